@@ -0,0 +1,120 @@
+package audio
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Opus always runs at 48kHz internally, whatever the input sample rate was
+const opusSampleRate = 48000
+
+// probeOgg reads an Ogg Opus file page by page. Granule positions count
+// samples, so the last one gives the duration once the pre-skip is removed.
+func probeOgg(data []byte) (*Info, error) {
+	var (
+		serial        uint32
+		packetIndex   int
+		packetData    []byte
+		packetSize    int
+		preSkip       int64
+		lastGranule   int64
+		packets       []packet
+		streamStarted bool
+	)
+
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < 27 || string(data[offset:offset+4]) != "OggS" {
+			return nil, ErrMalformed
+		}
+
+		granule := int64(binary.LittleEndian.Uint64(data[offset+6:]))
+		pageSerial := binary.LittleEndian.Uint32(data[offset+14:])
+		segments := int(data[offset+26])
+
+		bodyStart := offset + 27 + segments
+		if bodyStart > len(data) {
+			return nil, ErrMalformed
+		}
+		lacing := data[offset+27 : bodyStart]
+
+		bodyLength := 0
+		for _, l := range lacing {
+			bodyLength += int(l)
+		}
+		if bodyStart+bodyLength > len(data) {
+			return nil, ErrMalformed
+		}
+		offset = bodyStart + bodyLength
+
+		if !streamStarted {
+			serial = pageSerial
+			streamStarted = true
+		} else if pageSerial != serial {
+			// Only the first logical stream is read
+			continue
+		}
+
+		var completed []int
+		position := bodyStart
+		for _, l := range lacing {
+			// The first two packets are the headers, which we need to read
+			if packetIndex < 2 {
+				packetData = append(packetData, data[position:position+int(l)]...)
+			}
+			packetSize += int(l)
+			position += int(l)
+
+			// A lacing value under 255 ends the packet
+			if l == 255 {
+				continue
+			}
+
+			switch packetIndex {
+			case 0:
+				if len(packetData) < 19 || string(packetData[:8]) != "OpusHead" {
+					return nil, ErrUnsupportedFormat
+				}
+				preSkip = int64(binary.LittleEndian.Uint16(packetData[10:]))
+			case 1:
+				if len(packetData) < 8 || string(packetData[:8]) != "OpusTags" {
+					return nil, ErrMalformed
+				}
+			default:
+				completed = append(completed, packetSize)
+			}
+
+			packetIndex++
+			packetData = nil
+			packetSize = 0
+		}
+
+		// Pages where no packet ends have a granule position of -1
+		if granule < 0 {
+			continue
+		}
+
+		// Spread the packets that ended on this page evenly over the samples
+		// it covers
+		for i, size := range completed {
+			samples := lastGranule + (granule-lastGranule)*int64(i)/int64(len(completed))
+			packets = append(packets, packet{offset: samplesToDuration(samples), size: size})
+		}
+		lastGranule = granule
+	}
+
+	if packetIndex < 2 {
+		return nil, ErrMalformed
+	}
+
+	duration := samplesToDuration(max(0, lastGranule-preSkip))
+
+	return &Info{
+		ContentType: "audio/ogg",
+		Duration:    duration,
+		Waveform:    waveform(packets, samplesToDuration(lastGranule)),
+	}, nil
+}
+
+func samplesToDuration(samples int64) time.Duration {
+	return time.Duration(samples) * time.Second / opusSampleRate
+}
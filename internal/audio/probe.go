@@ -0,0 +1,33 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	ErrMalformed         = errors.New("malformed audio file")
+)
+
+// Info describes an audio file without decoding it.
+type Info struct {
+	ContentType string
+	Duration    time.Duration
+	// Waveform holds up to MaxWaveformLength loudness samples from 0 to 255
+	Waveform []byte
+}
+
+// Probe parses an Opus stream in an OGG or WebM container and returns its
+// duration and a waveform preview.
+func Probe(data []byte) (*Info, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return probeOgg(data)
+	case bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return probeWebM(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
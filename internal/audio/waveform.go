@@ -0,0 +1,49 @@
+package audio
+
+import "time"
+
+const MaxWaveformLength = 256
+
+// packet is a chunk of compressed audio at a point in the stream.
+type packet struct {
+	offset time.Duration
+	size   int
+}
+
+// waveform builds a loudness preview from packet sizes. Opus is variable
+// bitrate, so louder and busier audio takes more bytes to encode, which is
+// close enough for a preview without having to decode anything.
+func waveform(packets []packet, duration time.Duration) []byte {
+	if len(packets) == 0 || duration <= 0 {
+		return nil
+	}
+
+	n := min(len(packets), MaxWaveformLength)
+	sums := make([]int, n)
+	counts := make([]int, n)
+	for _, p := range packets {
+		i := int(int64(p.offset) * int64(n) / int64(duration))
+		i = max(0, min(n-1, i))
+		sums[i] += p.size
+		counts[i]++
+	}
+
+	averages := make([]int, n)
+	peak := 0
+	for i := range sums {
+		if counts[i] > 0 {
+			averages[i] = sums[i] / counts[i]
+		}
+		peak = max(peak, averages[i])
+	}
+
+	samples := make([]byte, n)
+	if peak == 0 {
+		return samples
+	}
+	for i, average := range averages {
+		samples[i] = byte(average * 255 / peak)
+	}
+
+	return samples
+}
@@ -0,0 +1,188 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// EBML element IDs, including their length marker bits
+const (
+	idEBML          = 0x1A45DFA3
+	idDocType       = 0x4282
+	idSegment       = 0x18538067
+	idInfo          = 0x1549A966
+	idTimecodeScale = 0x2AD7B1
+	idDuration      = 0x4489
+	idTracks        = 0x1654AE6B
+	idTrackEntry    = 0xAE
+	idTrackType     = 0x83
+	idCodecID       = 0x86
+	idCluster       = 0x1F43B675
+	idTimecode      = 0xE7
+	idBlockGroup    = 0xA0
+	idBlock         = 0xA1
+	idSimpleBlock   = 0xA3
+)
+
+const (
+	audioTrackType       = 2
+	defaultTimecodeScale = 1_000_000
+	// Browsers record Opus in 20ms frames, which is what the last block is
+	// assumed to last when the file doesn't state its duration
+	opusFrameDuration = 20 * time.Millisecond
+)
+
+// masterElements are read by descending into their children in place, which
+// also copes with the unknown sizes live recordings use for segments and
+// clusters.
+var masterElements = map[uint64]bool{
+	idEBML:       true,
+	idSegment:    true,
+	idInfo:       true,
+	idTracks:     true,
+	idTrackEntry: true,
+	idCluster:    true,
+	idBlockGroup: true,
+}
+
+// probeWebM reads an audio-only WebM file. Recordings made with MediaRecorder
+// don't have a duration in their header, so it falls back to the timestamp
+// of the last block.
+func probeWebM(data []byte) (*Info, error) {
+	var (
+		docType         string
+		timecodeScale   uint64 = defaultTimecodeScale
+		headerDuration  float64
+		codecs          []string
+		clusterTimecode int64
+		lastTimecode    int64
+		packets         []packet
+	)
+
+	for offset := 0; offset < len(data); {
+		id, n := readVint(data[offset:], true)
+		if n == 0 {
+			return nil, ErrMalformed
+		}
+		offset += n
+
+		size, n := readVint(data[offset:], false)
+		if n == 0 {
+			return nil, ErrMalformed
+		}
+		offset += n
+
+		if masterElements[id] {
+			continue
+		}
+
+		// Only master elements may have an unknown size
+		if size == unknownSize(n) || size > uint64(len(data)-offset) {
+			return nil, ErrMalformed
+		}
+		payload := data[offset : offset+int(size)]
+		offset += int(size)
+
+		switch id {
+		case idDocType:
+			docType = string(payload)
+		case idTimecodeScale:
+			timecodeScale = readUint(payload)
+		case idDuration:
+			headerDuration = readFloat(payload)
+		case idTrackType:
+			if readUint(payload) != audioTrackType {
+				return nil, ErrUnsupportedFormat
+			}
+		case idCodecID:
+			codecs = append(codecs, string(payload))
+		case idTimecode:
+			clusterTimecode = int64(readUint(payload))
+		case idBlock, idSimpleBlock:
+			_, n := readVint(payload, false)
+			if n == 0 || len(payload) < n+3 {
+				return nil, ErrMalformed
+			}
+
+			timecode := clusterTimecode + int64(int16(binary.BigEndian.Uint16(payload[n:])))
+			lastTimecode = max(lastTimecode, timecode)
+			packets = append(packets, packet{
+				offset: time.Duration(timecode) * time.Duration(timecodeScale),
+				size:   len(payload) - n - 3,
+			})
+		}
+	}
+
+	if docType != "webm" || len(codecs) == 0 {
+		return nil, ErrUnsupportedFormat
+	}
+	for _, codec := range codecs {
+		if codec != "A_OPUS" {
+			return nil, ErrUnsupportedFormat
+		}
+	}
+
+	scale := time.Duration(timecodeScale)
+
+	duration := time.Duration(headerDuration * float64(scale))
+	if duration <= 0 && len(packets) > 0 {
+		duration = time.Duration(lastTimecode)*scale + opusFrameDuration
+	}
+
+	return &Info{
+		ContentType: "audio/webm",
+		Duration:    duration,
+		Waveform:    waveform(packets, duration),
+	}, nil
+}
+
+// readVint reads an EBML variable length integer, returning its value and
+// length, or a length of 0 if it's invalid. IDs keep their length marker.
+func readVint(data []byte, keepMarker bool) (uint64, int) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0
+	}
+
+	length := 1
+	for mask := byte(0x80); data[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > len(data) {
+		return 0, 0
+	}
+
+	value := uint64(data[0])
+	if !keepMarker {
+		value &= 0xFF >> length
+	}
+	for _, b := range data[1:length] {
+		value = value<<8 | uint64(b)
+	}
+
+	return value, length
+}
+
+// unknownSize is the reserved size value, all ones, for a vint of length n.
+func unknownSize(n int) uint64 {
+	return 1<<(7*n) - 1
+}
+
+func readUint(data []byte) uint64 {
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+func readFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	default:
+		return 0
+	}
+}
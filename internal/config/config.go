@@ -1,7 +1,10 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/log"
@@ -18,6 +21,39 @@ type Config struct {
 	NATSURL       string
 	ClerkSecret   string
 	SigningSecret string
+	AuthProvider  string // "clerk", "local"
+	JWTAlgorithm  string // "HS256", "RS256"; only used by the local provider
+	JWTSecret     string
+	JWTKeyPath    string // PEM private key, or public key to only verify tokens
+	JWTIssuer     string
+	NodeID        string
+	SearchBackend string // "mysql", "memory"
+	PublicURL     string
+	MetricsAddr   string // expvar metrics listener, e.g. "localhost:9090"
+
+	StorageBackend       string // "local", "s3"
+	StoragePath          string
+	S3Endpoint           string
+	S3Region             string
+	S3Bucket             string
+	S3AccessKey          string
+	S3SecretKey          string
+	S3UseSSL             bool
+	AttachmentSigningKey string
+	MaxUploadSize        int64
+
+	VAPIDPrivateKey         string // Web Push is disabled when unset
+	VAPIDSubject            string
+	PushAllowLocalEndpoints bool // Allows http: and internal endpoints, for fake push services
+
+	MailBackend      string // "smtp", "file"; email is disabled when unset
+	SMTPHost         string
+	SMTPPort         int
+	SMTPUsername     string
+	SMTPPassword     string
+	MailFrom         string
+	MailPath         string
+	DigestSigningKey string
 }
 
 func LoadConfig() Config {
@@ -45,5 +81,117 @@ func LoadConfig() Config {
 	config.ClerkSecret = os.Getenv("CLERK_SECRET_KEY")
 	config.SigningSecret = os.Getenv("SIGNING_SECRET")
 
+	config.AuthProvider = strings.ToLower(os.Getenv("AUTH_PROVIDER"))
+	if config.AuthProvider == "" {
+		config.AuthProvider = "clerk"
+	}
+	config.JWTAlgorithm = strings.ToUpper(os.Getenv("JWT_ALGORITHM"))
+	if config.JWTAlgorithm == "" {
+		config.JWTAlgorithm = "HS256"
+	}
+	config.JWTSecret = os.Getenv("JWT_SECRET")
+	config.JWTKeyPath = os.Getenv("JWT_KEY_PATH")
+	config.JWTIssuer = os.Getenv("JWT_ISSUER")
+	if config.JWTIssuer == "" {
+		config.JWTIssuer = "yapchat"
+	}
+
+	config.SearchBackend = strings.ToLower(os.Getenv("SEARCH_BACKEND"))
+	if config.SearchBackend == "" {
+		config.SearchBackend = "mysql"
+	}
+
+	config.PublicURL = strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	config.MetricsAddr = os.Getenv("METRICS_ADDR")
+
+	config.StorageBackend = strings.ToLower(os.Getenv("STORAGE_BACKEND"))
+	if config.StorageBackend == "" {
+		config.StorageBackend = "local"
+	}
+	config.StoragePath = os.Getenv("STORAGE_PATH")
+	if config.StoragePath == "" {
+		config.StoragePath = "./data"
+	}
+	config.S3Endpoint = os.Getenv("S3_ENDPOINT")
+	config.S3Region = os.Getenv("S3_REGION")
+	config.S3Bucket = os.Getenv("S3_BUCKET")
+	config.S3AccessKey = os.Getenv("S3_ACCESS_KEY")
+	config.S3SecretKey = os.Getenv("S3_SECRET_KEY")
+	config.S3UseSSL = os.Getenv("S3_USE_SSL") != "false"
+
+	config.AttachmentSigningKey = os.Getenv("ATTACHMENT_SIGNING_KEY")
+	if config.AttachmentSigningKey == "" {
+		if config.AppEnv == "prod" {
+			log.Fatal("ATTACHMENT_SIGNING_KEY must be set in production")
+		}
+
+		// Signed URLs won't survive a restart or work across nodes, which is
+		// fine outside of production
+		key := make([]byte, 32)
+		rand.Read(key)
+		config.AttachmentSigningKey = hex.EncodeToString(key)
+		log.Warn("No ATTACHMENT_SIGNING_KEY set. Using a random key.")
+	}
+
+	config.MaxUploadSize = 25 << 20 // 25 MiB
+	if maxUploadSize := os.Getenv("MAX_UPLOAD_SIZE"); maxUploadSize != "" {
+		size, err := strconv.ParseInt(maxUploadSize, 10, 64)
+		if err != nil || size <= 0 {
+			log.Fatal("Invalid MAX_UPLOAD_SIZE", "value", maxUploadSize)
+		}
+		config.MaxUploadSize = size
+	}
+
+	config.VAPIDPrivateKey = os.Getenv("VAPID_PRIVATE_KEY")
+	config.VAPIDSubject = os.Getenv("VAPID_SUBJECT")
+	if config.VAPIDSubject == "" {
+		config.VAPIDSubject = config.PublicURL
+	}
+	config.PushAllowLocalEndpoints = os.Getenv("PUSH_ALLOW_LOCAL_ENDPOINTS") == "true"
+	if config.PushAllowLocalEndpoints && config.AppEnv == "prod" {
+		log.Fatal("PUSH_ALLOW_LOCAL_ENDPOINTS can't be used in production")
+	}
+
+	config.MailBackend = strings.ToLower(os.Getenv("MAIL_BACKEND"))
+	config.SMTPHost = os.Getenv("SMTP_HOST")
+	config.SMTPPort = 587
+	if smtpPort := os.Getenv("SMTP_PORT"); smtpPort != "" {
+		port, err := strconv.Atoi(smtpPort)
+		if err != nil || port <= 0 {
+			log.Fatal("Invalid SMTP_PORT", "value", smtpPort)
+		}
+		config.SMTPPort = port
+	}
+	config.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	config.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	config.MailFrom = os.Getenv("MAIL_FROM")
+	config.MailPath = os.Getenv("MAIL_PATH")
+	if config.MailPath == "" {
+		config.MailPath = "./mail"
+	}
+
+	config.DigestSigningKey = os.Getenv("DIGEST_SIGNING_KEY")
+	if config.DigestSigningKey == "" && config.MailBackend != "" {
+		if config.AppEnv == "prod" {
+			log.Fatal("DIGEST_SIGNING_KEY must be set in production when email is enabled")
+		}
+
+		// Unsubscribe links won't survive a restart, which is fine outside
+		// of production
+		key := make([]byte, 32)
+		rand.Read(key)
+		config.DigestSigningKey = hex.EncodeToString(key)
+		log.Warn("No DIGEST_SIGNING_KEY set. Using a random key.")
+	}
+
+	config.NodeID = os.Getenv("NODE_ID")
+	if config.NodeID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Fatal("Failed to determine hostname for node ID", "err", err.Error())
+		}
+		config.NodeID = hostname
+	}
+
 	return config
 }
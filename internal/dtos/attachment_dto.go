@@ -0,0 +1,28 @@
+package dtos
+
+// AttachmentResponse describes an attachment. Voice messages come with their
+// duration and a base64 encoded waveform of loudness samples from 0 to 255.
+type AttachmentResponse struct {
+	ID           uint                          `json:"id"                       validate:"required" example:"1"`
+	Filename     string                        `json:"filename"                 validate:"required" example:"screenshot.png"`
+	ContentType  string                        `json:"content_type"             validate:"required" example:"image/png"`
+	Size         int64                         `json:"size"                     validate:"required" example:"204800"`
+	URL          string                        `json:"url,omitempty"                                example:"https://api.yapchat.xyz/attachments/1/download?expires=0&signature=abc"`
+	URLExpiresAt string                        `json:"url_expires_at,omitempty"                     example:"1970-01-01T00:00:00Z"`
+	Width        int                           `json:"width,omitempty"                              example:"1920"`
+	Height       int                           `json:"height,omitempty"                             example:"1080"`
+	BlurHash     string                        `json:"blurhash,omitempty"                           example:"LEHV6nWB2yk8pyo0adR*.7kCMdnj"`
+	Thumbnails   []AttachmentThumbnailResponse `json:"thumbnails,omitempty"`
+	VoiceMessage bool                          `json:"voice_message,omitempty"                      example:"true"`
+	DurationSecs float64                       `json:"duration_secs,omitempty"                      example:"4.28"`
+	Waveform     string                        `json:"waveform,omitempty"                           example:"AAwZJjM/TFhlcn6LmKSxvsrX5PA="`
+}
+
+// AttachmentThumbnailResponse is a scaled down copy of an image attachment
+// that fits within a size by size square.
+type AttachmentThumbnailResponse struct {
+	Size   int    `json:"size"   validate:"required" example:"256"`
+	Width  int    `json:"width"  validate:"required" example:"256"`
+	Height int    `json:"height" validate:"required" example:"144"`
+	URL    string `json:"url"    validate:"required" example:"https://api.yapchat.xyz/attachments/1/download?expires=0&signature=abc&thumbnail=256"`
+}
@@ -0,0 +1,52 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type CreateBotRequest struct {
+	Username    string `json:"username"               validate:"required" example:"deploy_bot"`
+	DisplayName string `json:"display_name,omitempty"                     example:"Deploy Bot"`
+}
+
+// CreateBotTokenRequest issues a token for a bot. Scopes are any of
+// users:read, rooms:read, rooms:join, messages:read, messages:write and
+// commands:write.
+type CreateBotTokenRequest struct {
+	Name   string   `json:"name"   validate:"required" example:"CI"`
+	Scopes []string `json:"scopes" validate:"required" example:"messages:read,messages:write"`
+}
+
+type BotTokenResponse struct {
+	ID         uint     `json:"id"                     validate:"required" example:"1"`
+	Name       string   `json:"name"                   validate:"required" example:"CI"`
+	Scopes     []string `json:"scopes"                 validate:"required" example:"messages:read,messages:write"`
+	LastUsedAt string   `json:"last_used_at,omitempty"                     example:"1970-01-01T00:00:00Z"`
+	CreatedAt  string   `json:"created_at"             validate:"required" example:"1970-01-01T00:00:00Z"`
+}
+
+// IssuedBotTokenResponse holds a newly issued token, which is only ever
+// shown once.
+type IssuedBotTokenResponse struct {
+	Token    string           `json:"token"     validate:"required" example:"ybot_9b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a"`
+	BotToken BotTokenResponse `json:"bot_token" validate:"required"`
+}
+
+func NewBotTokenResponse(token *models.BotToken) BotTokenResponse {
+	response := BotTokenResponse{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    make([]string, len(token.Scopes)),
+		CreatedAt: token.CreatedAt.Format(time.RFC3339),
+	}
+	for i, scope := range token.Scopes {
+		response.Scopes[i] = string(scope)
+	}
+	if token.LastUsedAt != nil {
+		response.LastUsedAt = token.LastUsedAt.Format(time.RFC3339)
+	}
+
+	return response
+}
@@ -4,15 +4,26 @@ import "github.com/teamyapchat/yapchat-server/internal/models"
 
 type ChatRoomRequest struct {
 	Name           string              `json:"name,omitempty"            example:"My Group Chat"`
-	Type           models.ChatRoomType `json:"type"                      example:"group"                                   validate:"required"`
+	Type           models.ChatRoomType `json:"type"                      example:"group"         validate:"required"`
 	ParticipantIDs []string            `json:"participant_ids,omitempty"`
-	ImageURL       string              `json:"image_url,omitempty"       example:"https://example.com/profile_picture.jpg"`
+}
+
+type UpdateChatRoomRequest struct {
+	Name string `json:"name" validate:"required" example:"My Group Chat"`
+}
+
+// MarkRoomReadRequest moves the user's read marker in a room up to a message.
+type MarkRoomReadRequest struct {
+	MessageID uint `json:"message_id" validate:"required" example:"1"`
 }
 
 type ChatRoomResponse struct {
-	ID           uint           `json:"id"                  validate:"required"`
-	Name         string         `json:"name"                validate:"required"`
-	Type         string         `json:"type"                validate:"required"`
-	Participants []UserResponse `json:"participants"        validate:"required"`
-	ImageURL     string         `json:"image_url,omitempty"`
+	ID           uint                  `json:"id"                  validate:"required"`
+	Name         string                `json:"name"                validate:"required"`
+	Topic        string                `json:"topic,omitempty"`
+	Type         string                `json:"type"                validate:"required"`
+	OwnerID      string                `json:"owner_id,omitempty"`
+	Participants []UserResponse        `json:"participants"        validate:"required"`
+	ImageURL     string                `json:"image_url,omitempty"`
+	Settings     *RoomSettingsResponse `json:"settings,omitempty"`
 }
@@ -0,0 +1,69 @@
+package dtos
+
+import "github.com/teamyapchat/yapchat-server/internal/models"
+
+// CommandOptionRequest is an argument of a bot command. Type is one of
+// string, integer, boolean or user.
+type CommandOptionRequest struct {
+	Name        string `json:"name"               validate:"required" example:"environment"`
+	Description string `json:"description"        validate:"required" example:"Where to deploy"`
+	Type        string `json:"type"               validate:"required" example:"string"`
+	Required    bool   `json:"required,omitempty"                     example:"true"`
+}
+
+type BotCommandRequest struct {
+	Name        string                 `json:"name"              validate:"required" example:"deploy"`
+	Description string                 `json:"description"       validate:"required" example:"Deploy the latest build"`
+	Options     []CommandOptionRequest `json:"options,omitempty"`
+}
+
+// SetBotCommandsRequest replaces all of a bot's commands.
+type SetBotCommandsRequest struct {
+	Commands []BotCommandRequest `json:"commands" validate:"required"`
+}
+
+type CommandOptionResponse struct {
+	Name        string `json:"name"        validate:"required" example:"environment"`
+	Description string `json:"description" validate:"required" example:"Where to deploy"`
+	Type        string `json:"type"        validate:"required" example:"string"`
+	Required    bool   `json:"required"                        example:"true"`
+}
+
+// CommandResponse is a slash command. BotID is empty for built-in commands.
+type CommandResponse struct {
+	Name        string                  `json:"name"             validate:"required" example:"deploy"`
+	Description string                  `json:"description"      validate:"required" example:"Deploy the latest build"`
+	Options     []CommandOptionResponse `json:"options"          validate:"required"`
+	BotID       string                  `json:"bot_id,omitempty"                     example:"bot_5f2b9c0e4d1a7e3b6c8f0a12"`
+}
+
+// CommandInvocationData is sent to a bot when one of its commands is
+// invoked. Options holds the arguments by option name, as strings, integers,
+// booleans or user IDs. The bot can reply to just the session the command was
+// sent from with an ephemeral message.
+type CommandInvocationData struct {
+	Name      string         `json:"name"       validate:"required" example:"deploy"`
+	RoomID    uint           `json:"room_id"    validate:"required" example:"1"`
+	UserID    string         `json:"user_id"    validate:"required" example:"123"`
+	SessionID string         `json:"session_id" validate:"required" example:"01JA2B3C4D5E6F7G8H9J0KMNPQ"`
+	Options   map[string]any `json:"options"    validate:"required"`
+}
+
+func NewCommandResponse(command *models.BotCommand) CommandResponse {
+	response := CommandResponse{
+		Name:        command.Name,
+		Description: command.Description,
+		Options:     make([]CommandOptionResponse, len(command.Options)),
+		BotID:       command.BotID,
+	}
+	for i, option := range command.Options {
+		response.Options[i] = CommandOptionResponse{
+			Name:        option.Name,
+			Description: option.Description,
+			Type:        string(option.Type),
+			Required:    option.Required,
+		}
+	}
+
+	return response
+}
@@ -0,0 +1,45 @@
+package dtos
+
+import "github.com/teamyapchat/yapchat-server/internal/models"
+
+type EmbedResponse struct {
+	Type        string `json:"type"                   validate:"required" example:"link"`
+	URL         string `json:"url"                    validate:"required" example:"https://github.com/teamyapchat"`
+	Title       string `json:"title,omitempty"                            example:"YapChat"`
+	Description string `json:"description,omitempty"                      example:"A chat app for yapping"`
+	SiteName    string `json:"site_name,omitempty"                        example:"GitHub"`
+	ImageURL    string `json:"image_url,omitempty"                        example:"https://avatars.githubusercontent.com/u/1"`
+	ImageWidth  int    `json:"image_width,omitempty"                      example:"1200"`
+	ImageHeight int    `json:"image_height,omitempty"                     example:"630"`
+	ThemeColor  string `json:"theme_color,omitempty"                      example:"#5865F2"`
+}
+
+// MessageEmbedsUpdateData is sent once the links in a message have been
+// unfurled, which happens after the message itself was delivered.
+type MessageEmbedsUpdateData struct {
+	MessageID uint            `json:"message_id" validate:"required" example:"1"`
+	RoomID    uint            `json:"room_id"    validate:"required" example:"1"`
+	Embeds    []EmbedResponse `json:"embeds"     validate:"required"`
+}
+
+func NewEmbedResponses(embeds []models.Embed) []EmbedResponse {
+	if len(embeds) == 0 {
+		return nil
+	}
+
+	responses := make([]EmbedResponse, 0, len(embeds))
+	for _, embed := range embeds {
+		responses = append(responses, EmbedResponse{
+			Type:        string(embed.Type),
+			URL:         embed.URL,
+			Title:       embed.Title,
+			Description: embed.Description,
+			SiteName:    embed.SiteName,
+			ImageURL:    embed.ImageURL,
+			ImageWidth:  embed.ImageWidth,
+			ImageHeight: embed.ImageHeight,
+			ThemeColor:  embed.ThemeColor,
+		})
+	}
+	return responses
+}
@@ -0,0 +1,45 @@
+package dtos
+
+import "github.com/teamyapchat/yapchat-server/internal/models"
+
+// EntityResponse marks a formatted span of a message's content. Offsets
+// count UTF-16 code units. Offset and Length cover the whole span including
+// its markup, and InnerOffset and InnerLength the text to display. Mentions
+// and custom emoji are replaced entirely, so both ranges are the same.
+type EntityResponse struct {
+	Type        string `json:"type"                 validate:"required" example:"bold"`
+	Offset      int    `json:"offset"                                   example:"0"`
+	Length      int    `json:"length"               validate:"required" example:"8"`
+	InnerOffset int    `json:"inner_offset"                             example:"2"`
+	InnerLength int    `json:"inner_length"                             example:"4"`
+	URL         string `json:"url,omitempty"                            example:"https://yapchat.xyz"`
+	Language    string `json:"language,omitempty"                       example:"go"`
+	UserID      string `json:"user_id,omitempty"                        example:"user_2NNEqL2nrIRdJ194ndJqAHwEfxC"`
+	RoomID      uint   `json:"room_id,omitempty"                        example:"1"`
+	EmojiName   string `json:"emoji_name,omitempty"                     example:"party"`
+	EmojiID     string `json:"emoji_id,omitempty"                       example:"1234567890"`
+}
+
+func NewEntityResponses(entities []models.Entity) []EntityResponse {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	responses := make([]EntityResponse, 0, len(entities))
+	for _, entity := range entities {
+		responses = append(responses, EntityResponse{
+			Type:        string(entity.Type),
+			Offset:      entity.Offset,
+			Length:      entity.Length,
+			InnerOffset: entity.InnerOffset,
+			InnerLength: entity.InnerLength,
+			URL:         entity.URL,
+			Language:    entity.Language,
+			UserID:      entity.UserID,
+			RoomID:      entity.RoomID,
+			EmojiName:   entity.EmojiName,
+			EmojiID:     entity.EmojiID,
+		})
+	}
+	return responses
+}
@@ -0,0 +1,35 @@
+package dtos
+
+import "time"
+
+type EventType string
+
+const (
+	PresenceUpdateEvent      EventType = "PRESENCE_UPDATE"
+	ProfileUpdateEvent       EventType = "PROFILE_UPDATE"
+	MessageEmbedsUpdateEvent EventType = "MESSAGE_EMBEDS_UPDATE"
+	MentionCreateEvent       EventType = "MENTION_CREATE"
+	RoomSettingsUpdateEvent  EventType = "ROOM_SETTINGS_UPDATE"
+	MessagePinEvent          EventType = "MESSAGE_PIN"
+	MessageUnpinEvent        EventType = "MESSAGE_UNPIN"
+	CommandInvokeEvent       EventType = "COMMAND_INVOKE"
+	EphemeralMessageEvent    EventType = "EPHEMERAL_MESSAGE"
+)
+
+// Event is what clients receive over the gateway for anything that isn't a
+// chat message.
+type Event struct {
+	Type      EventType `json:"type"`
+	Data      any       `json:"data"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// GatewayEvent is published to NATS so that every gateway node can deliver
+// the event to whichever recipients it has connected. Events with a
+// SessionID only go to that session of the recipients.
+type GatewayEvent struct {
+	Event        Event    `json:"event"`
+	RecipientIDs []string `json:"recipient_ids,omitempty"`
+	RoomID       uint     `json:"room_id,omitempty"`
+	SessionID    string   `json:"session_id,omitempty"`
+}
@@ -0,0 +1,43 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type CreateIncomingWebhookRequest struct {
+	Name      string `json:"name"                 validate:"required" example:"CI"`
+	AvatarURL string `json:"avatar_url,omitempty"                     example:"https://example.com/ci.png"`
+}
+
+// ExecuteIncomingWebhookRequest posts a message through a webhook. Username
+// and AvatarURL override the webhook's name and avatar for this message.
+type ExecuteIncomingWebhookRequest struct {
+	Content   string `json:"content"              validate:"required" example:"Build #42 passed"`
+	Username  string `json:"username,omitempty"                       example:"CI (main)"`
+	AvatarURL string `json:"avatar_url,omitempty"                     example:"https://example.com/ci.png"`
+}
+
+// IncomingWebhookResponse is a webhook. Its URL, which includes the secret
+// token, is only included when the webhook is created.
+type IncomingWebhookResponse struct {
+	ID        uint   `json:"id"                   validate:"required" example:"1"`
+	RoomID    uint   `json:"room_id"              validate:"required" example:"1"`
+	Name      string `json:"name"                 validate:"required" example:"CI"`
+	AvatarURL string `json:"avatar_url,omitempty"                     example:"https://example.com/ci.png"`
+	CreatorID string `json:"creator_id"           validate:"required" example:"123"`
+	URL       string `json:"url,omitempty"                            example:"https://yapchat.example.com/webhooks/1/9b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e"`
+	CreatedAt string `json:"created_at"           validate:"required" example:"1970-01-01T00:00:00Z"`
+}
+
+func NewIncomingWebhookResponse(webhook *models.IncomingWebhook) IncomingWebhookResponse {
+	return IncomingWebhookResponse{
+		ID:        webhook.ID,
+		RoomID:    webhook.RoomID,
+		Name:      webhook.Name,
+		AvatarURL: webhook.AvatarURL,
+		CreatorID: webhook.CreatorID,
+		CreatedAt: webhook.CreatedAt.Format(time.RFC3339),
+	}
+}
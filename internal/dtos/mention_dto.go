@@ -0,0 +1,55 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type MentionResponse struct {
+	ID        uint            `json:"id"                validate:"required" example:"1"`
+	Type      string          `json:"type"              validate:"required" example:"user"`
+	RoomID    uint            `json:"room_id"           validate:"required" example:"1"`
+	Message   MessageResponse `json:"message"           validate:"required"`
+	Read      bool            `json:"read"                                  example:"false"`
+	ReadAt    string          `json:"read_at,omitempty"                     example:"1970-01-01T00:00:00Z"`
+	CreatedAt string          `json:"created_at"        validate:"required" example:"1970-01-01T00:00:00Z"`
+}
+
+type MarkMentionsReadRequest struct {
+	// Only marks mentions in this room as read when set
+	RoomID uint `json:"room_id,omitempty" example:"1"`
+}
+
+// NewMentionResponse builds the inbox entry for a mention. The message is a
+// preview without attachments or embeds, which clients load by jumping to
+// it.
+func NewMentionResponse(mention *models.Mention) MentionResponse {
+	response := MentionResponse{
+		ID:     mention.ID,
+		Type:   string(mention.Type),
+		RoomID: mention.RoomID,
+		Message: MessageResponse{
+			ID:        mention.Message.ID,
+			Type:      string(mention.Message.Type),
+			Content:   mention.Message.Content,
+			SenderID:  mention.Message.SenderID,
+			RoomID:    mention.Message.RoomID,
+			Entities:  NewEntityResponses(mention.Message.Entities),
+			Timestamp: mention.Message.Timestamp.Format(time.RFC3339),
+		},
+		Read:      mention.ReadAt != nil,
+		CreatedAt: mention.CreatedAt.Format(time.RFC3339),
+	}
+
+	if mention.Message.WebhookID != nil {
+		response.Message.WebhookID = *mention.Message.WebhookID
+		response.Message.Username = mention.Message.Username
+		response.Message.AvatarURL = mention.Message.AvatarURL
+	}
+	if mention.ReadAt != nil {
+		response.ReadAt = mention.ReadAt.Format(time.RFC3339)
+	}
+
+	return response
+}
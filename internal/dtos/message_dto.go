@@ -1,12 +1,98 @@
 package dtos
 
-import "time"
+import (
+	"time"
 
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+// MessageResponse is a chat message. Silent is set on the copies the gateway
+// delivers to recipients whose notification settings say not to notify them.
+// System messages have an Event for clients to render, with their Content as
+// a fallback. Messages posted through a webhook have a WebhookID and are
+// shown with Username and AvatarURL instead of the sender's profile.
+// Ephemeral messages are only shown to the user they were sent to and aren't
+// stored, so they have no ID. Those from the server have no sender.
 type MessageResponse struct {
-	Content   string `json:"content"           validate:"required"`
-	SenderID  string `json:"sender_id"         validate:"required"`
-	RoomID    uint   `json:"room_id,omitempty"`
-	Timestamp string `json:"timestamp"         validate:"required"`
+	ID          uint                 `json:"id,omitempty"`
+	Type        string               `json:"type"                   validate:"required" example:"user"`
+	Event       string               `json:"event,omitempty"                            example:"message_pinned"`
+	Content     string               `json:"content"                validate:"required"`
+	SenderID    string               `json:"sender_id"              validate:"required"`
+	RoomID      uint                 `json:"room_id,omitempty"`
+	ReferenceID uint                 `json:"reference_id,omitempty"`
+	WebhookID   uint                 `json:"webhook_id,omitempty"`
+	Username    string               `json:"username,omitempty"                         example:"CI"`
+	AvatarURL   string               `json:"avatar_url,omitempty"                       example:"https://example.com/ci.png"`
+	Entities    []EntityResponse     `json:"entities,omitempty"`
+	Attachments []AttachmentResponse `json:"attachments,omitempty"`
+	Embeds      []EmbedResponse      `json:"embeds,omitempty"`
+	Pinned      bool                 `json:"pinned,omitempty"`
+	Timestamp   string               `json:"timestamp"              validate:"required"`
+	Silent      bool                 `json:"silent,omitempty"`
+	Ephemeral   bool                 `json:"ephemeral,omitempty"`
+}
+
+// EphemeralMessageRequest sends a message only one user in the room can see,
+// to just one of their sessions if SessionID is set.
+type EphemeralMessageRequest struct {
+	UserID    string `json:"user_id"              validate:"required" example:"123"`
+	SessionID string `json:"session_id,omitempty"                     example:"01JA2B3C4D5E6F7G8H9J0KMNPQ"`
+	Content   string `json:"content"              validate:"required" example:"Deploying to staging."`
+}
+
+// PinResponse is a pinned message along with who pinned it and when.
+type PinResponse struct {
+	Message    MessageResponse `json:"message"      validate:"required"`
+	PinnedByID string          `json:"pinned_by_id" validate:"required" example:"123"`
+	PinnedAt   string          `json:"pinned_at"    validate:"required" example:"1970-01-01T00:00:00Z"`
+}
+
+// MessagePinData is sent when a message is pinned.
+type MessagePinData struct {
+	MessageID  uint   `json:"message_id"   validate:"required" example:"1"`
+	RoomID     uint   `json:"room_id"      validate:"required" example:"1"`
+	PinnedByID string `json:"pinned_by_id" validate:"required" example:"123"`
+	PinnedAt   string `json:"pinned_at"    validate:"required" example:"1970-01-01T00:00:00Z"`
+}
+
+// MessageUnpinData is sent when a message is unpinned.
+type MessageUnpinData struct {
+	MessageID uint `json:"message_id" validate:"required" example:"1"`
+	RoomID    uint `json:"room_id"    validate:"required" example:"1"`
+}
+
+// NewMessageResponse builds the response for a message, except for its
+// attachments, whose URLs have to be signed by the attachment service.
+func NewMessageResponse(message *models.Message) MessageResponse {
+	response := MessageResponse{
+		ID:        message.ID,
+		Type:      string(message.Type),
+		Event:     string(message.Event),
+		Content:   message.Content,
+		SenderID:  message.SenderID,
+		RoomID:    message.RoomID,
+		Entities:  NewEntityResponses(message.Entities),
+		Embeds:    NewEmbedResponses(message.Embeds),
+		Pinned:    message.PinnedAt != nil,
+		Timestamp: message.Timestamp.Format(time.RFC3339),
+	}
+
+	if message.ReferenceID != nil {
+		response.ReferenceID = *message.ReferenceID
+	}
+	if message.WebhookID != nil {
+		response.WebhookID = *message.WebhookID
+		response.Username = message.Username
+		response.AvatarURL = message.AvatarURL
+	}
+
+	return response
+}
+
+type MessageSearchResult struct {
+	Message MessageResponse `json:"message" validate:"required"`
+	Snippet string          `json:"snippet" validate:"required" example:"the <mark>deploy</mark> failed again"`
 }
 
 type Payload struct {
@@ -17,8 +103,9 @@ type Payload struct {
 
 // Opcode 0
 type DispatchData struct {
-	Content string `mapstructure:"content"`
-	RoomID  uint   `mapstructure:"room_id"`
+	Content       string `mapstructure:"content"`
+	RoomID        uint   `mapstructure:"room_id"`
+	AttachmentIDs []uint `mapstructure:"attachment_ids"`
 }
 
 // Opcode 1
@@ -0,0 +1,100 @@
+package dtos
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+// CreateOutgoingWebhookRequest subscribes a URL to events, any of
+// message.created, member.joined and member.left.
+type CreateOutgoingWebhookRequest struct {
+	URL    string   `json:"url"    validate:"required" example:"https://example.com/yapchat/events"`
+	Events []string `json:"events" validate:"required" example:"message.created,member.joined"`
+}
+
+// OutgoingWebhookResponse is an outgoing webhook. Its signing secret is only
+// included when the webhook is created.
+type OutgoingWebhookResponse struct {
+	ID        uint     `json:"id"                validate:"required" example:"1"`
+	RoomID    uint     `json:"room_id,omitempty"                     example:"1"`
+	BotID     string   `json:"bot_id,omitempty"                      example:"bot_5f2b9c0e4d1a7e3b6c8f0a12"`
+	URL       string   `json:"url"               validate:"required" example:"https://example.com/yapchat/events"`
+	Events    []string `json:"events"            validate:"required" example:"message.created,member.joined"`
+	CreatorID string   `json:"creator_id"        validate:"required" example:"123"`
+	Secret    string   `json:"secret,omitempty"                      example:"whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"`
+	CreatedAt string   `json:"created_at"        validate:"required" example:"1970-01-01T00:00:00Z"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uint            `json:"id"                         validate:"required" example:"1"`
+	MessageID      string          `json:"message_id"                 validate:"required" example:"msg_2b9c0e4d1a7e3b6c8f0a12"`
+	Event          string          `json:"event"                      validate:"required" example:"message.created"`
+	Status         string          `json:"status"                     validate:"required" example:"failed"`
+	Attempts       int             `json:"attempts"                                       example:"8"`
+	NextAttemptAt  string          `json:"next_attempt_at,omitempty"                      example:"1970-01-01T00:00:00Z"`
+	LastStatusCode int             `json:"last_status_code,omitempty"                     example:"502"`
+	LastError      string          `json:"last_error,omitempty"                           example:"webhook receiver responded with status 502"`
+	DeliveredAt    string          `json:"delivered_at,omitempty"                         example:"1970-01-01T00:00:00Z"`
+	Payload        json.RawMessage `json:"payload"                    validate:"required"                                                      swaggertype:"object"`
+	CreatedAt      string          `json:"created_at"                 validate:"required" example:"1970-01-01T00:00:00Z"`
+}
+
+// WebhookEvent is the body of every outgoing webhook request. Data is a
+// MessageResponse for message events and a MemberEventData for member
+// events.
+type WebhookEvent struct {
+	Type      string `json:"type"      validate:"required" example:"message.created"`
+	Timestamp string `json:"timestamp" validate:"required" example:"1970-01-01T00:00:00Z"`
+	Data      any    `json:"data"      validate:"required"`
+}
+
+// MemberEventData is sent when someone joins or leaves a room. ModeratorID is
+// set when they were kicked.
+type MemberEventData struct {
+	RoomID      uint   `json:"room_id"                validate:"required" example:"1"`
+	UserID      string `json:"user_id"                validate:"required" example:"123"`
+	ModeratorID string `json:"moderator_id,omitempty"                     example:"456"`
+}
+
+func NewOutgoingWebhookResponse(webhook *models.OutgoingWebhook) OutgoingWebhookResponse {
+	response := OutgoingWebhookResponse{
+		ID:        webhook.ID,
+		BotID:     webhook.BotID,
+		URL:       webhook.URL,
+		Events:    make([]string, len(webhook.Events)),
+		CreatorID: webhook.CreatorID,
+		CreatedAt: webhook.CreatedAt.Format(time.RFC3339),
+	}
+	for i, event := range webhook.Events {
+		response.Events[i] = string(event)
+	}
+	if webhook.RoomID != nil {
+		response.RoomID = *webhook.RoomID
+	}
+
+	return response
+}
+
+func NewWebhookDeliveryResponse(delivery *models.WebhookDelivery) WebhookDeliveryResponse {
+	response := WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		MessageID:      delivery.MessageID,
+		Event:          string(delivery.Event),
+		Status:         string(delivery.Status),
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		Payload:        json.RawMessage(delivery.Payload),
+		CreatedAt:      delivery.CreatedAt.Format(time.RFC3339),
+	}
+	if delivery.Status == models.DeliveryPending && delivery.NextAttemptAt != nil {
+		response.NextAttemptAt = delivery.NextAttemptAt.Format(time.RFC3339)
+	}
+	if delivery.DeliveredAt != nil {
+		response.DeliveredAt = delivery.DeliveredAt.Format(time.RFC3339)
+	}
+
+	return response
+}
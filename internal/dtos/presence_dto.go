@@ -0,0 +1,66 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type UpdatePresenceRequest struct {
+	Status       string               `json:"status,omitempty"        example:"idle"`
+	CustomStatus *CustomStatusRequest `json:"custom_status,omitempty"`
+}
+
+type CustomStatusRequest struct {
+	Text      string     `json:"text,omitempty"       example:"Out for lunch"`
+	Emoji     string     `json:"emoji,omitempty"      example:"🍔"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"1970-01-01T00:00:00Z"`
+}
+
+type PresenceResponse struct {
+	UserID       string                `json:"user_id,omitempty"                           example:"123"`
+	Status       string                `json:"status"                  validate:"required" example:"online"`
+	CustomStatus *CustomStatusResponse `json:"custom_status,omitempty"`
+	LastSeen     string                `json:"last_seen,omitempty"                         example:"1970-01-01T00:00:00Z"`
+}
+
+type CustomStatusResponse struct {
+	Text      string `json:"text,omitempty"       example:"Out for lunch"`
+	Emoji     string `json:"emoji,omitempty"      example:"🍔"`
+	ExpiresAt string `json:"expires_at,omitempty" example:"1970-01-01T00:00:00Z"`
+}
+
+func NewPresenceResponse(presence *models.Presence) PresenceResponse {
+	response := PresenceResponse{
+		UserID: presence.UserID,
+		Status: string(presence.Status),
+	}
+
+	if presence.CustomStatus != nil {
+		response.CustomStatus = &CustomStatusResponse{
+			Text:  presence.CustomStatus.Text,
+			Emoji: presence.CustomStatus.Emoji,
+		}
+		if presence.CustomStatus.ExpiresAt != nil {
+			response.CustomStatus.ExpiresAt = presence.CustomStatus.ExpiresAt.Format(time.RFC3339)
+		}
+	}
+
+	if presence.Status == models.StatusOffline && !presence.LastSeen.IsZero() {
+		response.LastSeen = presence.LastSeen.Format(time.RFC3339)
+	}
+
+	return response
+}
+
+// Opcode 2
+type PresenceUpdateData struct {
+	Status       string            `mapstructure:"status"`
+	CustomStatus *CustomStatusData `mapstructure:"custom_status"`
+}
+
+type CustomStatusData struct {
+	Text      string `mapstructure:"text"`
+	Emoji     string `mapstructure:"emoji"`
+	ExpiresAt string `mapstructure:"expires_at"`
+}
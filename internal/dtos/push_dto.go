@@ -0,0 +1,33 @@
+package dtos
+
+// PushSubscriptionRequest is the JSON form of the browser's PushSubscription.
+type PushSubscriptionRequest struct {
+	Endpoint string               `json:"endpoint" validate:"required" example:"https://fcm.googleapis.com/fcm/send/abc123"`
+	Keys     PushSubscriptionKeys `json:"keys"     validate:"required"`
+}
+
+type PushSubscriptionKeys struct {
+	P256dh string `json:"p256dh" validate:"required" example:"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"`
+	Auth   string `json:"auth"   validate:"required" example:"BTBZMqHH6r4Tts7J_aSIgg"`
+}
+
+type DeletePushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" validate:"required" example:"https://fcm.googleapis.com/fcm/send/abc123"`
+}
+
+type VAPIDKeyResponse struct {
+	PublicKey string `json:"public_key" validate:"required" example:"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"`
+}
+
+// PushNotification is the decrypted payload of a Web Push message, for the
+// client's service worker to show.
+type PushNotification struct {
+	Type       string `json:"type"`
+	RoomID     uint   `json:"room_id"`
+	RoomName   string `json:"room_name,omitempty"`
+	MessageID  uint   `json:"message_id"`
+	SenderID   string `json:"sender_id"`
+	SenderName string `json:"sender_name"`
+	Body       string `json:"body"`
+	Timestamp  string `json:"timestamp"`
+}
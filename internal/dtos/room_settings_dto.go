@@ -0,0 +1,35 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+// RoomSettingsRequest updates a user's settings for a chat room. Omitted
+// fields are left unchanged and an empty muted_until unmutes the room.
+// Notifications is one of all, mentions or none, or default to follow the
+// user's default_notifications.
+type RoomSettingsRequest struct {
+	Notifications *string `json:"notifications,omitempty" example:"mentions"`
+	MutedUntil    *string `json:"muted_until,omitempty"   example:"1970-01-01T00:00:00Z"`
+}
+
+type RoomSettingsResponse struct {
+	RoomID        uint   `json:"room_id"               validate:"required" example:"1"`
+	Notifications string `json:"notifications"         validate:"required" example:"default"`
+	MutedUntil    string `json:"muted_until,omitempty"                     example:"1970-01-01T00:00:00Z"`
+}
+
+func NewRoomSettingsResponse(settings *models.RoomSettings) RoomSettingsResponse {
+	response := RoomSettingsResponse{
+		RoomID:        settings.RoomID,
+		Notifications: string(settings.Notifications),
+	}
+
+	if settings.Muted(time.Now()) {
+		response.MutedUntil = settings.MutedUntil.Format(time.RFC3339)
+	}
+
+	return response
+}
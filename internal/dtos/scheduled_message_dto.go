@@ -0,0 +1,43 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type ScheduleMessageRequest struct {
+	Content string `json:"content" validate:"required" example:"Happy birthday!"`
+	SendAt  string `json:"send_at" validate:"required" example:"1970-01-01T00:00:00Z"`
+}
+
+// UpdateScheduledMessageRequest edits a scheduled message. Omitted fields are
+// left unchanged, and a message that failed to send is scheduled again.
+type UpdateScheduledMessageRequest struct {
+	Content *string `json:"content,omitempty" example:"Happy birthday!"`
+	SendAt  *string `json:"send_at,omitempty" example:"1970-01-01T00:00:00Z"`
+}
+
+// ScheduledMessageResponse is a message waiting to be sent. Those that failed
+// to send have a LastError saying why.
+type ScheduledMessageResponse struct {
+	ID        uint   `json:"id"                   validate:"required" example:"1"`
+	RoomID    uint   `json:"room_id"              validate:"required" example:"1"`
+	Content   string `json:"content"              validate:"required" example:"Happy birthday!"`
+	SendAt    string `json:"send_at"              validate:"required" example:"1970-01-01T00:00:00Z"`
+	Status    string `json:"status"               validate:"required" example:"pending"`
+	LastError string `json:"last_error,omitempty"                     example:"sender is no longer in the chat room"`
+	CreatedAt string `json:"created_at"           validate:"required" example:"1970-01-01T00:00:00Z"`
+}
+
+func NewScheduledMessageResponse(message *models.ScheduledMessage) ScheduledMessageResponse {
+	return ScheduledMessageResponse{
+		ID:        message.ID,
+		RoomID:    message.RoomID,
+		Content:   message.Content,
+		SendAt:    message.SendAt.Format(time.RFC3339),
+		Status:    string(message.Status),
+		LastError: message.LastError,
+		CreatedAt: message.CreatedAt.Format(time.RFC3339),
+	}
+}
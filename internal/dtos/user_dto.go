@@ -1,15 +1,63 @@
 package dtos
 
+import "github.com/teamyapchat/yapchat-server/internal/models"
+
+// UpdateUserRequest holds the fields synced from Clerk.
 type UpdateUserRequest struct {
 	Username string `json:"username,omitempty"  example:"john_doe"`
 	ImageURL string `json:"image_url,omitempty" example:"https://example.com/profile_picture.jpg"`
-	Status   string `json:"status,omitempty"    example:"online"`
+	Email    string `json:"email,omitempty"     example:"john@example.com"`
+}
+
+// UpdateProfileRequest holds the fields users edit themselves. Omitted fields
+// are left unchanged and empty strings clear a field.
+type UpdateProfileRequest struct {
+	DisplayName          *string `json:"display_name,omitempty"          example:"John Doe"`
+	Bio                  *string `json:"bio,omitempty"                   example:"Just here to yap"`
+	Pronouns             *string `json:"pronouns,omitempty"              example:"they/them"`
+	BannerURL            *string `json:"banner_url,omitempty"            example:"https://example.com/banner.jpg"`
+	AccentColor          *string `json:"accent_color,omitempty"          example:"#5865F2"`
+	Discoverable         *bool   `json:"discoverable,omitempty"          example:"true"`
+	DefaultNotifications *string `json:"default_notifications,omitempty" example:"all"`
+	DigestFrequency      *string `json:"digest_frequency,omitempty"      example:"daily"`
 }
 
 type UserResponse struct {
-	ID        string `json:"id"                   validate:"required" example:"123"`
-	Username  string `json:"username"             validate:"required" example:"john_doe"`
-	ImageURL  string `json:"image_url,omitempty"                      example:"https://example.com/profile_picture.jpg"`
-	IsOnline  bool   `json:"is_online"            validate:"required" example:"true"`
-	CreatedAt string `json:"created_at,omitempty"                     example:"1970-01-01T00:00:00Z"`
+	ID                   string            `json:"id"                              validate:"required" example:"123"`
+	Username             string            `json:"username"                        validate:"required" example:"john_doe"`
+	DisplayName          string            `json:"display_name,omitempty"                              example:"John Doe"`
+	Bot                  bool              `json:"bot,omitempty"                                       example:"false"`
+	ImageURL             string            `json:"image_url,omitempty"                                 example:"https://example.com/profile_picture.jpg"`
+	Bio                  string            `json:"bio,omitempty"                                       example:"Just here to yap"`
+	Pronouns             string            `json:"pronouns,omitempty"                                  example:"they/them"`
+	BannerURL            string            `json:"banner_url,omitempty"                                example:"https://example.com/banner.jpg"`
+	AccentColor          string            `json:"accent_color,omitempty"                              example:"#5865F2"`
+	Discoverable         *bool             `json:"discoverable,omitempty"                              example:"true"`
+	DefaultNotifications string            `json:"default_notifications,omitempty"                     example:"all"`
+	DigestFrequency      string            `json:"digest_frequency,omitempty"                          example:"daily"`
+	Presence             *PresenceResponse `json:"presence,omitempty"`
+	CreatedAt            string            `json:"created_at,omitempty"                                example:"1970-01-01T00:00:00Z"`
+}
+
+func NewUserResponse(user *models.User, presence *models.Presence) UserResponse {
+	response := UserResponse{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bot:         user.Bot,
+		ImageURL:    user.ImageURL,
+		Bio:         user.Bio,
+		Pronouns:    user.Pronouns,
+		BannerURL:   user.BannerURL,
+		AccentColor: user.AccentColor,
+	}
+
+	// Presence is left out of profile events, which are sent separately
+	if presence != nil {
+		presenceResponse := NewPresenceResponse(presence)
+		presenceResponse.UserID = ""
+		response.Presence = &presenceResponse
+	}
+
+	return response
 }
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/teamyapchat/yapchat-server/internal/services"
+	"github.com/teamyapchat/yapchat-server/internal/storage"
+	"github.com/teamyapchat/yapchat-server/internal/utils"
+)
+
+// Leeway on top of the file size limit for the rest of the multipart body
+const multipartOverhead = 1 << 20
+
+type AttachmentHandler struct {
+	attachmentService *services.AttachmentService
+	chatroomService   *services.ChatRoomService
+}
+
+func NewAttachmentHandler(
+	attachmentService *services.AttachmentService,
+	chatroomService *services.ChatRoomService,
+) *AttachmentHandler {
+	return &AttachmentHandler{
+		attachmentService: attachmentService,
+		chatroomService:   chatroomService,
+	}
+}
+
+// UploadHandler godoc
+//
+//	@Summary		Upload an attachment
+//	@Description	Upload a file to a chat room. Send its ID in the attachment_ids of a dispatch to attach it to a message.
+//	@Tags			attachments
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		integer	true	"Chat room ID"
+//	@Param			file			formData	file	true	"File to upload"
+//	@Param			voice			formData	boolean	false	"Upload as a voice message (Opus in OGG or WebM)"
+//	@Success		201				{object}	utils.SuccessResponse{data=dtos.AttachmentResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		413				{object}	utils.ErrorResponse
+//	@Failure		415				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/attachments [post]
+func (h *AttachmentHandler) UploadHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+	chatroomID := uint(idUint64)
+
+	isParticipant, err := h.chatroomService.IsParticipant(chatroomID, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get chat room"))
+		return
+	}
+	if !isParticipant {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(
+		c.Writer,
+		c.Request.Body,
+		h.attachmentService.MaxSize()+multipartOverhead,
+	)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, utils.NewErrorResponse("File is too large"))
+		} else {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("File is required"))
+		}
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Failed to read file"))
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.attachmentService.Upload(
+		c.Request.Context(),
+		userID.(string),
+		chatroomID,
+		fileHeader.Filename,
+		file,
+		fileHeader.Size,
+		c.PostForm("voice") == "true",
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrAttachmentTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, utils.NewErrorResponse("File is too large"))
+		} else if errors.Is(err, services.ErrUnsupportedMediaType) {
+			c.JSON(http.StatusUnsupportedMediaType, utils.NewErrorResponse("Unsupported file type"))
+		} else if errors.Is(err, services.ErrInvalidImage) ||
+			errors.Is(err, services.ErrInvalidVoiceMessage) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else {
+			log.Error("Failed to upload attachment", "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to upload file"))
+		}
+		return
+	}
+
+	c.JSON(
+		http.StatusCreated,
+		utils.NewSuccessResponse(h.attachmentService.ToResponse(attachment)),
+	)
+}
+
+// GetByIDHandler godoc
+//
+//	@Summary		Get attachment by ID
+//	@Description	Get an attachment along with a freshly signed download URL
+//	@Tags			attachments
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		integer	true	"Attachment ID"
+//	@Success		200				{object}	utils.SuccessResponse{data=dtos.AttachmentResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/attachments/{id} [get]
+func (h *AttachmentHandler) GetByIDHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid attachment ID"))
+		return
+	}
+
+	attachment, err := h.attachmentService.GetForUser(uint(idUint64), userID.(string))
+	if err != nil {
+		if errors.Is(err, services.ErrAttachmentNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Attachment not found"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get attachment"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(h.attachmentService.ToResponse(attachment)))
+}
+
+// DownloadHandler godoc
+//
+//	@Summary		Download an attachment
+//	@Description	Download an attachment using a signed URL from an attachment response
+//	@Tags			attachments
+//	@Produce		octet-stream
+//	@Param			id			path		integer	true	"Attachment ID"
+//	@Param			expires		query		integer	true	"Expiry of the signed URL"
+//	@Param			signature	query		string	true	"Signature of the signed URL"
+//	@Param			thumbnail	query		integer	false	"Size of the thumbnail to download instead of the original"
+//	@Success		200			{file}		file
+//	@Failure		400			{object}	utils.ErrorResponse
+//	@Failure		403			{object}	utils.ErrorResponse
+//	@Failure		404			{object}	utils.ErrorResponse
+//	@Failure		500			{object}	utils.ErrorResponse
+//	@Router			/attachments/{id}/download [get]
+func (h *AttachmentHandler) DownloadHandler(c *gin.Context) {
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid attachment ID"))
+		return
+	}
+
+	content, err := h.attachmentService.Open(
+		c.Request.Context(),
+		uint(idUint64),
+		c.Query("thumbnail"),
+		c.Query("expires"),
+		c.Query("signature"),
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSignature) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("Invalid or expired link"))
+		} else if errors.Is(err, services.ErrAttachmentNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Attachment not found"))
+		} else {
+			log.Error("Failed to open attachment", "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get attachment"))
+		}
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(
+		http.StatusOK,
+		content.Size,
+		content.ContentType,
+		content,
+		map[string]string{
+			"Content-Disposition":    storage.ContentDisposition(content.Filename),
+			"X-Content-Type-Options": "nosniff",
+			"Cache-Control":          "private, max-age=600",
+		},
+	)
+}
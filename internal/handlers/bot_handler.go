@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/services"
+	"github.com/teamyapchat/yapchat-server/internal/utils"
+)
+
+type BotHandler struct {
+	botService *services.BotService
+}
+
+func NewBotHandler(botService *services.BotService) *BotHandler {
+	return &BotHandler{botService: botService}
+}
+
+// ListBotsHandler godoc
+//
+//	@Summary		List bots
+//	@Description	List the bots the user owns
+//	@Tags			bots
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Success		200				{object}	utils.SuccessResponse{data=[]dtos.UserResponse}
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/bots [get]
+func (h *BotHandler) ListBotsHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	bots, err := h.botService.List(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get bots"))
+		return
+	}
+
+	response := make([]dtos.UserResponse, len(bots))
+	for i, bot := range bots {
+		response[i] = dtos.NewUserResponse(bot, nil)
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(response))
+}
+
+// CreateBotHandler godoc
+//
+//	@Summary		Create a bot
+//	@Description	Create a bot account owned by the user
+//	@Tags			bots
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string					true	"Bearer token"
+//	@Param			request			body		dtos.CreateBotRequest	true	"Bot info"
+//	@Success		201				{object}	utils.SuccessResponse{data=dtos.UserResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		409				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/bots [post]
+func (h *BotHandler) CreateBotHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	var botRequest dtos.CreateBotRequest
+	if err := c.ShouldBindJSON(&botRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	bot, err := h.botService.Create(userID.(string), botRequest)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidBot) || errors.Is(err, services.ErrInvalidProfile) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else if errors.Is(err, services.ErrUsernameTaken) {
+			c.JSON(http.StatusConflict, utils.NewErrorResponse("Username is already taken"))
+		} else if errors.Is(err, services.ErrBotLimitReached) {
+			c.JSON(http.StatusConflict, utils.NewErrorResponse("Too many bots"))
+		} else {
+			log.Error("Failed to create bot", "userID", userID, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to create bot"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.NewSuccessResponse(dtos.NewUserResponse(bot, nil)))
+}
+
+// DeleteBotHandler godoc
+//
+//	@Summary		Delete a bot
+//	@Description	Delete a bot the user owns, revoking all of its tokens
+//	@Tags			bots
+//	@Produce		json
+//	@Param			Authorization	header	string	true	"Bearer token"
+//	@Param			id				path	string	true	"Bot ID"
+//	@Success		204
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		404	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/bots/{id} [delete]
+func (h *BotHandler) DeleteBotHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	if err := h.botService.Delete(userID.(string), c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrBotNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Bot not found"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to delete bot"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListTokensHandler godoc
+//
+//	@Summary		List bot tokens
+//	@Description	List a bot's tokens. The tokens themselves are only shown when they're issued.
+//	@Tags			bots
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		string	true	"Bot ID"
+//	@Success		200				{object}	utils.SuccessResponse{data=[]dtos.BotTokenResponse}
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/bots/{id}/tokens [get]
+func (h *BotHandler) ListTokensHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	tokens, err := h.botService.ListTokens(userID.(string), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrBotNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Bot not found"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get bot tokens"))
+		}
+		return
+	}
+
+	response := make([]dtos.BotTokenResponse, len(tokens))
+	for i := range tokens {
+		response[i] = dtos.NewBotTokenResponse(&tokens[i])
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(response))
+}
+
+// CreateTokenHandler godoc
+//
+//	@Summary		Create a bot token
+//	@Description	Issue a token for a bot to authenticate with as "Bot <token>"
+//	@Tags			bots
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string						true	"Bearer token"
+//	@Param			id				path		string						true	"Bot ID"
+//	@Param			request			body		dtos.CreateBotTokenRequest	true	"Token name and scopes"
+//	@Success		201				{object}	utils.SuccessResponse{data=dtos.IssuedBotTokenResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		409				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/bots/{id}/tokens [post]
+func (h *BotHandler) CreateTokenHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	var tokenRequest dtos.CreateBotTokenRequest
+	if err := c.ShouldBindJSON(&tokenRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	token, secret, err := h.botService.CreateToken(userID.(string), c.Param("id"), tokenRequest)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidBot) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else if errors.Is(err, services.ErrBotNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Bot not found"))
+		} else if errors.Is(err, services.ErrBotLimitReached) {
+			c.JSON(http.StatusConflict, utils.NewErrorResponse("Too many tokens for this bot"))
+		} else {
+			log.Error("Failed to create bot token", "userID", userID, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to create bot token"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.NewSuccessResponse(dtos.IssuedBotTokenResponse{
+		Token:    secret,
+		BotToken: dtos.NewBotTokenResponse(token),
+	}))
+}
+
+// RotateTokenHandler godoc
+//
+//	@Summary		Rotate a bot token
+//	@Description	Replace a bot token with a new one with the same scopes. The old token stops working immediately.
+//	@Tags			bots
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		string	true	"Bot ID"
+//	@Param			token_id		path		integer	true	"Token ID"
+//	@Success		200				{object}	utils.SuccessResponse{data=dtos.IssuedBotTokenResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/bots/{id}/tokens/{token_id}/rotate [post]
+func (h *BotHandler) RotateTokenHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	tokenIDUint64, err := strconv.ParseUint(c.Param("token_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid token ID"))
+		return
+	}
+
+	token, secret, err := h.botService.RotateToken(
+		userID.(string),
+		c.Param("id"),
+		uint(tokenIDUint64),
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrBotNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Bot not found"))
+		} else if errors.Is(err, services.ErrBotTokenNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Token not found"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to rotate bot token"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(dtos.IssuedBotTokenResponse{
+		Token:    secret,
+		BotToken: dtos.NewBotTokenResponse(token),
+	}))
+}
+
+// RevokeTokenHandler godoc
+//
+//	@Summary		Revoke a bot token
+//	@Description	Delete a bot token so it can no longer be used
+//	@Tags			bots
+//	@Produce		json
+//	@Param			Authorization	header	string	true	"Bearer token"
+//	@Param			id				path	string	true	"Bot ID"
+//	@Param			token_id		path	integer	true	"Token ID"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		404	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/bots/{id}/tokens/{token_id} [delete]
+func (h *BotHandler) RevokeTokenHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	tokenIDUint64, err := strconv.ParseUint(c.Param("token_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid token ID"))
+		return
+	}
+
+	err = h.botService.RevokeToken(userID.(string), c.Param("id"), uint(tokenIDUint64))
+	if err != nil {
+		if errors.Is(err, services.ErrBotNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Bot not found"))
+		} else if errors.Is(err, services.ErrBotTokenNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Token not found"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to revoke bot token"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListCommandsHandler godoc
+//
+//	@Summary		List bot commands
+//	@Description	List the slash commands a bot has registered. Bots can manage their own commands with the commands:write scope.
+//	@Tags			bots
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		string	true	"Bot ID"
+//	@Success		200				{object}	utils.SuccessResponse{data=[]dtos.CommandResponse}
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/bots/{id}/commands [get]
+func (h *BotHandler) ListCommandsHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	commands, err := h.botService.ListCommands(userID.(string), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrBotNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Bot not found"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get bot commands"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(newCommandResponses(commands)))
+}
+
+// SetCommandsHandler godoc
+//
+//	@Summary		Set bot commands
+//	@Description	Replace all of a bot's slash commands. Anyone in a chat room with the bot can invoke them, and the bot receives a COMMAND_INVOKE event over the gateway when they do.
+//	@Tags			bots
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string						true	"Bearer token"
+//	@Param			id				path		string						true	"Bot ID"
+//	@Param			request			body		dtos.SetBotCommandsRequest	true	"Commands"
+//	@Success		200				{object}	utils.SuccessResponse{data=[]dtos.CommandResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/bots/{id}/commands [put]
+func (h *BotHandler) SetCommandsHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	var commandsRequest dtos.SetBotCommandsRequest
+	if err := c.ShouldBindJSON(&commandsRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	commands, err := h.botService.SetCommands(userID.(string), c.Param("id"), commandsRequest)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidBot) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else if errors.Is(err, services.ErrBotNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Bot not found"))
+		} else {
+			log.Error("Failed to set bot commands", "botID", c.Param("id"), "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to set bot commands"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(newCommandResponses(commands)))
+}
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
 
 	"github.com/teamyapchat/yapchat-server/internal/dtos"
@@ -17,17 +18,26 @@ import (
 )
 
 type ChatRoomHandler struct {
-	chatroomService *services.ChatRoomService
-	messageService  *services.MessageService
+	chatroomService     *services.ChatRoomService
+	messageService      *services.MessageService
+	presenceService     *services.PresenceService
+	attachmentService   *services.AttachmentService
+	notificationService *services.NotificationService
 }
 
 func NewChatRoomHandler(
 	chatroomService *services.ChatRoomService,
 	messageService *services.MessageService,
+	presenceService *services.PresenceService,
+	attachmentService *services.AttachmentService,
+	notificationService *services.NotificationService,
 ) *ChatRoomHandler {
 	return &ChatRoomHandler{
-		chatroomService: chatroomService,
-		messageService:  messageService,
+		chatroomService:     chatroomService,
+		messageService:      messageService,
+		presenceService:     presenceService,
+		attachmentService:   attachmentService,
+		notificationService: notificationService,
 	}
 }
 
@@ -61,7 +71,7 @@ func (h *ChatRoomHandler) CreateHandler(c *gin.Context) {
 
 	chatroomRequest.ParticipantIDs = append(chatroomRequest.ParticipantIDs, userID.(string))
 
-	if err := h.chatroomService.Create(&chatroomRequest); err != nil {
+	if err := h.chatroomService.Create(userID.(string), &chatroomRequest); err != nil {
 		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to create chat room"))
 		return
 	}
@@ -116,7 +126,7 @@ func (h *ChatRoomHandler) GetByIDHandler(c *gin.Context) {
 
 	inviteCode := c.Query("code")
 	if inviteCode != "" {
-		inviteRoom, err := h.chatroomService.GetByInviteCode(inviteCode)
+		inviteRoom, _, err := h.chatroomService.GetByInviteCode(inviteCode)
 		if err != nil {
 			if errors.Is(err, services.ErrChatRoomNotFound) {
 				c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
@@ -143,8 +153,10 @@ func (h *ChatRoomHandler) GetByIDHandler(c *gin.Context) {
 	response := dtos.ChatRoomResponse{
 		ID:           chatroom.ID,
 		Name:         chatroom.Name,
+		Topic:        chatroom.Topic,
 		Type:         string(chatroom.Type),
-		Participants: getParticipants(chatroom.Participants),
+		OwnerID:      chatroom.OwnerID,
+		Participants: h.getParticipants(chatroom.Participants),
 		ImageURL:     chatroom.ImageURL,
 	}
 
@@ -247,11 +259,9 @@ func (h *ChatRoomHandler) GetMessagesByRoomIDHandler(c *gin.Context) {
 
 	messageList := make([]dtos.MessageResponse, 0, len(messages))
 	for _, message := range messages {
-		messageList = append(messageList, dtos.MessageResponse{
-			Content:   message.Content,
-			SenderID:  message.SenderID,
-			Timestamp: message.Timestamp.Format(time.RFC3339),
-		})
+		response := dtos.NewMessageResponse(&message)
+		response.Attachments = h.attachmentService.ToResponses(message.Attachments)
+		messageList = append(messageList, response)
 	}
 
 	pagination := utils.Pagination{
@@ -290,22 +300,569 @@ func (h *ChatRoomHandler) ListChatroomsHandler(c *gin.Context) {
 		return
 	}
 
+	roomSettings, err := h.notificationService.ListRoomSettings(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to list chat rooms"))
+		return
+	}
+
 	var responses []dtos.ChatRoomResponse
 	for _, chatroom := range chatrooms {
-		participants := getParticipants(chatroom.Participants)
+		participants := h.getParticipants(chatroom.Participants)
+
+		settings, ok := roomSettings[chatroom.ID]
+		if !ok {
+			settings = &models.RoomSettings{
+				RoomID:        chatroom.ID,
+				Notifications: models.NotifyDefault,
+			}
+		}
+		settingsResponse := dtos.NewRoomSettingsResponse(settings)
 
 		responses = append(responses, dtos.ChatRoomResponse{
 			ID:           chatroom.ID,
 			Name:         chatroom.Name,
+			Topic:        chatroom.Topic,
 			Type:         string(chatroom.Type),
+			OwnerID:      chatroom.OwnerID,
 			Participants: participants,
 			ImageURL:     chatroom.ImageURL,
+			Settings:     &settingsResponse,
 		})
 	}
 
 	c.JSON(http.StatusOK, utils.NewSuccessResponse(responses))
 }
 
+// UpdateIconHandler godoc
+//
+//	@Summary		Update chat room icon
+//	@Description	Upload a new icon for a chat room. The image is re-encoded without metadata and scaled down to fit 512x512.
+//	@Tags			chatrooms
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		integer	true	"Chat room ID"
+//	@Param			image			formData	file	true	"Icon image (PNG, JPEG, GIF or WebP)"
+//	@Success		200				{object}	utils.SuccessResponse{data=dtos.ChatRoomResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		413				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/icon [put]
+func (h *ChatRoomHandler) UpdateIconHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	file, size, ok := formImage(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	chatroom, err := h.chatroomService.UpdateIcon(
+		c.Request.Context(),
+		uint(idUint64),
+		userID.(string),
+		file,
+		size,
+	)
+	if err != nil {
+		if writeImageError(c, err) {
+			return
+		}
+
+		if errors.Is(err, services.ErrChatRoomNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
+		} else if errors.Is(err, services.ErrNotParticipant) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+		} else {
+			log.Error("Failed to update chat room icon", "chatroomID", idUint64, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to update icon"))
+		}
+		return
+	}
+
+	response := dtos.ChatRoomResponse{
+		ID:           chatroom.ID,
+		Name:         chatroom.Name,
+		Topic:        chatroom.Topic,
+		Type:         string(chatroom.Type),
+		OwnerID:      chatroom.OwnerID,
+		Participants: h.getParticipants(chatroom.Participants),
+		ImageURL:     chatroom.ImageURL,
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(response))
+}
+
+// RenameHandler godoc
+//
+//	@Summary		Rename chat room
+//	@Description	Change a group's name. Any participant can rename it.
+//	@Tags			chatrooms
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string						true	"Bearer token"
+//	@Param			id				path		integer						true	"Chat room ID"
+//	@Param			request			body		dtos.UpdateChatRoomRequest	true	"New name"
+//	@Success		200				{object}	utils.SuccessResponse{data=dtos.ChatRoomResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id} [patch]
+func (h *ChatRoomHandler) RenameHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	var updateRequest dtos.UpdateChatRoomRequest
+	if err := c.ShouldBindJSON(&updateRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	chatroom, err := h.chatroomService.Rename(uint(idUint64), userID.(string), updateRequest.Name)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidChatRoom) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else if errors.Is(err, services.ErrNotGroup) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Only groups can be renamed"))
+		} else if errors.Is(err, services.ErrChatRoomNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
+		} else if errors.Is(err, services.ErrNotParticipant) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+		} else {
+			log.Error("Failed to rename chat room", "chatroomID", idUint64, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to rename chat room"))
+		}
+		return
+	}
+
+	response := dtos.ChatRoomResponse{
+		ID:           chatroom.ID,
+		Name:         chatroom.Name,
+		Topic:        chatroom.Topic,
+		Type:         string(chatroom.Type),
+		OwnerID:      chatroom.OwnerID,
+		Participants: h.getParticipants(chatroom.Participants),
+		ImageURL:     chatroom.ImageURL,
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(response))
+}
+
+// GetSettingsHandler godoc
+//
+//	@Summary		Get chat room settings
+//	@Description	Get the user's notification settings for a chat room
+//	@Tags			chatrooms
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		integer	true	"Chat room ID"
+//	@Success		200				{object}	utils.SuccessResponse{data=dtos.RoomSettingsResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/settings [get]
+func (h *ChatRoomHandler) GetSettingsHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	settings, err := h.notificationService.GetRoomSettings(userID.(string), uint(idUint64))
+	if err != nil {
+		if errors.Is(err, services.ErrChatRoomNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
+		} else if errors.Is(err, services.ErrNotParticipant) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get settings"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(dtos.NewRoomSettingsResponse(settings)))
+}
+
+// UpdateSettingsHandler godoc
+//
+//	@Summary		Update chat room settings
+//	@Description	Update the user's notification settings for a chat room
+//	@Tags			chatrooms
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string						true	"Bearer token"
+//	@Param			id				path		integer						true	"Chat room ID"
+//	@Param			request			body		dtos.RoomSettingsRequest	true	"Settings to change"
+//	@Success		200				{object}	utils.SuccessResponse{data=dtos.RoomSettingsResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/settings [patch]
+func (h *ChatRoomHandler) UpdateSettingsHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	var settingsRequest dtos.RoomSettingsRequest
+	if err := c.ShouldBindJSON(&settingsRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	settings, err := h.notificationService.UpdateRoomSettings(
+		userID.(string),
+		uint(idUint64),
+		settingsRequest,
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSettings) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else if errors.Is(err, services.ErrChatRoomNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
+		} else if errors.Is(err, services.ErrNotParticipant) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+		} else {
+			log.Error("Failed to update chat room settings", "chatroomID", idUint64, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to update settings"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(dtos.NewRoomSettingsResponse(settings)))
+}
+
+// MarkReadHandler godoc
+//
+//	@Summary		Mark chat room as read
+//	@Description	Move the user's read marker in a chat room up to a message
+//	@Tags			chatrooms
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header	string						true	"Bearer token"
+//	@Param			id				path	integer						true	"Chat room ID"
+//	@Param			request			body	dtos.MarkRoomReadRequest	true	"Last message read"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		403	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/read [post]
+func (h *ChatRoomHandler) MarkReadHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	var readRequest dtos.MarkRoomReadRequest
+	if err := c.ShouldBindJSON(&readRequest); err != nil || readRequest.MessageID == 0 {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	err = h.chatroomService.MarkRead(uint(idUint64), userID.(string), readRequest.MessageID)
+	if err != nil {
+		if errors.Is(err, services.ErrNotParticipant) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+		} else {
+			log.Error("Failed to mark chat room as read", "chatroomID", idUint64, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to mark as read"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SendEphemeralMessageHandler godoc
+//
+//	@Summary		Send an ephemeral message
+//	@Description	Send a message that only one user in the chat room sees, and that isn't stored. It's delivered as an EPHEMERAL_MESSAGE event, to just the given session if there is one, such as the one a command was invoked from. Only bots can send ephemeral messages.
+//	@Tags			chatrooms
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header	string							true	"Bearer token"
+//	@Param			id				path	integer							true	"Chat room ID"
+//	@Param			request			body	dtos.EphemeralMessageRequest	true	"Recipient and message"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		403	{object}	utils.ErrorResponse
+//	@Failure		404	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/ephemeral-messages [post]
+func (h *ChatRoomHandler) SendEphemeralMessageHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	var messageRequest dtos.EphemeralMessageRequest
+	if err := c.ShouldBindJSON(&messageRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	err = h.messageService.SendEphemeralAs(uint(idUint64), userID.(string), messageRequest)
+	if err != nil {
+		if errors.Is(err, services.ErrChatRoomNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
+		} else if errors.Is(err, services.ErrNotParticipant) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+		} else if errors.Is(err, services.ErrNotBot) {
+			c.JSON(
+				http.StatusForbidden,
+				utils.NewErrorResponse("Only bots can send ephemeral messages"),
+			)
+		} else if errors.Is(err, services.ErrInvalidMessage) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else {
+			log.Error("Failed to send ephemeral message", "userID", userID, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to send message"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListPinsHandler godoc
+//
+//	@Summary		List pinned messages
+//	@Description	Get the messages pinned in a chat room, most recently pinned first
+//	@Tags			chatrooms
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		integer	true	"Chat room ID"
+//	@Success		200				{object}	utils.SuccessResponse{data=[]dtos.PinResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/pins [get]
+func (h *ChatRoomHandler) ListPinsHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	pins, err := h.chatroomService.ListPins(uint(idUint64), userID.(string))
+	if err != nil {
+		if errors.Is(err, services.ErrChatRoomNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
+		} else if errors.Is(err, services.ErrNotParticipant) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get pins"))
+		}
+		return
+	}
+
+	responses := make([]dtos.PinResponse, 0, len(pins))
+	for _, pin := range pins {
+		message := dtos.NewMessageResponse(&pin)
+		message.Attachments = h.attachmentService.ToResponses(pin.Attachments)
+		responses = append(responses, dtos.PinResponse{
+			Message:    message,
+			PinnedByID: pin.PinnedByID,
+			PinnedAt:   pin.PinnedAt.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(responses))
+}
+
+// PinHandler godoc
+//
+//	@Summary		Pin a message
+//	@Description	Pin a message to its chat room. Only moderators can pin messages in groups.
+//	@Tags			chatrooms
+//	@Produce		json
+//	@Param			Authorization	header	string	true	"Bearer token"
+//	@Param			id				path	integer	true	"Chat room ID"
+//	@Param			message_id		path	integer	true	"Message ID"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		403	{object}	utils.ErrorResponse
+//	@Failure		404	{object}	utils.ErrorResponse
+//	@Failure		409	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/pins/{message_id} [put]
+func (h *ChatRoomHandler) PinHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idUint64, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+	messageIDUint64, err := strconv.ParseUint(c.Param("message_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid message ID"))
+		return
+	}
+
+	err = h.chatroomService.Pin(uint(idUint64), uint(messageIDUint64), userID.(string))
+	if err != nil {
+		if errors.Is(err, services.ErrChatRoomNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
+		} else if errors.Is(err, services.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Message not found"))
+		} else if errors.Is(err, services.ErrNotParticipant) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+		} else if errors.Is(err, services.ErrCannotPin) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("Only moderators can pin messages"))
+		} else if errors.Is(err, services.ErrPinLimitReached) {
+			c.JSON(http.StatusConflict, utils.NewErrorResponse(err.Error()))
+		} else {
+			log.Error("Failed to pin message", "messageID", messageIDUint64, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to pin message"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UnpinHandler godoc
+//
+//	@Summary		Unpin a message
+//	@Description	Unpin a message from its chat room. Only moderators can unpin messages in groups.
+//	@Tags			chatrooms
+//	@Produce		json
+//	@Param			Authorization	header	string	true	"Bearer token"
+//	@Param			id				path	integer	true	"Chat room ID"
+//	@Param			message_id		path	integer	true	"Message ID"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		403	{object}	utils.ErrorResponse
+//	@Failure		404	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/pins/{message_id} [delete]
+func (h *ChatRoomHandler) UnpinHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idUint64, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+	messageIDUint64, err := strconv.ParseUint(c.Param("message_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid message ID"))
+		return
+	}
+
+	err = h.chatroomService.Unpin(uint(idUint64), uint(messageIDUint64), userID.(string))
+	if err != nil {
+		if errors.Is(err, services.ErrChatRoomNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
+		} else if errors.Is(err, services.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Message not found"))
+		} else if errors.Is(err, services.ErrNotParticipant) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+		} else if errors.Is(err, services.ErrCannotPin) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("Only moderators can unpin messages"))
+		} else {
+			log.Error("Failed to unpin message", "messageID", messageIDUint64, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to unpin message"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
 // GetInviteCodeHandler godoc
 //
 //	@Summary		Get an invite code for a chat room
@@ -352,7 +909,7 @@ func (h *ChatRoomHandler) GetInviteCodeHandler(c *gin.Context) {
 		return
 	}
 
-	inviteCode, err := h.chatroomService.CreateInviteCode(chatroomID)
+	inviteCode, err := h.chatroomService.CreateInviteCode(chatroomID, userID.(string))
 	if err != nil {
 		c.JSON(
 			http.StatusInternalServerError,
@@ -401,7 +958,7 @@ func (h *ChatRoomHandler) JoinChatroomHandler(c *gin.Context) {
 		return
 	}
 
-	chatroom, err := h.chatroomService.GetByInviteCode(inviteCode)
+	chatroom, inviterID, err := h.chatroomService.GetByInviteCode(inviteCode)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid invite code"))
 		return
@@ -417,7 +974,7 @@ func (h *ChatRoomHandler) JoinChatroomHandler(c *gin.Context) {
 		return
 	}
 
-	err = h.chatroomService.AddParticipant(chatroomID, userID.(string))
+	err = h.chatroomService.Join(chatroomID, userID.(string), inviterID)
 	if err != nil {
 		if errors.Is(err, services.ErrChatRoomNotFound) {
 			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
@@ -482,7 +1039,7 @@ func (h *ChatRoomHandler) LeaveChatroomHandler(c *gin.Context) {
 		return
 	}
 
-	err = h.chatroomService.RemoveParticipant(chatroomID, userID.(string))
+	err = h.chatroomService.Leave(chatroomID, userID.(string))
 	if err != nil {
 		if errors.Is(err, services.ErrChatRoomNotFound) {
 			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
@@ -495,15 +1052,68 @@ func (h *ChatRoomHandler) LeaveChatroomHandler(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-func getParticipants(participants []*models.User) []dtos.UserResponse {
+// KickHandler godoc
+//
+//	@Summary		Kick a participant
+//	@Description	Remove a participant from a group. Only moderators can kick participants.
+//	@Tags			chatrooms
+//	@Produce		json
+//	@Param			Authorization	header	string	true	"Bearer token"
+//	@Param			id				path	integer	true	"Chat room ID"
+//	@Param			user_id			path	string	true	"ID of the user to kick"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		403	{object}	utils.ErrorResponse
+//	@Failure		404	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/participants/{user_id} [delete]
+func (h *ChatRoomHandler) KickHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	err = h.chatroomService.Kick(uint(idUint64), userID.(string), c.Param("user_id"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidKick) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else if errors.Is(err, services.ErrNotGroup) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Only groups have participants to kick"))
+		} else if errors.Is(err, services.ErrChatRoomNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
+		} else if errors.Is(err, services.ErrNotModerator) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("Only moderators can kick participants"))
+		} else if errors.Is(err, services.ErrNotParticipant) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+		} else {
+			log.Error("Failed to kick participant", "chatroomID", idUint64, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to kick participant"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ChatRoomHandler) getParticipants(participants []*models.User) []dtos.UserResponse {
+	presences, err := h.presenceService.GetVisible(getParticipantIDs(participants)...)
+	if err != nil {
+		log.Error("Failed to get participant presence", "err", err.Error())
+	}
+
 	users := make([]dtos.UserResponse, 0, len(participants))
 	for _, p := range participants {
-		users = append(users, dtos.UserResponse{
-			ID:       p.ID,
-			Username: p.Username,
-			ImageURL: p.ImageURL,
-			IsOnline: p.IsOnline,
-		})
+		users = append(users, dtos.NewUserResponse(p, presences[p.ID]))
 	}
 	return users
 }
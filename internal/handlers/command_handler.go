@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/services"
+	"github.com/teamyapchat/yapchat-server/internal/utils"
+)
+
+type CommandHandler struct {
+	commandService *services.CommandService
+}
+
+func NewCommandHandler(commandService *services.CommandService) *CommandHandler {
+	return &CommandHandler{commandService: commandService}
+}
+
+// ListCommandsHandler godoc
+//
+//	@Summary		List a chat room's commands
+//	@Description	List the slash commands that can be sent in a chat room, built-in ones first, followed by those of the bots in it. Commands are sent as messages starting with a slash, and a doubled slash sends the message as it is.
+//	@Tags			chatrooms
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		integer	true	"Chat room ID"
+//	@Success		200				{object}	utils.SuccessResponse{data=[]dtos.CommandResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/commands [get]
+func (h *CommandHandler) ListCommandsHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	commands, err := h.commandService.List(uint(idUint64), userID.(string))
+	if err != nil {
+		if errors.Is(err, services.ErrChatRoomNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
+		} else if errors.Is(err, services.ErrNotParticipant) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get commands"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(newCommandResponses(commands)))
+}
+
+func newCommandResponses(commands []models.BotCommand) []dtos.CommandResponse {
+	responses := make([]dtos.CommandResponse, len(commands))
+	for i := range commands {
+		responses[i] = dtos.NewCommandResponse(&commands[i])
+	}
+	return responses
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/teamyapchat/yapchat-server/internal/services"
+	"github.com/teamyapchat/yapchat-server/internal/utils"
+)
+
+// unsubscribePage asks for confirmation before unsubscribing, since mail
+// scanners follow the links in emails they deliver.
+const unsubscribePage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe from YapChat digests</title></head>
+<body style="font-family: sans-serif; text-align: center; padding: 48px;">
+<p>Stop getting emails about what you missed on YapChat?</p>
+<form method="post">
+<input type="hidden" name="token" value="%s">
+<button type="submit">Unsubscribe</button>
+</form>
+</body>
+</html>
+`
+
+type DigestHandler struct {
+	digestService *services.DigestService
+}
+
+func NewDigestHandler(digestService *services.DigestService) *DigestHandler {
+	return &DigestHandler{digestService: digestService}
+}
+
+// UnsubscribePageHandler godoc
+//
+//	@Summary		Confirm unsubscribing from email digests
+//	@Description	Show a page confirming the user wants to stop getting email digests
+//	@Tags			digest
+//	@Produce		html
+//	@Param			token	query	string	true	"Unsubscribe token from the digest email"
+//	@Success		200
+//	@Router			/digest/unsubscribe [get]
+func (h *DigestHandler) UnsubscribePageHandler(c *gin.Context) {
+	page := fmt.Sprintf(unsubscribePage, template.HTMLEscapeString(c.Query("token")))
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+}
+
+// UnsubscribeHandler godoc
+//
+//	@Summary		Unsubscribe from email digests
+//	@Description	Stop sending email digests to the user an unsubscribe link was made for
+//	@Tags			digest
+//	@Accept			x-www-form-urlencoded
+//	@Produce		json
+//	@Param			token	query	string	true	"Unsubscribe token from the digest email"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Router			/digest/unsubscribe [post]
+func (h *DigestHandler) UnsubscribeHandler(c *gin.Context) {
+	// One-click unsubscribes (RFC 8058) keep the token in the query string,
+	// the confirmation page sends it in the form
+	token := c.Query("token")
+	if token == "" {
+		token = c.PostForm("token")
+	}
+
+	if err := h.digestService.Unsubscribe(token); err != nil {
+		if errors.Is(err, services.ErrInvalidUnsubscribeToken) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid unsubscribe link"))
+		} else {
+			log.Error("Failed to unsubscribe from digests", "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to unsubscribe"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
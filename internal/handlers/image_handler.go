@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/teamyapchat/yapchat-server/internal/services"
+	"github.com/teamyapchat/yapchat-server/internal/utils"
+)
+
+type ImageHandler struct {
+	imageService *services.ImageService
+}
+
+func NewImageHandler(imageService *services.ImageService) *ImageHandler {
+	return &ImageHandler{imageService: imageService}
+}
+
+// GetImageHandler godoc
+//
+//	@Summary		Get an image
+//	@Description	Get a public image such as an avatar or room icon. Image keys change on every upload, so responses can be cached indefinitely.
+//	@Tags			images
+//	@Produce		image/jpeg,image/png,image/gif
+//	@Param			key	path		string	true	"Image key"
+//	@Success		200	{file}		file
+//	@Failure		404	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Router			/images/{key} [get]
+func (h *ImageHandler) GetImageHandler(c *gin.Context) {
+	blob, contentType, err := h.imageService.Open(
+		c.Request.Context(),
+		"images"+c.Param("key"),
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrImageNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Image not found"))
+		} else {
+			log.Error("Failed to open image", "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get image"))
+		}
+		return
+	}
+	defer blob.Close()
+
+	c.DataFromReader(http.StatusOK, -1, contentType, blob, map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "public, max-age=31536000, immutable",
+	})
+}
+
+// formImage opens the image uploaded in the "image" form field, writing an
+// error response and returning false if there isn't a usable one.
+func formImage(c *gin.Context) (multipart.File, int64, bool) {
+	c.Request.Body = http.MaxBytesReader(
+		c.Writer,
+		c.Request.Body,
+		services.MaxImageUploadSize+multipartOverhead,
+	)
+
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, utils.NewErrorResponse("Image is too large"))
+		} else {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Image is required"))
+		}
+		return nil, 0, false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Failed to read image"))
+		return nil, 0, false
+	}
+
+	return file, fileHeader.Size, true
+}
+
+// writeImageError writes the response for a failed avatar or icon upload,
+// returning false if err wasn't an image error.
+func writeImageError(c *gin.Context, err error) bool {
+	if errors.Is(err, services.ErrImageTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, utils.NewErrorResponse("Image is too large"))
+	} else if errors.Is(err, services.ErrInvalidImage) {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+	} else {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/services"
+	"github.com/teamyapchat/yapchat-server/internal/utils"
+)
+
+type IncomingWebhookHandler struct {
+	webhookService *services.IncomingWebhookService
+}
+
+func NewIncomingWebhookHandler(
+	webhookService *services.IncomingWebhookService,
+) *IncomingWebhookHandler {
+	return &IncomingWebhookHandler{webhookService: webhookService}
+}
+
+// ListHandler godoc
+//
+//	@Summary		List a chat room's webhooks
+//	@Description	List the incoming webhooks of a chat room. Only moderators can manage webhooks.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		integer	true	"Chat room ID"
+//	@Success		200				{object}	utils.SuccessResponse{data=[]dtos.IncomingWebhookResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/webhooks [get]
+func (h *IncomingWebhookHandler) ListHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	webhooks, err := h.webhookService.List(uint(idUint64), userID.(string))
+	if err != nil {
+		if !respondWebhookRoomError(c, err) {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get webhooks"))
+		}
+		return
+	}
+
+	responses := make([]dtos.IncomingWebhookResponse, len(webhooks))
+	for i := range webhooks {
+		responses[i] = dtos.NewIncomingWebhookResponse(&webhooks[i])
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(responses))
+}
+
+// CreateHandler godoc
+//
+//	@Summary		Create a webhook
+//	@Description	Create an incoming webhook that posts into a chat room. Its URL is only returned here.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string								true	"Bearer token"
+//	@Param			id				path		integer								true	"Chat room ID"
+//	@Param			request			body		dtos.CreateIncomingWebhookRequest	true	"Webhook name and avatar"
+//	@Success		201				{object}	utils.SuccessResponse{data=dtos.IncomingWebhookResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		409				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/webhooks [post]
+func (h *IncomingWebhookHandler) CreateHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	var webhookRequest dtos.CreateIncomingWebhookRequest
+	if err := c.ShouldBindJSON(&webhookRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	webhook, url, err := h.webhookService.Create(uint(idUint64), userID.(string), webhookRequest)
+	if err != nil {
+		if respondWebhookRoomError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidWebhook) || errors.Is(err, services.ErrInvalidProfile) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else if errors.Is(err, services.ErrWebhookLimitReached) {
+			c.JSON(http.StatusConflict, utils.NewErrorResponse("Too many webhooks in this chat room"))
+		} else {
+			log.Error("Failed to create webhook", "userID", userID, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to create webhook"))
+		}
+		return
+	}
+
+	response := dtos.NewIncomingWebhookResponse(webhook)
+	response.URL = url
+	c.JSON(http.StatusCreated, utils.NewSuccessResponse(response))
+}
+
+// DeleteHandler godoc
+//
+//	@Summary		Delete a webhook
+//	@Description	Delete an incoming webhook, after which its URL stops working
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			Authorization	header	string	true	"Bearer token"
+//	@Param			id				path	integer	true	"Chat room ID"
+//	@Param			webhook_id		path	integer	true	"Webhook ID"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		403	{object}	utils.ErrorResponse
+//	@Failure		404	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/webhooks/{webhook_id} [delete]
+func (h *IncomingWebhookHandler) DeleteHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+	webhookIDUint64, err := strconv.ParseUint(c.Param("webhook_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid webhook ID"))
+		return
+	}
+
+	err = h.webhookService.Delete(uint(idUint64), userID.(string), uint(webhookIDUint64))
+	if err != nil {
+		if respondWebhookRoomError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Webhook not found"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to delete webhook"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ExecuteHandler godoc
+//
+//	@Summary		Post a message through a webhook
+//	@Description	Post a message to the webhook's chat room. The URL is the webhook's secret, and no other authentication is needed.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path	integer								true	"Webhook ID"
+//	@Param			token	path	string								true	"Webhook token"
+//	@Param			request	body	dtos.ExecuteIncomingWebhookRequest	true	"Message"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		403	{object}	utils.ErrorResponse
+//	@Failure		404	{object}	utils.ErrorResponse
+//	@Failure		429	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Router			/webhooks/{id}/{token} [post]
+func (h *IncomingWebhookHandler) ExecuteHandler(c *gin.Context) {
+	idUint64, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, utils.NewErrorResponse("Webhook not found"))
+		return
+	}
+
+	var executeRequest dtos.ExecuteIncomingWebhookRequest
+	if err := c.ShouldBindJSON(&executeRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	_, err = h.webhookService.Execute(uint(idUint64), c.Param("token"), executeRequest)
+	if err != nil {
+		if errors.Is(err, services.ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Webhook not found"))
+		} else if errors.Is(err, services.ErrWebhookDisabled) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse(err.Error()))
+		} else if errors.Is(err, services.ErrInvalidWebhook) ||
+			errors.Is(err, services.ErrInvalidProfile) ||
+			errors.Is(err, services.ErrInvalidMessage) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else {
+			log.Error("Failed to execute webhook", "webhookID", idUint64, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to send message"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondWebhookRoomError responds to the errors from checking that the user
+// moderates the chat room, reporting whether err was one of them.
+func respondWebhookRoomError(c *gin.Context, err error) bool {
+	if errors.Is(err, services.ErrChatRoomNotFound) {
+		c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
+	} else if errors.Is(err, services.ErrNotParticipant) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+	} else if errors.Is(err, services.ErrNotModerator) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("Only moderators can manage webhooks"))
+	} else {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/services"
+	"github.com/teamyapchat/yapchat-server/internal/utils"
+)
+
+type MentionHandler struct {
+	mentionService *services.MentionService
+}
+
+func NewMentionHandler(mentionService *services.MentionService) *MentionHandler {
+	return &MentionHandler{mentionService: mentionService}
+}
+
+// ListMentionsHandler godoc
+//
+//	@Summary		List mentions
+//	@Description	List the messages the user was mentioned in, newest first
+//	@Tags			mentions
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			unread			query		boolean	false	"Only list unread mentions"
+//	@Param			cursor			query		string	false	"Cursor from the previous page"
+//	@Param			limit			query		integer	false	"Number of mentions per page (default 25)"
+//	@Success		200				{object}	utils.CursorPagination{data=[]dtos.MentionResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/users/me/mentions [get]
+func (h *MentionHandler) ListMentionsHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	var unreadOnly bool
+	if unread := c.Query("unread"); unread != "" {
+		var err error
+		unreadOnly, err = strconv.ParseBool(unread)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid unread filter"))
+			return
+		}
+	}
+
+	var cursor uint
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		cursorID, err := strconv.ParseUint(cursorStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid cursor"))
+			return
+		}
+		cursor = uint(cursorID)
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 25
+	}
+
+	mentions, err := h.mentionService.List(userID.(string), unreadOnly, cursor, limit)
+	if err != nil {
+		log.Error("Failed to list mentions", "userID", userID, "err", err.Error())
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to list mentions"))
+		return
+	}
+
+	results := make([]dtos.MentionResponse, 0, len(mentions))
+	for i := range mentions {
+		results = append(results, dtos.NewMentionResponse(&mentions[i]))
+	}
+
+	pagination := utils.CursorPagination{Data: results}
+	if len(mentions) == limit {
+		pagination.NextCursor = strconv.FormatUint(uint64(mentions[len(mentions)-1].ID), 10)
+	}
+
+	c.JSON(http.StatusOK, pagination)
+}
+
+// MarkReadHandler godoc
+//
+//	@Summary		Mark mention as read
+//	@Description	Mark one of the user's mentions as read
+//	@Tags			mentions
+//	@Produce		json
+//	@Param			Authorization	header	string	true	"Bearer token"
+//	@Param			id				path	integer	true	"Mention ID"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		404	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/users/me/mentions/{id}/read [post]
+func (h *MentionHandler) MarkReadHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid mention ID"))
+		return
+	}
+
+	if err := h.mentionService.MarkRead(userID.(string), uint(idUint64)); err != nil {
+		if errors.Is(err, services.ErrMentionNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Mention not found"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to mark mention as read"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// MarkAllReadHandler godoc
+//
+//	@Summary		Mark all mentions as read
+//	@Description	Mark all of the user's mentions as read, optionally only those in one chat room
+//	@Tags			mentions
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header	string							true	"Bearer token"
+//	@Param			request			body	dtos.MarkMentionsReadRequest	false	"Chat room to mark as read"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/users/me/mentions/read [post]
+func (h *MentionHandler) MarkAllReadHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	var readRequest dtos.MarkMentionsReadRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&readRequest); err != nil {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+			return
+		}
+	}
+
+	if err := h.mentionService.MarkAllRead(userID.(string), readRequest.RoomID); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to mark mentions as read"))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/services"
+	"github.com/teamyapchat/yapchat-server/internal/utils"
+)
+
+type OutgoingWebhookHandler struct {
+	webhookService *services.OutgoingWebhookService
+}
+
+func NewOutgoingWebhookHandler(
+	webhookService *services.OutgoingWebhookService,
+) *OutgoingWebhookHandler {
+	return &OutgoingWebhookHandler{webhookService: webhookService}
+}
+
+// ListRoomWebhooksHandler godoc
+//
+//	@Summary		List a chat room's outgoing webhooks
+//	@Description	List the URLs a chat room's events are sent to. Only moderators can manage them.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		integer	true	"Chat room ID"
+//	@Success		200				{object}	utils.SuccessResponse{data=[]dtos.OutgoingWebhookResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/outgoing-webhooks [get]
+func (h *OutgoingWebhookHandler) ListRoomWebhooksHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	webhooks, err := h.webhookService.ListForRoom(uint(idUint64), userID.(string))
+	if err != nil {
+		if !respondWebhookRoomError(c, err) {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get webhooks"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(newOutgoingWebhookResponses(webhooks)))
+}
+
+// CreateRoomWebhookHandler godoc
+//
+//	@Summary		Create an outgoing webhook for a chat room
+//	@Description	Send a chat room's events to a URL. Requests are signed with the returned secret, which is only returned here.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string								true	"Bearer token"
+//	@Param			id				path		integer								true	"Chat room ID"
+//	@Param			request			body		dtos.CreateOutgoingWebhookRequest	true	"URL and events"
+//	@Success		201				{object}	utils.SuccessResponse{data=dtos.OutgoingWebhookResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		409				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/outgoing-webhooks [post]
+func (h *OutgoingWebhookHandler) CreateRoomWebhookHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	var webhookRequest dtos.CreateOutgoingWebhookRequest
+	if err := c.ShouldBindJSON(&webhookRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	webhook, err := h.webhookService.CreateForRoom(
+		uint(idUint64),
+		userID.(string),
+		webhookRequest,
+	)
+	if err != nil {
+		if !respondWebhookRoomError(c, err) {
+			respondCreateOutgoingWebhookError(c, userID.(string), err)
+		}
+		return
+	}
+
+	response := dtos.NewOutgoingWebhookResponse(webhook)
+	response.Secret = webhook.Secret
+	c.JSON(http.StatusCreated, utils.NewSuccessResponse(response))
+}
+
+// ListBotWebhooksHandler godoc
+//
+//	@Summary		List a bot's outgoing webhooks
+//	@Description	List the URLs that events in every chat room the bot is in are sent to
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		string	true	"Bot ID"
+//	@Success		200				{object}	utils.SuccessResponse{data=[]dtos.OutgoingWebhookResponse}
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/bots/{id}/outgoing-webhooks [get]
+func (h *OutgoingWebhookHandler) ListBotWebhooksHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	webhooks, err := h.webhookService.ListForBot(c.Param("id"), userID.(string))
+	if err != nil {
+		if errors.Is(err, services.ErrBotNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Bot not found"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get webhooks"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(newOutgoingWebhookResponses(webhooks)))
+}
+
+// CreateBotWebhookHandler godoc
+//
+//	@Summary		Create an outgoing webhook for a bot
+//	@Description	Send the events in every chat room the bot is in to a URL, except those caused by the bot itself. Requests are signed with the returned secret, which is only returned here.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string								true	"Bearer token"
+//	@Param			id				path		string								true	"Bot ID"
+//	@Param			request			body		dtos.CreateOutgoingWebhookRequest	true	"URL and events"
+//	@Success		201				{object}	utils.SuccessResponse{data=dtos.OutgoingWebhookResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		409				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/bots/{id}/outgoing-webhooks [post]
+func (h *OutgoingWebhookHandler) CreateBotWebhookHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	var webhookRequest dtos.CreateOutgoingWebhookRequest
+	if err := c.ShouldBindJSON(&webhookRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	webhook, err := h.webhookService.CreateForBot(c.Param("id"), userID.(string), webhookRequest)
+	if err != nil {
+		if errors.Is(err, services.ErrBotNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Bot not found"))
+		} else {
+			respondCreateOutgoingWebhookError(c, userID.(string), err)
+		}
+		return
+	}
+
+	response := dtos.NewOutgoingWebhookResponse(webhook)
+	response.Secret = webhook.Secret
+	c.JSON(http.StatusCreated, utils.NewSuccessResponse(response))
+}
+
+// DeleteHandler godoc
+//
+//	@Summary		Delete an outgoing webhook
+//	@Description	Delete an outgoing webhook along with its deliveries, including any not yet sent
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			Authorization	header	string	true	"Bearer token"
+//	@Param			id				path	integer	true	"Webhook ID"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		403	{object}	utils.ErrorResponse
+//	@Failure		404	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/outgoing-webhooks/{id} [delete]
+func (h *OutgoingWebhookHandler) DeleteHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idUint64, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid webhook ID"))
+		return
+	}
+
+	if err := h.webhookService.Delete(uint(idUint64), userID.(string)); err != nil {
+		if !respondManagedWebhookError(c, err) {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to delete webhook"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveriesHandler godoc
+//
+//	@Summary		List an outgoing webhook's deliveries
+//	@Description	List the latest 100 deliveries of an outgoing webhook. Deliveries are attempted 8 times before they're given up on, and the failed ones make up its dead-letter list.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		integer	true	"Webhook ID"
+//	@Param			status			query		string	false	"Only list deliveries with this status"	Enums(pending, succeeded, failed)
+//	@Success		200				{object}	utils.SuccessResponse{data=[]dtos.WebhookDeliveryResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/outgoing-webhooks/{id}/deliveries [get]
+func (h *OutgoingWebhookHandler) ListDeliveriesHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idUint64, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid webhook ID"))
+		return
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(
+		uint(idUint64),
+		userID.(string),
+		c.Query("status"),
+	)
+	if err != nil {
+		if respondManagedWebhookError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidWebhook) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get deliveries"))
+		}
+		return
+	}
+
+	responses := make([]dtos.WebhookDeliveryResponse, len(deliveries))
+	for i := range deliveries {
+		responses[i] = dtos.NewWebhookDeliveryResponse(&deliveries[i])
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(responses))
+}
+
+// RetryDeliveryHandler godoc
+//
+//	@Summary		Retry a delivery
+//	@Description	Send a delivery again straight away. Failed deliveries get a fresh set of attempts.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		integer	true	"Webhook ID"
+//	@Param			delivery_id		path		integer	true	"Delivery ID"
+//	@Success		200				{object}	utils.SuccessResponse{data=dtos.WebhookDeliveryResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/outgoing-webhooks/{id}/deliveries/{delivery_id}/retry [post]
+func (h *OutgoingWebhookHandler) RetryDeliveryHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idUint64, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid webhook ID"))
+		return
+	}
+	deliveryIDUint64, err := strconv.ParseUint(c.Param("delivery_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid delivery ID"))
+		return
+	}
+
+	delivery, err := h.webhookService.RetryDelivery(
+		uint(idUint64),
+		uint(deliveryIDUint64),
+		userID.(string),
+	)
+	if err != nil {
+		if respondManagedWebhookError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrDeliveryNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("Delivery not found"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to retry delivery"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(dtos.NewWebhookDeliveryResponse(delivery)))
+}
+
+func newOutgoingWebhookResponses(
+	webhooks []models.OutgoingWebhook,
+) []dtos.OutgoingWebhookResponse {
+	responses := make([]dtos.OutgoingWebhookResponse, len(webhooks))
+	for i := range webhooks {
+		responses[i] = dtos.NewOutgoingWebhookResponse(&webhooks[i])
+	}
+	return responses
+}
+
+func respondCreateOutgoingWebhookError(c *gin.Context, userID string, err error) {
+	if errors.Is(err, services.ErrInvalidWebhook) {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+	} else if errors.Is(err, services.ErrWebhookLimitReached) {
+		c.JSON(http.StatusConflict, utils.NewErrorResponse("Too many outgoing webhooks"))
+	} else {
+		log.Error("Failed to create outgoing webhook", "userID", userID, "err", err.Error())
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to create webhook"))
+	}
+}
+
+// respondManagedWebhookError responds to the errors from checking that the
+// user can manage an outgoing webhook, reporting whether err was one of them.
+// Users who can't see the webhook at all are told it doesn't exist.
+func respondManagedWebhookError(c *gin.Context, err error) bool {
+	if errors.Is(err, services.ErrWebhookNotFound) ||
+		errors.Is(err, services.ErrChatRoomNotFound) ||
+		errors.Is(err, services.ErrNotParticipant) {
+		c.JSON(http.StatusNotFound, utils.NewErrorResponse("Webhook not found"))
+	} else if errors.Is(err, services.ErrNotModerator) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("Only moderators can manage webhooks"))
+	} else {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/services"
+	"github.com/teamyapchat/yapchat-server/internal/utils"
+)
+
+type PushHandler struct {
+	pushService *services.PushService
+}
+
+func NewPushHandler(pushService *services.PushService) *PushHandler {
+	return &PushHandler{pushService: pushService}
+}
+
+// GetVAPIDKeyHandler godoc
+//
+//	@Summary		Get VAPID public key
+//	@Description	Get the application server key to subscribe to Web Push notifications with
+//	@Tags			push
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Success		200				{object}	utils.SuccessResponse{data=dtos.VAPIDKeyResponse}
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		503				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/push/vapid-key [get]
+func (h *PushHandler) GetVAPIDKeyHandler(c *gin.Context) {
+	publicKey, err := h.pushService.VAPIDPublicKey()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, utils.NewErrorResponse("Push notifications are disabled"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(dtos.VAPIDKeyResponse{PublicKey: publicKey}))
+}
+
+// SubscribeHandler godoc
+//
+//	@Summary		Subscribe to push notifications
+//	@Description	Register a Web Push subscription to be notified of messages while offline
+//	@Tags			push
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header	string							true	"Bearer token"
+//	@Param			request			body	dtos.PushSubscriptionRequest	true	"Push subscription"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Failure		503	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/users/me/push-subscriptions [post]
+func (h *PushHandler) SubscribeHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	var subscriptionRequest dtos.PushSubscriptionRequest
+	if err := c.ShouldBindJSON(&subscriptionRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	if err := h.pushService.Subscribe(userID.(string), subscriptionRequest); err != nil {
+		if errors.Is(err, services.ErrInvalidPushSubscription) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else if errors.Is(err, services.ErrPushDisabled) {
+			c.JSON(http.StatusServiceUnavailable, utils.NewErrorResponse("Push notifications are disabled"))
+		} else {
+			log.Error("Failed to save push subscription", "userID", userID, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to subscribe"))
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// UnsubscribeHandler godoc
+//
+//	@Summary		Unsubscribe from push notifications
+//	@Description	Remove a Web Push subscription
+//	@Tags			push
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header	string								true	"Bearer token"
+//	@Param			request			body	dtos.DeletePushSubscriptionRequest	true	"Push subscription endpoint"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/users/me/push-subscriptions [delete]
+func (h *PushHandler) UnsubscribeHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	var deleteRequest dtos.DeletePushSubscriptionRequest
+	if err := c.ShouldBindJSON(&deleteRequest); err != nil || deleteRequest.Endpoint == "" {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	if err := h.pushService.Unsubscribe(userID.(string), deleteRequest.Endpoint); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to unsubscribe"))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
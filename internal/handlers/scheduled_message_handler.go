@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/services"
+	"github.com/teamyapchat/yapchat-server/internal/utils"
+)
+
+type ScheduledMessageHandler struct {
+	scheduledService *services.ScheduledMessageService
+}
+
+func NewScheduledMessageHandler(
+	scheduledService *services.ScheduledMessageService,
+) *ScheduledMessageHandler {
+	return &ScheduledMessageHandler{scheduledService: scheduledService}
+}
+
+// ListHandler godoc
+//
+//	@Summary		List scheduled messages
+//	@Description	List the messages the user has scheduled in a chat room, soonest first, including any that failed to send
+//	@Tags			chatrooms
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			id				path		integer	true	"Chat room ID"
+//	@Success		200				{object}	utils.SuccessResponse{data=[]dtos.ScheduledMessageResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/scheduled-messages [get]
+func (h *ScheduledMessageHandler) ListHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	messages, err := h.scheduledService.List(uint(idUint64), userID.(string))
+	if err != nil {
+		if !respondScheduledMessageError(c, err) {
+			log.Error("Failed to get scheduled messages", "userID", userID, "err", err.Error())
+			c.JSON(
+				http.StatusInternalServerError,
+				utils.NewErrorResponse("Failed to get scheduled messages"),
+			)
+		}
+		return
+	}
+
+	responses := make([]dtos.ScheduledMessageResponse, len(messages))
+	for i := range messages {
+		responses[i] = dtos.NewScheduledMessageResponse(&messages[i])
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(responses))
+}
+
+// CreateHandler godoc
+//
+//	@Summary		Schedule a message
+//	@Description	Write a message now to be sent to a chat room later, up to a year ahead. It's sent as if it was sent over the gateway at that time.
+//	@Tags			chatrooms
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string						true	"Bearer token"
+//	@Param			id				path		integer						true	"Chat room ID"
+//	@Param			request			body		dtos.ScheduleMessageRequest	true	"Message and when to send it"
+//	@Success		201				{object}	utils.SuccessResponse{data=dtos.ScheduledMessageResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		409				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/scheduled-messages [post]
+func (h *ScheduledMessageHandler) CreateHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+
+	var scheduleRequest dtos.ScheduleMessageRequest
+	if err := c.ShouldBindJSON(&scheduleRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	message, err := h.scheduledService.Schedule(uint(idUint64), userID.(string), scheduleRequest)
+	if err != nil {
+		if respondScheduledMessageError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrScheduleLimitReached) {
+			c.JSON(http.StatusConflict, utils.NewErrorResponse("Too many scheduled messages"))
+		} else {
+			log.Error("Failed to schedule message", "userID", userID, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to schedule message"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.NewSuccessResponse(dtos.NewScheduledMessageResponse(message)))
+}
+
+// UpdateHandler godoc
+//
+//	@Summary		Edit a scheduled message
+//	@Description	Change a scheduled message's content or when it's sent. Messages that failed to send are scheduled again, straight away if their time has passed.
+//	@Tags			chatrooms
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string								true	"Bearer token"
+//	@Param			id				path		integer								true	"Chat room ID"
+//	@Param			message_id		path		integer								true	"Scheduled message ID"
+//	@Param			request			body		dtos.UpdateScheduledMessageRequest	true	"Changes"
+//	@Success		200				{object}	utils.SuccessResponse{data=dtos.ScheduledMessageResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		409				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/scheduled-messages/{message_id} [patch]
+func (h *ScheduledMessageHandler) UpdateHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+	messageIDUint64, err := strconv.ParseUint(c.Param("message_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid scheduled message ID"))
+		return
+	}
+
+	var updateRequest dtos.UpdateScheduledMessageRequest
+	if err := c.ShouldBindJSON(&updateRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	message, err := h.scheduledService.Update(
+		uint(idUint64),
+		uint(messageIDUint64),
+		userID.(string),
+		updateRequest,
+	)
+	if err != nil {
+		if !respondScheduledMessageError(c, err) {
+			log.Error("Failed to update scheduled message", "userID", userID, "err", err.Error())
+			c.JSON(
+				http.StatusInternalServerError,
+				utils.NewErrorResponse("Failed to update scheduled message"),
+			)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(dtos.NewScheduledMessageResponse(message)))
+}
+
+// DeleteHandler godoc
+//
+//	@Summary		Cancel a scheduled message
+//	@Description	Delete a scheduled message so that it isn't sent
+//	@Tags			chatrooms
+//	@Produce		json
+//	@Param			Authorization	header	string	true	"Bearer token"
+//	@Param			id				path	integer	true	"Chat room ID"
+//	@Param			message_id		path	integer	true	"Scheduled message ID"
+//	@Success		204
+//	@Failure		400	{object}	utils.ErrorResponse
+//	@Failure		401	{object}	utils.ErrorResponse
+//	@Failure		403	{object}	utils.ErrorResponse
+//	@Failure		404	{object}	utils.ErrorResponse
+//	@Failure		409	{object}	utils.ErrorResponse
+//	@Failure		500	{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/chatrooms/{id}/scheduled-messages/{message_id} [delete]
+func (h *ScheduledMessageHandler) DeleteHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	idStr := c.Param("id")
+	idUint64, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+		return
+	}
+	messageIDUint64, err := strconv.ParseUint(c.Param("message_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid scheduled message ID"))
+		return
+	}
+
+	err = h.scheduledService.Cancel(uint(idUint64), uint(messageIDUint64), userID.(string))
+	if err != nil {
+		if !respondScheduledMessageError(c, err) {
+			log.Error("Failed to cancel scheduled message", "userID", userID, "err", err.Error())
+			c.JSON(
+				http.StatusInternalServerError,
+				utils.NewErrorResponse("Failed to cancel scheduled message"),
+			)
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondScheduledMessageError responds to the errors every scheduled message
+// endpoint can return, reporting whether err was one of them.
+func respondScheduledMessageError(c *gin.Context, err error) bool {
+	if errors.Is(err, services.ErrChatRoomNotFound) {
+		c.JSON(http.StatusNotFound, utils.NewErrorResponse("Chat room not found"))
+	} else if errors.Is(err, services.ErrNotParticipant) {
+		c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+	} else if errors.Is(err, services.ErrScheduledMessageNotFound) {
+		c.JSON(http.StatusNotFound, utils.NewErrorResponse("Scheduled message not found"))
+	} else if errors.Is(err, services.ErrScheduledMessageSending) {
+		c.JSON(http.StatusConflict, utils.NewErrorResponse("Message is already being sent"))
+	} else if errors.Is(err, services.ErrInvalidSchedule) ||
+		errors.Is(err, services.ErrInvalidMessage) {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+	} else {
+		return false
+	}
+	return true
+}
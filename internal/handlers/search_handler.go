@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/search"
+	"github.com/teamyapchat/yapchat-server/internal/services"
+	"github.com/teamyapchat/yapchat-server/internal/utils"
+)
+
+type SearchHandler struct {
+	chatroomService   *services.ChatRoomService
+	messageService    *services.MessageService
+	attachmentService *services.AttachmentService
+}
+
+func NewSearchHandler(
+	chatroomService *services.ChatRoomService,
+	messageService *services.MessageService,
+	attachmentService *services.AttachmentService,
+) *SearchHandler {
+	return &SearchHandler{
+		chatroomService:   chatroomService,
+		messageService:    messageService,
+		attachmentService: attachmentService,
+	}
+}
+
+// SearchMessagesHandler godoc
+//
+//	@Summary		Search messages
+//	@Description	Full-text search over messages in the chat rooms the user is in, newest first
+//	@Tags			search
+//	@Produce		json
+//	@Param			Authorization	header		string		true	"Bearer token"
+//	@Param			q				query		string		true	"Search query"
+//	@Param			room_id			query		integer		false	"Only search this chat room"
+//	@Param			author_id		query		string		false	"Only search messages sent by this user"
+//	@Param			after			query		string		false	"Only search messages sent at or after this time (RFC 3339)"
+//	@Param			before			query		string		false	"Only search messages sent before this time (RFC 3339)"
+//	@Param			has				query		[]string	false	"Only search messages that have these"	Enums(attachment, link)	collectionFormat(multi)
+//	@Param			cursor			query		string		false	"Cursor from the previous page"
+//	@Param			limit			query		integer		false	"Number of results per page (default 25)"
+//	@Success		200				{object}	utils.CursorPagination{data=[]dtos.MessageSearchResult}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		403				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/search/messages [get]
+func (h *SearchHandler) SearchMessagesHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	query := search.Query{
+		Text:     c.Query("q"),
+		AuthorID: c.Query("author_id"),
+	}
+
+	roomIDs, err := h.chatroomService.ListIDs(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to list chat rooms"))
+		return
+	}
+	query.RoomIDs = roomIDs
+
+	if roomIDStr := c.Query("room_id"); roomIDStr != "" {
+		roomID, err := strconv.ParseUint(roomIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid chat room ID"))
+			return
+		}
+
+		if !slices.Contains(roomIDs, uint(roomID)) {
+			c.JSON(http.StatusForbidden, utils.NewErrorResponse("User not in chat room"))
+			return
+		}
+		query.RoomIDs = []uint{uint(roomID)}
+	}
+
+	if after := c.Query("after"); after != "" {
+		t, err := time.Parse(time.RFC3339, after)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid after time"))
+			return
+		}
+		query.After = &t
+	}
+
+	if before := c.Query("before"); before != "" {
+		t, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid before time"))
+			return
+		}
+		query.Before = &t
+	}
+
+	for _, has := range c.QueryArray("has") {
+		switch has {
+		case "attachment":
+			query.HasAttachment = true
+		case "link":
+			query.HasLink = true
+		default:
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid has filter"))
+			return
+		}
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		cursorID, err := strconv.ParseUint(cursor, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid cursor"))
+			return
+		}
+		query.Cursor = uint(cursorID)
+	}
+
+	query.Limit, err = strconv.Atoi(c.Query("limit"))
+	if err != nil || query.Limit <= 0 || query.Limit > 100 {
+		query.Limit = 25
+	}
+
+	hits, err := h.messageService.Search(query)
+	if err != nil {
+		if errors.Is(err, search.ErrEmptyQuery) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Search query is required"))
+		} else {
+			log.Error("Failed to search messages", "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to search messages"))
+		}
+		return
+	}
+
+	results := make([]dtos.MessageSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		message := dtos.NewMessageResponse(&hit.Message)
+		message.Attachments = h.attachmentService.ToResponses(hit.Message.Attachments)
+		results = append(results, dtos.MessageSearchResult{
+			Message: message,
+			Snippet: hit.Snippet,
+		})
+	}
+
+	pagination := utils.CursorPagination{Data: results}
+	if len(hits) == query.Limit {
+		pagination.NextCursor = strconv.FormatUint(uint64(hits[len(hits)-1].Message.ID), 10)
+	}
+
+	c.JSON(http.StatusOK, pagination)
+}
@@ -1,23 +1,37 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 
 	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/models"
 	"github.com/teamyapchat/yapchat-server/internal/services"
 	"github.com/teamyapchat/yapchat-server/internal/utils"
 )
 
 type UserHandler struct {
-	userService *services.UserService
+	userService     *services.UserService
+	presenceService *services.PresenceService
 }
 
-func NewUserHandler(userService *services.UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+func NewUserHandler(
+	userService *services.UserService,
+	presenceService *services.PresenceService,
+) *UserHandler {
+	return &UserHandler{
+		userService:     userService,
+		presenceService: presenceService,
+	}
 }
 
 // GetMeHandler godoc
@@ -49,14 +63,134 @@ func (h *UserHandler) GetMeHandler(c *gin.Context) {
 		return
 	}
 
-	userResponse := dtos.UserResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		ImageURL:  user.ImageURL,
-		IsOnline:  user.IsOnline,
-		CreatedAt: user.CreatedAt.Format(time.RFC3339),
+	presence, err := h.presenceService.Get(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get presence"))
+		return
 	}
 
+	userResponse := dtos.NewUserResponse(user, presence)
+	userResponse.Discoverable = &user.Discoverable
+	userResponse.DefaultNotifications = string(user.DefaultNotifications)
+	userResponse.DigestFrequency = string(user.DigestFrequency)
+	userResponse.CreatedAt = user.CreatedAt.Format(time.RFC3339)
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(userResponse))
+}
+
+// UpdateMeHandler godoc
+//
+//	@Summary		Update user profile
+//	@Description	Update the display name, bio, pronouns, banner and accent color of the currently authenticated user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string						true	"Bearer token"
+//	@Param			request			body		dtos.UpdateProfileRequest	true	"Profile info"
+//	@Success		200				{object}	utils.SuccessResponse{data=dtos.UserResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/users/me [patch]
+func (h *UserHandler) UpdateMeHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	var profileRequest dtos.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&profileRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(userID.(string), profileRequest)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidProfile) {
+			c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("User not found"))
+		} else {
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to update profile"))
+		}
+		return
+	}
+
+	presence, err := h.presenceService.Get(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get presence"))
+		return
+	}
+
+	userResponse := dtos.NewUserResponse(user, presence)
+	userResponse.Discoverable = &user.Discoverable
+	userResponse.DefaultNotifications = string(user.DefaultNotifications)
+	userResponse.DigestFrequency = string(user.DigestFrequency)
+	userResponse.CreatedAt = user.CreatedAt.Format(time.RFC3339)
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(userResponse))
+}
+
+// UpdateAvatarHandler godoc
+//
+//	@Summary		Update avatar
+//	@Description	Upload a new avatar for the currently authenticated user. The image is re-encoded without metadata and scaled down to fit 512x512.
+//	@Tags			users
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			image			formData	file	true	"Avatar image (PNG, JPEG, GIF or WebP)"
+//	@Success		200				{object}	utils.SuccessResponse{data=dtos.UserResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		404				{object}	utils.ErrorResponse
+//	@Failure		413				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/users/me/avatar [put]
+func (h *UserHandler) UpdateAvatarHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	file, size, ok := formImage(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	user, err := h.userService.UpdateAvatar(c.Request.Context(), userID.(string), file, size)
+	if err != nil {
+		if writeImageError(c, err) {
+			return
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, utils.NewErrorResponse("User not found"))
+		} else {
+			log.Error("Failed to update avatar", "userID", userID, "err", err.Error())
+			c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to update avatar"))
+		}
+		return
+	}
+
+	presence, err := h.presenceService.Get(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get presence"))
+		return
+	}
+
+	userResponse := dtos.NewUserResponse(user, presence)
+	userResponse.Discoverable = &user.Discoverable
+	userResponse.DefaultNotifications = string(user.DefaultNotifications)
+	userResponse.DigestFrequency = string(user.DigestFrequency)
+	userResponse.CreatedAt = user.CreatedAt.Format(time.RFC3339)
+
 	c.JSON(http.StatusOK, utils.NewSuccessResponse(userResponse))
 }
 
@@ -89,12 +223,167 @@ func (h *UserHandler) GetByUsernameHandler(c *gin.Context) {
 		return
 	}
 
-	userResponse := dtos.UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		ImageURL: user.ImageURL,
-		IsOnline: user.IsOnline,
+	presences, err := h.presenceService.GetVisible(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get presence"))
+		return
 	}
 
-	c.JSON(http.StatusOK, utils.NewSuccessResponse(userResponse))
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(dtos.NewUserResponse(user, presences[user.ID])))
+}
+
+// SearchHandler godoc
+//
+//	@Summary		Search users
+//	@Description	Find users by username or display name, ranking people who share a room with the caller first
+//	@Tags			users
+//	@Produce		json
+//	@Param			Authorization	header		string	true	"Bearer token"
+//	@Param			q				query		string	true	"Search query"
+//	@Param			page			query		integer	false	"Page number (default 1)"
+//	@Param			page_size		query		integer	false	"Number of users per page (default 25)"
+//	@Success		200				{object}	utils.Pagination{data=[]dtos.UserResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/users/search [get]
+func (h *UserHandler) SearchHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" || utf8.RuneCountInString(query) > 32 {
+		c.JSON(
+			http.StatusBadRequest,
+			utils.NewErrorResponse("Query must be between 1 and 32 characters"),
+		)
+		return
+	}
+
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil {
+		page = 1
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.Query("page_size"))
+	if err != nil {
+		pageSize = 25
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 25
+	}
+	offset := (page - 1) * pageSize
+
+	users, totalRows, err := h.userService.Search(query, userID.(string), pageSize, offset)
+	if err != nil {
+		log.Error("Failed to search users", "query", query, "err", err.Error())
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to search users"))
+		return
+	}
+
+	userIDs := make([]string, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
+	}
+
+	presences, err := h.presenceService.GetVisible(userIDs...)
+	if err != nil {
+		log.Error("Failed to get presence of search results", "err", err.Error())
+	}
+
+	userList := make([]dtos.UserResponse, 0, len(users))
+	for _, user := range users {
+		userList = append(userList, dtos.NewUserResponse(user, presences[user.ID]))
+	}
+
+	pagination := utils.Pagination{
+		Page:       page,
+		PageSize:   pageSize,
+		TotalRows:  totalRows,
+		TotalPages: int(math.Ceil(float64(totalRows) / float64(pageSize))),
+		Data:       userList,
+	}
+
+	c.JSON(http.StatusOK, pagination)
+}
+
+// UpdatePresenceHandler godoc
+//
+//	@Summary		Update presence
+//	@Description	Update the status and custom status of the currently authenticated user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string						true	"Bearer token"
+//	@Param			request			body		dtos.UpdatePresenceRequest	true	"Presence info"
+//	@Success		200				{object}	utils.SuccessResponse{data=dtos.PresenceResponse}
+//	@Failure		400				{object}	utils.ErrorResponse
+//	@Failure		401				{object}	utils.ErrorResponse
+//	@Failure		500				{object}	utils.ErrorResponse
+//	@Security		BearerAuth
+//	@Router			/v1/users/me/presence [put]
+func (h *UserHandler) UpdatePresenceHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("User ID not found in context"))
+		return
+	}
+
+	var presenceRequest dtos.UpdatePresenceRequest
+	if err := c.ShouldBindJSON(&presenceRequest); err != nil {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request body"))
+		return
+	}
+
+	if presenceRequest.Status != "" {
+		err := h.presenceService.SetStatus(
+			userID.(string),
+			models.PresenceStatus(presenceRequest.Status),
+		)
+		if err != nil {
+			writePresenceError(c, err)
+			return
+		}
+	}
+
+	if presenceRequest.CustomStatus != nil {
+		var customStatus *models.CustomStatus
+		if presenceRequest.CustomStatus.Text != "" || presenceRequest.CustomStatus.Emoji != "" {
+			customStatus = &models.CustomStatus{
+				Text:      presenceRequest.CustomStatus.Text,
+				Emoji:     presenceRequest.CustomStatus.Emoji,
+				ExpiresAt: presenceRequest.CustomStatus.ExpiresAt,
+			}
+		}
+
+		if err := h.presenceService.SetCustomStatus(userID.(string), customStatus); err != nil {
+			writePresenceError(c, err)
+			return
+		}
+	}
+
+	presence, err := h.presenceService.Get(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to get presence"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.NewSuccessResponse(dtos.NewPresenceResponse(presence)))
+}
+
+func writePresenceError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidStatus) {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid status"))
+	} else if errors.Is(err, services.ErrInvalidCustomStatus) {
+		c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid custom status"))
+	} else {
+		c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Failed to update presence"))
+	}
 }
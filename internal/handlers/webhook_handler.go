@@ -16,9 +16,27 @@ import (
 )
 
 type UserData struct {
-	ID       string `json:"id"`
-	Username string `json:"username,omitempty"`
-	ImageURL string `json:"image_url,omitempty"`
+	ID                    string         `json:"id"`
+	Username              string         `json:"username,omitempty"`
+	ImageURL              string         `json:"image_url,omitempty"`
+	EmailAddresses        []EmailAddress `json:"email_addresses,omitempty"`
+	PrimaryEmailAddressID string         `json:"primary_email_address_id,omitempty"`
+}
+
+type EmailAddress struct {
+	ID           string `json:"id"`
+	EmailAddress string `json:"email_address"`
+}
+
+// PrimaryEmail returns the user's primary email address, or "" if they have
+// none.
+func (d UserData) PrimaryEmail() string {
+	for _, address := range d.EmailAddresses {
+		if address.ID == d.PrimaryEmailAddressID {
+			return address.EmailAddress
+		}
+	}
+	return ""
 }
 
 type WebhookData struct {
@@ -99,17 +117,26 @@ func (h *WebhookHandler) WebhookHandler(c *gin.Context) {
 
 // TODO: Integrate user service for database syncing
 func (h *WebhookHandler) handleUserCreated(data UserData) error {
+	// Users who sign in before this arrives are already copied from Clerk
+	if _, err := h.userService.GetByID(data.ID); err == nil {
+		return h.handleUserUpdated(data)
+	}
+
 	return h.userService.Create(&models.User{
 		ID:       data.ID,
 		Username: data.Username,
 		ImageURL: data.ImageURL,
+		Email:    data.PrimaryEmail(),
 	})
 }
 
+// handleUserUpdated only syncs the fields Clerk owns. Profile fields such as
+// the display name and bio are edited locally and must not be overwritten.
 func (h *WebhookHandler) handleUserUpdated(data UserData) error {
 	_, err := h.userService.Update(data.ID, dtos.UpdateUserRequest{
 		Username: data.Username,
 		ImageURL: data.ImageURL,
+		Email:    data.PrimaryEmail(),
 	})
 
 	return err
@@ -0,0 +1,184 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrImageTooLarge     = errors.New("image dimensions too large")
+)
+
+const (
+	// Checked against the header before decoding so that a small file can't
+	// claim dimensions that would exhaust memory once decoded
+	maxDimension = 12000
+	maxPixels    = 40_000_000
+
+	jpegQuality    = 90
+	blurHashSource = 32
+)
+
+type Options struct {
+	// MaxDimension shrinks the image to fit within a square of this size.
+	// Zero keeps the original dimensions.
+	MaxDimension   int
+	ThumbnailSizes []int
+}
+
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+type Thumbnail struct {
+	Size int
+	Image
+}
+
+type Result struct {
+	Image
+	BlurHash   string
+	Thumbnails []Thumbnail
+}
+
+// Process validates an uploaded image and normalises it. Decoding and
+// re-encoding drops EXIF and any other embedded metadata, after applying the
+// EXIF orientation so the image still displays the right way up.
+func Process(data []byte, opts Options) (*Result, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	if config.Width <= 0 || config.Height <= 0 ||
+		config.Width > maxDimension || config.Height > maxDimension ||
+		config.Width*config.Height > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	// Only decodes the first frame of a GIF
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	if format == "jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	result := &Result{}
+
+	if format == "gif" && opts.MaxDimension == 0 {
+		// GIFs carry no EXIF, and re-encoding would throw away every frame
+		// but the first, so the original is kept as is
+		result.Image = Image{
+			Data:        data,
+			ContentType: "image/gif",
+			Width:       config.Width,
+			Height:      config.Height,
+		}
+	} else {
+		if opts.MaxDimension > 0 {
+			img = fit(img, opts.MaxDimension)
+		}
+
+		result.Image, err = encode(img, format)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, size := range opts.ThumbnailSizes {
+		if size >= max(result.Width, result.Height) {
+			continue
+		}
+
+		thumbnail, err := encode(fit(img, size), "")
+		if err != nil {
+			return nil, err
+		}
+		result.Thumbnails = append(result.Thumbnails, Thumbnail{Size: size, Image: thumbnail})
+	}
+
+	result.BlurHash = BlurHash(fit(img, blurHashSource), 4, 3)
+
+	return result, nil
+}
+
+// encode writes img in its original format where possible, falling back to
+// JPEG for opaque images and PNG for everything else.
+func encode(img image.Image, format string) (Image, error) {
+	if format != "jpeg" && format != "png" {
+		format = "png"
+		if isOpaque(img) {
+			format = "jpeg"
+		}
+	}
+
+	var buf bytes.Buffer
+	var contentType string
+	switch format {
+	case "jpeg":
+		contentType = "image/jpeg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Image{}, err
+		}
+	default:
+		contentType = "image/png"
+		if err := png.Encode(&buf, img); err != nil {
+			return Image{}, err
+		}
+	}
+
+	bounds := img.Bounds()
+	return Image{
+		Data:        buf.Bytes(),
+		ContentType: contentType,
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+	}, nil
+}
+
+func isOpaque(img image.Image) bool {
+	if _, ok := img.(*image.Paletted); ok {
+		return false
+	}
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// fit scales img down to fit within a size by size square, keeping its
+// aspect ratio. Images that already fit are returned as is.
+func fit(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*size/w)
+		w = size
+	} else {
+		w = max(1, w*size/h)
+		h = size
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
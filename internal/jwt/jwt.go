@@ -0,0 +1,209 @@
+// Package jwt signs and verifies the JSON Web Tokens the server issues itself
+// when it isn't using Clerk. Only HS256 and RS256 are supported, and a key
+// only ever accepts tokens signed with its own algorithm.
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"time"
+)
+
+type Algorithm string
+
+const (
+	HS256 Algorithm = "HS256"
+	RS256 Algorithm = "RS256"
+)
+
+var (
+	ErrInvalidKey   = errors.New("invalid key")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+	ErrCannotSign   = errors.New("key can only verify tokens")
+)
+
+const (
+	minSecretLength = 32
+	// Allowed clock difference between the issuing and verifying servers
+	leeway = time.Minute
+)
+
+type Claims struct {
+	Subject   string `json:"sub"`
+	Issuer    string `json:"iss,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type header struct {
+	Algorithm Algorithm `json:"alg"`
+	Type      string    `json:"typ,omitempty"`
+}
+
+// Key signs and verifies tokens with one algorithm. An RS256 key made from
+// only a public key can verify tokens but not sign them.
+type Key struct {
+	algorithm  Algorithm
+	secret     []byte
+	privateKey *rsa.PrivateKey
+	publicKey  *rsa.PublicKey
+}
+
+// NewHMACKey returns an HS256 key. The secret must be at least 32 bytes.
+func NewHMACKey(secret []byte) (*Key, error) {
+	if len(secret) < minSecretLength {
+		return nil, ErrInvalidKey
+	}
+	return &Key{algorithm: HS256, secret: secret}, nil
+}
+
+// NewRSAKey returns an RS256 key from a PEM encoded private or public key,
+// in PKCS #1 or PKCS #8/PKIX form.
+func NewRSAKey(pemData []byte) (*Key, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	key := &Key{algorithm: RS256}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.privateKey = k
+		key.publicKey = &k.PublicKey
+	case *rsa.PublicKey:
+		key.publicKey = k
+	default:
+		return nil, ErrInvalidKey
+	}
+	if key.publicKey.N.BitLen() < 2048 {
+		return nil, ErrInvalidKey
+	}
+
+	return key, nil
+}
+
+func (k *Key) Algorithm() Algorithm {
+	return k.algorithm
+}
+
+func (k *Key) Sign(claims Claims) (string, error) {
+	if k.algorithm == RS256 && k.privateKey == nil {
+		return "", ErrCannotSign
+	}
+
+	headerJSON, err := json.Marshal(header{Algorithm: k.algorithm, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := encode(headerJSON) + "." + encode(claimsJSON)
+	signature, err := k.sign(unsigned)
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + encode(signature), nil
+}
+
+// Verify checks a token's signature and that it's valid at now, returning
+// its claims.
+func (k *Key) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	headerJSON, err := decode(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil || h.Algorithm != k.algorithm {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := decode(parts[2])
+	if err != nil || !k.verify(parts[0]+"."+parts[1], signature) {
+		return nil, ErrInvalidToken
+	}
+
+	claimsJSON, err := decode(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(leeway)) {
+		return nil, ErrExpiredToken
+	}
+	if claims.NotBefore != 0 && now.Add(leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
+
+func (k *Key) sign(unsigned string) ([]byte, error) {
+	if k.algorithm == HS256 {
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write([]byte(unsigned))
+		return mac.Sum(nil), nil
+	}
+
+	digest := sha256.Sum256([]byte(unsigned))
+	return rsa.SignPKCS1v15(rand.Reader, k.privateKey, crypto.SHA256, digest[:])
+}
+
+func (k *Key) verify(unsigned string, signature []byte) bool {
+	if k.algorithm == HS256 {
+		expected, _ := k.sign(unsigned)
+		return hmac.Equal(signature, expected)
+	}
+
+	digest := sha256.Sum256([]byte(unsigned))
+	return rsa.VerifyPKCS1v15(k.publicKey, crypto.SHA256, digest[:], signature) == nil
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
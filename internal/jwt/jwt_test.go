@@ -0,0 +1,358 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	return privateKey
+}
+
+func encodePEM(blockType string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+// keyOrFatal returns a function that takes what a key constructor returns
+// and fails the test on an error.
+func keyOrFatal(t *testing.T) func(*Key, error) *Key {
+	return func(key *Key, err error) *Key {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("creating key: %v", err)
+		}
+		return key
+	}
+}
+
+func testClaims(now time.Time) Claims {
+	return Claims{
+		Subject:   "user_2abc",
+		Issuer:    "yapchat",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	mustKey := keyOrFatal(t)
+	privateKey := newTestRSAKey(t, 2048)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("encoding private key: %v", err)
+	}
+	pkix, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("encoding public key: %v", err)
+	}
+
+	hmacKey := mustKey(NewHMACKey(testSecret))
+	pkcs1Key := mustKey(NewRSAKey(
+		encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(privateKey)),
+	))
+	pkcs8Key := mustKey(NewRSAKey(encodePEM("PRIVATE KEY", pkcs8)))
+	pkcs1PublicKey := mustKey(NewRSAKey(
+		encodePEM("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&privateKey.PublicKey)),
+	))
+	pkixPublicKey := mustKey(NewRSAKey(encodePEM("PUBLIC KEY", pkix)))
+
+	tests := []struct {
+		name      string
+		signer    *Key
+		verifier  *Key
+		algorithm Algorithm
+	}{
+		{name: "HS256", signer: hmacKey, verifier: hmacKey, algorithm: HS256},
+		{name: "RS256 PKCS #1", signer: pkcs1Key, verifier: pkcs1Key, algorithm: RS256},
+		{name: "RS256 PKCS #8", signer: pkcs8Key, verifier: pkcs1Key, algorithm: RS256},
+		{name: "RS256 PKCS #1 public key", signer: pkcs1Key, verifier: pkcs1PublicKey, algorithm: RS256},
+		{name: "RS256 PKIX public key", signer: pkcs8Key, verifier: pkixPublicKey, algorithm: RS256},
+	}
+
+	now := time.Now()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.signer.Algorithm(); got != tt.algorithm {
+				t.Errorf("Algorithm() = %s, want %s", got, tt.algorithm)
+			}
+
+			token, err := tt.signer.Sign(testClaims(now))
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			claims, err := tt.verifier.Verify(token, now)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if *claims != testClaims(now) {
+				t.Errorf("Verify() = %+v, want %+v", *claims, testClaims(now))
+			}
+		})
+	}
+
+	t.Run("public key can't sign", func(t *testing.T) {
+		if _, err := pkixPublicKey.Sign(testClaims(now)); !errors.Is(err, ErrCannotSign) {
+			t.Errorf("Sign() error = %v, want %v", err, ErrCannotSign)
+		}
+	})
+}
+
+// forge builds a token with the given header and claims, signed by key with
+// its own algorithm whatever the header says.
+func forge(t *testing.T, key *Key, h header, claims Claims) string {
+	t.Helper()
+	headerJSON, _ := json.Marshal(h)
+	claimsJSON, _ := json.Marshal(claims)
+	unsigned := encode(headerJSON) + "." + encode(claimsJSON)
+	if key == nil {
+		return unsigned + "."
+	}
+	signature, err := key.sign(unsigned)
+	if err != nil {
+		t.Fatalf("signing: %v", err)
+	}
+	return unsigned + "." + encode(signature)
+}
+
+func TestVerifyRejectsOtherAlgorithms(t *testing.T) {
+	mustKey := keyOrFatal(t)
+	privateKey := newTestRSAKey(t, 2048)
+	publicPEM := encodePEM("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&privateKey.PublicKey))
+
+	hmacKey := mustKey(NewHMACKey(testSecret))
+	rsaKey := mustKey(NewRSAKey(
+		encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(privateKey)),
+	))
+	rsaPublicKey := mustKey(NewRSAKey(publicPEM))
+	// The public key is no secret, so an HS256 token signed with it must
+	// not pass for RS256
+	publicAsSecret := mustKey(NewHMACKey(publicPEM))
+
+	now := time.Now()
+	claims := testClaims(now)
+	tests := []struct {
+		name     string
+		token    string
+		verifier *Key
+	}{
+		{
+			name:     "HS256 token, RS256 key",
+			token:    forge(t, hmacKey, header{Algorithm: HS256}, claims),
+			verifier: rsaKey,
+		},
+		{
+			name:     "RS256 token, HS256 key",
+			token:    forge(t, rsaKey, header{Algorithm: RS256}, claims),
+			verifier: hmacKey,
+		},
+		{
+			name:     "public key as HMAC secret",
+			token:    forge(t, publicAsSecret, header{Algorithm: HS256}, claims),
+			verifier: rsaPublicKey,
+		},
+		{
+			name:     "public key as HMAC secret, claiming RS256",
+			token:    forge(t, publicAsSecret, header{Algorithm: RS256}, claims),
+			verifier: rsaPublicKey,
+		},
+		{
+			name:     "none, HS256 key",
+			token:    forge(t, nil, header{Algorithm: "none"}, claims),
+			verifier: hmacKey,
+		},
+		{
+			name:     "none, RS256 key",
+			token:    forge(t, nil, header{Algorithm: "none"}, claims),
+			verifier: rsaPublicKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.verifier.Verify(tt.token, now); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify() error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestVerifyTimes(t *testing.T) {
+	mustKey := keyOrFatal(t)
+	key := mustKey(NewHMACKey(testSecret))
+	issued := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name      string
+		notBefore time.Duration
+		expiresAt time.Duration
+		now       time.Duration
+		wantErr   error
+	}{
+		{name: "valid", expiresAt: time.Hour, now: time.Minute},
+		{name: "expired within leeway", expiresAt: time.Hour, now: time.Hour + leeway},
+		{
+			name:      "expired beyond leeway",
+			expiresAt: time.Hour,
+			now:       time.Hour + leeway + time.Second,
+			wantErr:   ErrExpiredToken,
+		},
+		{name: "no expiry", now: 0, wantErr: ErrExpiredToken},
+		{
+			name:      "not yet valid within leeway",
+			notBefore: time.Minute,
+			expiresAt: time.Hour,
+			now:       0,
+		},
+		{
+			name:      "not yet valid beyond leeway",
+			notBefore: time.Minute + leeway,
+			expiresAt: time.Hour,
+			now:       time.Minute - time.Second,
+			wantErr:   ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := Claims{Subject: "user_2abc", IssuedAt: issued.Unix()}
+			if tt.notBefore != 0 {
+				claims.NotBefore = issued.Add(tt.notBefore).Unix()
+			}
+			if tt.expiresAt != 0 {
+				claims.ExpiresAt = issued.Add(tt.expiresAt).Unix()
+			}
+
+			token, err := key.Sign(claims)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+
+			_, err = key.Verify(token, issued.Add(tt.now))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsTamperedTokens(t *testing.T) {
+	mustKey := keyOrFatal(t)
+	hmacKey := mustKey(NewHMACKey(testSecret))
+	rsaKey := mustKey(NewRSAKey(
+		encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(newTestRSAKey(t, 2048))),
+	))
+	otherHMACKey := mustKey(NewHMACKey([]byte(strings.Repeat("x", minSecretLength))))
+
+	now := time.Now()
+	for _, key := range []*Key{hmacKey, rsaKey} {
+		token, err := key.Sign(testClaims(now))
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		parts := strings.Split(token, ".")
+
+		signature, _ := decode(parts[2])
+		signature[0] ^= 1
+		otherClaims := testClaims(now)
+		otherClaims.Subject = "user_2xyz"
+		otherClaimsJSON, _ := json.Marshal(otherClaims)
+
+		tests := []struct {
+			name  string
+			token string
+		}{
+			{name: "flipped signature bit", token: parts[0] + "." + parts[1] + "." + encode(signature)},
+			{name: "changed claims", token: parts[0] + "." + encode(otherClaimsJSON) + "." + parts[2]},
+			{name: "no signature", token: parts[0] + "." + parts[1] + "."},
+			{name: "missing part", token: parts[0] + "." + parts[1]},
+			{name: "extra part", token: token + "." + parts[2]},
+			{name: "not base64", token: parts[0] + "." + parts[1] + ".!!!"},
+		}
+		if key == hmacKey {
+			other, err := otherHMACKey.Sign(testClaims(now))
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			tests = append(tests, struct {
+				name  string
+				token string
+			}{name: "other secret", token: other})
+		}
+
+		for _, tt := range tests {
+			t.Run(string(key.Algorithm())+" "+tt.name, func(t *testing.T) {
+				if _, err := key.Verify(tt.token, now); !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("Verify() error = %v, want %v", err, ErrInvalidToken)
+				}
+			})
+		}
+	}
+}
+
+func TestNewKeyRejectsWeakKeys(t *testing.T) {
+	shortRSAKey := newTestRSAKey(t, 1024)
+
+	tests := []struct {
+		name string
+		new  func() (*Key, error)
+	}{
+		{
+			name: "short secret",
+			new:  func() (*Key, error) { return NewHMACKey(testSecret[:minSecretLength-1]) },
+		},
+		{
+			name: "empty secret",
+			new:  func() (*Key, error) { return NewHMACKey(nil) },
+		},
+		{
+			name: "1024-bit RSA private key",
+			new: func() (*Key, error) {
+				return NewRSAKey(encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(shortRSAKey)))
+			},
+		},
+		{
+			name: "1024-bit RSA public key",
+			new: func() (*Key, error) {
+				return NewRSAKey(encodePEM(
+					"RSA PUBLIC KEY",
+					x509.MarshalPKCS1PublicKey(&shortRSAKey.PublicKey),
+				))
+			},
+		},
+		{
+			name: "not PEM",
+			new:  func() (*Key, error) { return NewRSAKey([]byte("not a key")) },
+		},
+		{
+			name: "certificate",
+			new:  func() (*Key, error) { return NewRSAKey(encodePEM("CERTIFICATE", []byte{0x30})) },
+		},
+		{
+			name: "corrupt key",
+			new:  func() (*Key, error) { return NewRSAKey(encodePEM("PRIVATE KEY", []byte{0x30})) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.new(); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("error = %v, want %v", err, ErrInvalidKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Package mail sends email through SMTP, or writes it to files for local
+// development and tests.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+type Mailer interface {
+	Send(ctx context.Context, message *Message) error
+}
+
+// Message is an email with both a plain text and an HTML body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	// Extra headers, such as List-Unsubscribe
+	Headers map[string]string
+}
+
+// build renders the message as a multipart/alternative MIME message.
+func (m *Message) build(from string, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	header := textproto.MIMEHeader{}
+	header.Set("From", from)
+	header.Set("To", m.To)
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header.Set("Date", now.Format(time.RFC1123Z))
+	header.Set("Message-ID", messageID(from))
+	header.Set("MIME-Version", "1.0")
+	header.Set("Content-Type", "multipart/alternative; boundary="+writer.Boundary())
+	for key, value := range m.Headers {
+		header.Set(key, value)
+	}
+
+	var head bytes.Buffer
+	for key, values := range header {
+		for _, value := range values {
+			fmt.Fprintf(&head, "%s: %s\r\n", key, value)
+		}
+	}
+	head.WriteString("\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(partWriter)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return append(head.Bytes(), buf.Bytes()...), nil
+}
+
+// envelopeAddress returns the bare address of a From header like
+// "YapChat <noreply@yapchat.xyz>".
+func envelopeAddress(from string) (string, error) {
+	address, err := netmail.ParseAddress(from)
+	if err != nil {
+		return "", err
+	}
+	return address.Address, nil
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if address, err := envelopeAddress(from); err == nil {
+		domain = address[strings.LastIndexByte(address, '@')+1:]
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	return "<" + hex.EncodeToString(id) + "@" + domain + ">"
+}
@@ -0,0 +1,24 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalize cleans up message content before it's parsed and stored. Line
+// endings become \n, invalid UTF-8 and control characters other than
+// newlines and tabs are dropped, and surrounding whitespace is trimmed.
+func Normalize(content string) string {
+	content = strings.ToValidUTF8(content, "")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+
+	content = strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, content)
+
+	return strings.TrimSpace(content)
+}
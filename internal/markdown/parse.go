@@ -0,0 +1,473 @@
+// Package markdown parses the subset of Markdown that messages support into
+// entities, so that every client renders a message the same way.
+//
+// The supported syntax is **bold**, *italic* or _italic_, __underline__,
+// ~~strikethrough~~, ||spoiler||, `code`, ```code blocks```, [links](url),
+// bare links, user mentions <@id>, @everyone, @here, room mentions <#id> and
+// custom emoji <:name:id>. Anything else, including unmatched markup, is plain text.
+package markdown
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+// Entities nested deeper than this are left as plain text, which keeps
+// parsing cheap on adversarial input.
+const maxDepth = 8
+
+type delimiter struct {
+	marker     string
+	entityType models.EntityType
+}
+
+// Longer markers come first so that ** isn't mistaken for two *
+var delimiters = []delimiter{
+	{"**", models.BoldEntity},
+	{"__", models.UnderlineEntity},
+	{"~~", models.StrikethroughEntity},
+	{"||", models.SpoilerEntity},
+	{"*", models.ItalicEntity},
+	{"_", models.ItalicEntity},
+}
+
+const escapable = "\\*_~|`[]()<>#@:"
+
+var (
+	userIDRegex    = regexp.MustCompile(`^[A-Za-z0-9_]{1,64}$`)
+	emojiNameRegex = regexp.MustCompile(`^[A-Za-z0-9_]{2,32}$`)
+	languageRegex  = regexp.MustCompile(`^[A-Za-z0-9_+#.-]{1,32}$`)
+)
+
+type parser struct {
+	text     []rune
+	entities []models.Entity
+}
+
+// Parse returns the entities in content, outer entities before the ones
+// nested inside them.
+func Parse(content string) []models.Entity {
+	p := &parser{text: []rune(content)}
+	p.parse(0, len(p.text), 0, false)
+	return p.toUTF16()
+}
+
+func (p *parser) parse(start, end, depth int, inLink bool) {
+	for i := start; i < end; {
+		if next := p.span(i, end, depth, inLink); next > i {
+			i = next
+		} else {
+			i++
+		}
+	}
+}
+
+// span parses whatever starts at i, returning where it ends, or -1 if i is
+// just a plain character.
+func (p *parser) span(i, end, depth int, inLink bool) int {
+	switch c := p.text[i]; {
+	case c == '\\' && i+1 < end && strings.ContainsRune(escapable, p.text[i+1]):
+		return i + 2
+	case c == '`':
+		if p.atLineStart(i) && p.hasPrefix(i, end, "```") {
+			if next := p.codeBlock(i, end); next > 0 {
+				return next
+			}
+		}
+		return p.inlineCode(i, end)
+	case c == '<':
+		return p.angle(i, end)
+	case c == '@' && (i == 0 || !isWordChar(p.text[i-1])):
+		if next := p.massMention(i, end); next > 0 {
+			return next
+		}
+	case c == '[' && !inLink && depth < maxDepth:
+		if next := p.link(i, end, depth); next > 0 {
+			return next
+		}
+	case c == 'h' && !inLink && (i == 0 || !isWordChar(p.text[i-1])):
+		if next := p.autolink(i, end); next > 0 {
+			return next
+		}
+	}
+
+	if depth >= maxDepth {
+		return -1
+	}
+
+	for _, d := range delimiters {
+		if p.hasPrefix(i, end, d.marker) {
+			if next := p.emphasis(i, end, depth, inLink, d); next > 0 {
+				return next
+			}
+		}
+	}
+
+	return -1
+}
+
+func (p *parser) emphasis(i, end, depth int, inLink bool, d delimiter) int {
+	innerStart := i + len(d.marker)
+	if innerStart >= end || unicode.IsSpace(p.text[innerStart]) {
+		return -1
+	}
+	// snake_case_words aren't italic
+	if d.marker == "_" && i > 0 && isWordChar(p.text[i-1]) {
+		return -1
+	}
+
+	innerEnd := p.findClose(innerStart, end, d.marker)
+	if innerEnd <= innerStart {
+		return -1
+	}
+
+	next := innerEnd + len(d.marker)
+	p.add(d.entityType, i, next, innerStart, innerEnd)
+	p.parse(innerStart, innerEnd, depth+1, inLink)
+	return next
+}
+
+// findClose finds the closing marker for an emphasis that starts at from,
+// skipping over escapes, code and doubled markers nested inside.
+func (p *parser) findClose(from, end int, marker string) int {
+	for j := from; j < end; {
+		c := p.text[j]
+
+		if c == '\\' && j+1 < end && strings.ContainsRune(escapable, p.text[j+1]) {
+			j += 2
+			continue
+		}
+		if c == '`' {
+			if closeStart, n := p.codeSpan(j, end); closeStart > 0 {
+				j = closeStart + n
+				continue
+			}
+		}
+
+		// A single * or _ followed by another is the start or end of a
+		// nested double marker, like the bold in *a **b** c*
+		if len(marker) == 1 && c == rune(marker[0]) && j+1 < end && p.text[j+1] == c {
+			if closeStart := p.findClose(j+2, end, marker+marker); closeStart > 0 {
+				j = closeStart + 2
+			} else {
+				j += 2
+			}
+			continue
+		}
+
+		if !p.hasPrefix(j, end, marker) || unicode.IsSpace(p.text[j-1]) {
+			j++
+			continue
+		}
+
+		// Close on the last marker of a run, so that ***a*** is bold around
+		// italic rather than the other way round
+		for j+len(marker) < end && p.text[j+len(marker)] == c {
+			j++
+		}
+
+		if marker == "_" && j+1 < end && isWordChar(p.text[j+1]) {
+			j++
+			continue
+		}
+
+		return j
+	}
+
+	return -1
+}
+
+// codeSpan finds the end of a code span opened by the run of backticks at
+// i, returning where the closing run starts and the run length. The closing
+// run has to be exactly as long as the opening one.
+func (p *parser) codeSpan(i, end int) (int, int) {
+	n := p.backtickRun(i, end)
+	for j := i + n; j < end; {
+		if p.text[j] != '`' {
+			j++
+			continue
+		}
+
+		m := p.backtickRun(j, end)
+		if m == n {
+			return j, n
+		}
+		j += m
+	}
+
+	return -1, n
+}
+
+func (p *parser) inlineCode(i, end int) int {
+	closeStart, n := p.codeSpan(i, end)
+	if closeStart < 0 || closeStart == i+n {
+		// Unmatched backticks are literal
+		return i + n
+	}
+
+	p.add(models.CodeEntity, i, closeStart+n, i+n, closeStart)
+	return closeStart + n
+}
+
+func (p *parser) codeBlock(i, end int) int {
+	innerStart := i + 3
+
+	// An info string on the opening line names the language
+	var language string
+	if newline := p.indexRune(innerStart, end, '\n'); newline > 0 {
+		info := strings.TrimSpace(string(p.text[innerStart:newline]))
+		if info == "" || languageRegex.MatchString(info) {
+			language = info
+			innerStart = newline + 1
+		}
+	}
+
+	for j := innerStart; j+3 <= end; j++ {
+		if p.hasPrefix(j, end, "```") {
+			entity := p.add(models.CodeBlockEntity, i, j+3, innerStart, j)
+			entity.Language = language
+			return j + 3
+		}
+	}
+
+	return -1
+}
+
+// angle parses mentions and custom emoji, which are all wrapped in angle
+// brackets.
+func (p *parser) angle(i, end int) int {
+	closing := p.indexRune(i+1, min(end, i+100), '>')
+	if closing < 0 {
+		return -1
+	}
+	body := string(p.text[i+1 : closing])
+	next := closing + 1
+
+	switch {
+	case strings.HasPrefix(body, "@"):
+		if userIDRegex.MatchString(body[1:]) {
+			p.add(models.UserMentionEntity, i, next, i, next).UserID = body[1:]
+			return next
+		}
+	case strings.HasPrefix(body, "#"):
+		if roomID, err := strconv.ParseUint(body[1:], 10, 32); err == nil && roomID > 0 {
+			p.add(models.RoomMentionEntity, i, next, i, next).RoomID = uint(roomID)
+			return next
+		}
+	case strings.HasPrefix(body, ":"):
+		name, id, ok := strings.Cut(body[1:], ":")
+		if _, err := strconv.ParseUint(id, 10, 64); ok && err == nil &&
+			emojiNameRegex.MatchString(name) {
+			entity := p.add(models.CustomEmojiEntity, i, next, i, next)
+			entity.EmojiName = name
+			entity.EmojiID = id
+			return next
+		}
+	}
+
+	return -1
+}
+
+func (p *parser) massMention(i, end int) int {
+	for _, mention := range []struct {
+		text       string
+		entityType models.EntityType
+	}{
+		{"@everyone", models.EveryoneMentionEntity},
+		{"@here", models.HereMentionEntity},
+	} {
+		next := i + len(mention.text)
+		if p.hasPrefix(i, end, mention.text) && (next == end || !isWordChar(p.text[next])) {
+			p.add(mention.entityType, i, next, i, next)
+			return next
+		}
+	}
+
+	return -1
+}
+
+func (p *parser) link(i, end, depth int) int {
+	textEnd := -1
+	for j := i + 1; j < end; j++ {
+		if p.text[j] == '\\' {
+			j++
+			continue
+		}
+		if p.text[j] == '[' || p.text[j] == '\n' {
+			return -1
+		}
+		if p.text[j] == ']' {
+			textEnd = j
+			break
+		}
+	}
+	if textEnd <= i+1 || textEnd+1 >= end || p.text[textEnd+1] != '(' {
+		return -1
+	}
+
+	// Parentheses in the URL have to be balanced, as in Wikipedia links
+	urlStart := textEnd + 2
+	urlEnd := -1
+	for j, open := urlStart, 0; j < end && urlEnd < 0; j++ {
+		switch p.text[j] {
+		case '(':
+			open++
+		case ')':
+			if open == 0 {
+				urlEnd = j
+			}
+			open--
+		case ' ', '\n', '\t':
+			return -1
+		}
+	}
+	if urlEnd < 0 {
+		return -1
+	}
+
+	rawURL := string(p.text[urlStart:urlEnd])
+	if !isWebURL(rawURL) {
+		return -1
+	}
+
+	next := urlEnd + 1
+	p.add(models.LinkEntity, i, next, i+1, textEnd).URL = rawURL
+	p.parse(i+1, textEnd, depth+1, true)
+	return next
+}
+
+func (p *parser) autolink(i, end int) int {
+	if !p.hasPrefix(i, end, "http://") && !p.hasPrefix(i, end, "https://") {
+		return -1
+	}
+
+	j := i
+	for j < end && !unicode.IsSpace(p.text[j]) && p.text[j] != '<' && p.text[j] != '>' {
+		j++
+	}
+
+	rawURL := TrimTrailingPunctuation(string(p.text[i:j]))
+	if !isWebURL(rawURL) {
+		return -1
+	}
+
+	next := i + len([]rune(rawURL))
+	p.add(models.LinkEntity, i, next, i, next).URL = rawURL
+	return next
+}
+
+// TrimTrailingPunctuation drops punctuation that ends the sentence a link is
+// in rather than the link itself. Closing parentheses are only kept when
+// they're balanced, as in Wikipedia links.
+func TrimTrailingPunctuation(rawURL string) string {
+	for rawURL != "" {
+		last := rawURL[len(rawURL)-1]
+		switch {
+		case strings.IndexByte(".,:;!?'\"*_~|", last) != -1:
+			rawURL = rawURL[:len(rawURL)-1]
+		case last == ')' && strings.Count(rawURL, "(") < strings.Count(rawURL, ")"):
+			rawURL = rawURL[:len(rawURL)-1]
+		default:
+			return rawURL
+		}
+	}
+	return rawURL
+}
+
+func isWebURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// add records an entity using rune offsets, which toUTF16 converts once
+// parsing is done.
+func (p *parser) add(
+	entityType models.EntityType,
+	start, end, innerStart, innerEnd int,
+) *models.Entity {
+	p.entities = append(p.entities, models.Entity{
+		Type:        entityType,
+		Offset:      start,
+		Length:      end - start,
+		InnerOffset: innerStart,
+		InnerLength: innerEnd - innerStart,
+	})
+	return &p.entities[len(p.entities)-1]
+}
+
+func (p *parser) toUTF16() []models.Entity {
+	if len(p.entities) == 0 {
+		return nil
+	}
+
+	// offsets[i] is the UTF-16 offset of rune i
+	offsets := make([]int, len(p.text)+1)
+	for i, r := range p.text {
+		offsets[i+1] = offsets[i] + utf16.RuneLen(r)
+	}
+
+	for i := range p.entities {
+		entity := &p.entities[i]
+		start, innerStart := entity.Offset, entity.InnerOffset
+		entity.Offset = offsets[start]
+		entity.Length = offsets[start+entity.Length] - entity.Offset
+		entity.InnerOffset = offsets[innerStart]
+		entity.InnerLength = offsets[innerStart+entity.InnerLength] - entity.InnerOffset
+	}
+
+	return p.entities
+}
+
+func (p *parser) hasPrefix(i, end int, prefix string) bool {
+	for _, r := range prefix {
+		if i >= end || p.text[i] != r {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+func (p *parser) atLineStart(i int) bool {
+	return i == 0 || p.text[i-1] == '\n'
+}
+
+func (p *parser) backtickRun(i, end int) int {
+	n := 0
+	for i+n < end && p.text[i+n] == '`' {
+		n++
+	}
+	return n
+}
+
+func (p *parser) indexRune(from, end int, r rune) int {
+	for j := from; j < end; j++ {
+		if p.text[j] == r {
+			return j
+		}
+	}
+	return -1
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// Escape backslash-escapes the characters in text that Parse would treat as
+// markup, so that it's shown as is.
+func Escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(escapable, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
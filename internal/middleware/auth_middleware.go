@@ -1,67 +1,25 @@
 package middleware
 
 import (
-	"context"
-	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
-	"time"
 
-	"github.com/charmbracelet/log"
-	"github.com/clerk/clerk-sdk-go/v2"
-	"github.com/clerk/clerk-sdk-go/v2/jwks"
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
 
+	"github.com/teamyapchat/yapchat-server/internal/models"
 	"github.com/teamyapchat/yapchat-server/internal/services"
 	"github.com/teamyapchat/yapchat-server/internal/utils"
 )
 
-type JWKStore struct {
-	jwksClient  *jwks.Client
-	redisClient *redis.Client
-}
-
-func NewJWKStore(clerkSecretKey string, redisClient *redis.Client) *JWKStore {
-	config := &clerk.ClientConfig{}
-	config.Key = clerk.String(clerkSecretKey)
-
-	return &JWKStore{
-		jwksClient:  jwks.NewClient(config),
-		redisClient: redisClient,
-	}
-}
-
-func (s *JWKStore) GetJWK(keyID string) *clerk.JSONWebKey {
-	ctx := context.Background()
-	jwkData, err := s.redisClient.Get(ctx, "jwks:"+keyID).Bytes()
-	if err != nil {
-		return nil
-	}
-
-	var jwk clerk.JSONWebKey
-	if err := json.Unmarshal(jwkData, &jwk); err != nil {
-		return nil
-	}
-
-	return &jwk
-}
+// BotRoutes maps the routes bots may use, as the method and path they were
+// registered with ("GET /v1/chatrooms/:id"), to the scope a bot's token needs
+// for them. Bots can't use any other route.
+type BotRoutes map[string]models.BotScope
 
-func (s *JWKStore) SetJWK(keyID string, jwk *clerk.JSONWebKey) {
-	ctx := context.Background()
-	jwkData, err := json.Marshal(jwk)
-	if err != nil {
-		log.Error("Failed to serialize JWK", "err", err.Error())
-		return
-	}
-
-	err = s.redisClient.Set(ctx, "jwks:"+keyID, jwkData, time.Hour).Err()
-	if err != nil {
-		log.Error("Failed to cache JWK", "err", err.Error())
-	}
-}
-
-func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
+// AuthMiddleware accepts user session tokens ("Bearer <token>") and bot
+// tokens ("Bot <token>"), setting userID to the user or bot's ID.
+func AuthMiddleware(authService *services.AuthService, botRoutes BotRoutes) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -72,8 +30,32 @@ func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		sessionToken := strings.TrimPrefix(authHeader, "Bearer ")
-		if sessionToken == authHeader {
+		var userID string
+		var err error
+		if sessionToken, ok := strings.CutPrefix(authHeader, "Bearer "); ok {
+			userID, err = authService.VerifyToken(c.Request.Context(), sessionToken)
+		} else if botToken, ok := strings.CutPrefix(authHeader, "Bot "); ok {
+			var token *models.BotToken
+			token, err = authService.VerifyBotToken(botToken)
+			if err == nil {
+				scope, allowed := botRoutes[c.Request.Method+" "+c.FullPath()]
+				if !allowed {
+					c.AbortWithStatusJSON(
+						http.StatusForbidden,
+						utils.NewErrorResponse("Bots can't use this endpoint"),
+					)
+					return
+				}
+				if !token.HasScope(scope) {
+					c.AbortWithStatusJSON(
+						http.StatusForbidden,
+						utils.NewErrorResponse("Bot token is missing the "+string(scope)+" scope"),
+					)
+					return
+				}
+				userID = token.BotID
+			}
+		} else {
 			c.AbortWithStatusJSON(
 				http.StatusUnauthorized,
 				utils.NewErrorResponse("Invalid token format"),
@@ -81,20 +63,25 @@ func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		usr, err := authService.VerifyToken(c.Request.Context(), sessionToken)
 		if err != nil {
-			if err.Error() == "invalid token" {
+			if errors.Is(err, services.ErrInvalidToken) {
 				c.AbortWithStatusJSON(
 					http.StatusUnauthorized,
 					utils.NewErrorResponse("Invalid token"),
 				)
 				return
-			} else if err.Error() == "invalid or expired token" {
+			} else if errors.Is(err, services.ErrUnverifiedToken) {
 				c.AbortWithStatusJSON(
 					http.StatusUnauthorized,
 					utils.NewErrorResponse("Invalid or expired token"),
 				)
 				return
+			} else if errors.Is(err, services.ErrUserNotFound) {
+				c.AbortWithStatusJSON(
+					http.StatusUnauthorized,
+					utils.NewErrorResponse("User not found"),
+				)
+				return
 			} else {
 				c.AbortWithStatusJSON(
 					http.StatusInternalServerError,
@@ -104,7 +91,7 @@ func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 			}
 		}
 
-		c.Set("userID", usr.ID)
+		c.Set("userID", userID)
 		c.Next()
 	}
 }
@@ -19,6 +19,8 @@ type RateLimiter struct {
 type RateLimitConfig struct {
 	Limit  int
 	Window time.Duration
+	// Key returns what requests are counted by, the client's IP if unset
+	Key func(c *gin.Context) string
 }
 
 func NewRateLimiter(redisClient *redis.Client) *RateLimiter {
@@ -41,6 +43,9 @@ func (rl *RateLimiter) Middleware(name string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := context.Background()
 		key := c.ClientIP()
+		if config.Key != nil {
+			key = config.Key(c)
+		}
 		redisKey := fmt.Sprintf("ratelimit:%s:%s", name, key)
 
 		// Increment the request count
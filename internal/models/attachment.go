@@ -0,0 +1,37 @@
+package models
+
+import "gorm.io/gorm"
+
+// Attachment is uploaded before the message it belongs to is sent, so
+// MessageID stays empty until a dispatch claims it.
+type Attachment struct {
+	gorm.Model
+	MessageID   *uint  `gorm:"index"`
+	RoomID      uint   `gorm:"index"`
+	UploaderID  string `gorm:"type:varchar(255);index"`
+	Filename    string `gorm:"type:varchar(255)"`
+	ContentType string `gorm:"type:varchar(127)"`
+	Size        int64
+	StorageKey  string `gorm:"type:varchar(255);uniqueIndex"`
+
+	// Only set for images
+	Width      int
+	Height     int
+	BlurHash   string                `gorm:"type:varchar(64)"`
+	Thumbnails []AttachmentThumbnail `gorm:"type:json;serializer:json"`
+
+	// Only set for voice messages
+	IsVoiceMessage bool
+	DurationMs     int
+	Waveform       []byte `gorm:"type:varbinary(256)"`
+}
+
+// AttachmentThumbnail is a scaled down copy of an image attachment that fits
+// within a Size by Size square.
+type AttachmentThumbnail struct {
+	Size        int    `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ContentType string `json:"content_type"`
+	Length      int64  `json:"length"`
+}
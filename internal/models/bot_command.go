@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+type CommandOptionType string
+
+const (
+	StringOption  CommandOptionType = "string"
+	IntegerOption CommandOptionType = "integer"
+	BooleanOption CommandOptionType = "boolean"
+	// A user mentioned as <@id>
+	UserOption CommandOptionType = "user"
+)
+
+var CommandOptionTypes = []CommandOptionType{
+	StringOption,
+	IntegerOption,
+	BooleanOption,
+	UserOption,
+}
+
+// CommandOption is an argument a command takes. Arguments are given in order,
+// separated by spaces, except that a string as the last option takes the
+// rest of the line.
+type CommandOption struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Type        CommandOptionType `json:"type"`
+	Required    bool              `json:"required"`
+}
+
+// BotCommand is a slash command a bot has registered, which anyone in a room
+// with the bot can invoke.
+type BotCommand struct {
+	ID          uint            `gorm:"primarykey"`
+	BotID       string          `gorm:"type:varchar(255);uniqueIndex:idx_bot_commands_name,priority:1"`
+	Name        string          `gorm:"type:varchar(32);uniqueIndex:idx_bot_commands_name,priority:2"`
+	Description string          `gorm:"type:varchar(100)"`
+	Options     []CommandOption `gorm:"type:json;serializer:json"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
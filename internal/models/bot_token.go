@@ -0,0 +1,48 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// BotScope is something a bot token allows its bot to do.
+type BotScope string
+
+const (
+	BotScopeUsersRead BotScope = "users:read"
+	BotScopeRoomsRead BotScope = "rooms:read"
+	// Joining rooms with an invite code, and leaving them
+	BotScopeRoomsJoin BotScope = "rooms:join"
+	// Reading message history and connecting to the gateway
+	BotScopeMessagesRead BotScope = "messages:read"
+	// Sending messages over the gateway and uploading attachments
+	BotScopeMessagesWrite BotScope = "messages:write"
+	// Registering the bot's own slash commands
+	BotScopeCommandsWrite BotScope = "commands:write"
+)
+
+var BotScopes = []BotScope{
+	BotScopeUsersRead,
+	BotScopeRoomsRead,
+	BotScopeRoomsJoin,
+	BotScopeMessagesRead,
+	BotScopeMessagesWrite,
+	BotScopeCommandsWrite,
+}
+
+// BotToken is a long-lived token a bot authenticates with. Only a SHA-256
+// hash of the token is stored, so it can't be shown again after it's issued.
+type BotToken struct {
+	ID         uint       `gorm:"primarykey"`
+	BotID      string     `gorm:"type:varchar(255);index"`
+	Name       string     `gorm:"type:varchar(64)"`
+	Hash       string     `gorm:"type:char(64);uniqueIndex"`
+	Scopes     []BotScope `gorm:"type:json;serializer:json"`
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (t *BotToken) HasScope(scope BotScope) bool {
+	return slices.Contains(t.Scopes, scope)
+}
@@ -14,7 +14,28 @@ const (
 type ChatRoom struct {
 	gorm.Model
 	Name         string       `gorm:"varchar(255);index"`
+	Topic        string       `gorm:"type:varchar(255)"`
 	Type         ChatRoomType `gorm:"type:enum('dm', 'group');default:'dm'"`
+	OwnerID      string       `gorm:"type:varchar(255);index"`
 	Participants []*User      `gorm:"many2many:chat_room_participants;"`
 	ImageURL     string       `gorm:"varchar(255)"`
+	IconKey      string       `gorm:"type:varchar(255)"`
+}
+
+// IsModerator reports whether the user can moderate the room. For now that's
+// only the owner, and rooms created before owners were recorded have none.
+func (c *ChatRoom) IsModerator(userID string) bool {
+	return c.OwnerID != "" && c.OwnerID == userID
+}
+
+// CanMentionEveryone reports whether the user can use @everyone and @here.
+// Everyone in a DM is already notified, so it only matters in groups.
+func (c *ChatRoom) CanMentionEveryone(userID string) bool {
+	return c.Type == DirectMessageRoom || c.IsModerator(userID)
+}
+
+// CanPin reports whether the user can pin and unpin messages. Either side of
+// a DM can, since there's nobody else to moderate it.
+func (c *ChatRoom) CanPin(userID string) bool {
+	return c.Type == DirectMessageRoom || c.IsModerator(userID)
 }
@@ -0,0 +1,22 @@
+package models
+
+type EmbedType string
+
+const (
+	LinkEmbed  EmbedType = "link"
+	ImageEmbed EmbedType = "image"
+)
+
+// Embed is a preview of a link in a message, built from the OpenGraph and
+// Twitter card metadata of the page it points to.
+type Embed struct {
+	Type        EmbedType `json:"type"`
+	URL         string    `json:"url"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	SiteName    string    `json:"site_name,omitempty"`
+	ImageURL    string    `json:"image_url,omitempty"`
+	ImageWidth  int       `json:"image_width,omitempty"`
+	ImageHeight int       `json:"image_height,omitempty"`
+	ThemeColor  string    `json:"theme_color,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+type EntityType string
+
+const (
+	BoldEntity            EntityType = "bold"
+	ItalicEntity          EntityType = "italic"
+	UnderlineEntity       EntityType = "underline"
+	StrikethroughEntity   EntityType = "strikethrough"
+	SpoilerEntity         EntityType = "spoiler"
+	CodeEntity            EntityType = "code"
+	CodeBlockEntity       EntityType = "code_block"
+	LinkEntity            EntityType = "link"
+	UserMentionEntity     EntityType = "user_mention"
+	EveryoneMentionEntity EntityType = "everyone_mention"
+	HereMentionEntity     EntityType = "here_mention"
+	RoomMentionEntity     EntityType = "room_mention"
+	CustomEmojiEntity     EntityType = "custom_emoji"
+)
+
+// Entity marks a formatted span of a message's content. Offset and Length
+// cover the whole span including its markup, while InnerOffset and
+// InnerLength cover the text that's displayed, which may hold nested
+// entities. All of them count UTF-16 code units, like JavaScript strings.
+type Entity struct {
+	Type        EntityType `json:"type"`
+	Offset      int        `json:"offset"`
+	Length      int        `json:"length"`
+	InnerOffset int        `json:"inner_offset"`
+	InnerLength int        `json:"inner_length"`
+	URL         string     `json:"url,omitempty"`
+	Language    string     `json:"language,omitempty"`
+	UserID      string     `json:"user_id,omitempty"`
+	RoomID      uint       `json:"room_id,omitempty"`
+	EmojiName   string     `json:"emoji_name,omitempty"`
+	EmojiID     string     `json:"emoji_id,omitempty"`
+}
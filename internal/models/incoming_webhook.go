@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IncomingWebhook lets other systems post messages into a room by URL. Its
+// messages are sent on behalf of the moderator who created it, under the
+// webhook's name. Only a SHA-256 hash of its token is stored.
+type IncomingWebhook struct {
+	ID        uint   `gorm:"primarykey"`
+	RoomID    uint   `gorm:"index"`
+	CreatorID string `gorm:"type:varchar(255)"`
+	Name      string `gorm:"type:varchar(80)"`
+	AvatarURL string `gorm:"type:varchar(255)"`
+	TokenHash string `gorm:"type:char(64)"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type MentionType string
+
+const (
+	UserMention     MentionType = "user"
+	HereMention     MentionType = "here"
+	EveryoneMention MentionType = "everyone"
+)
+
+// Mention is an entry in a user's inbox for a message that pinged them.
+type Mention struct {
+	ID        uint        `gorm:"primarykey"`
+	UserID    string      `gorm:"type:varchar(255);uniqueIndex:idx_mention_user_message;index:idx_mention_user_read"`
+	MessageID uint        `gorm:"uniqueIndex:idx_mention_user_message"`
+	Message   Message     `gorm:"foreignKey:MessageID"`
+	RoomID    uint        `gorm:"index"`
+	Type      MentionType `gorm:"type:enum('user', 'here', 'everyone')"`
+	ReadAt    *time.Time  `gorm:"index:idx_mention_user_read"`
+	CreatedAt time.Time
+}
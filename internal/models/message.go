@@ -6,12 +6,57 @@ import (
 	"gorm.io/gorm"
 )
 
+type MessageType string
+
+const (
+	UserMessage   MessageType = "user"
+	SystemMessage MessageType = "system"
+)
+
+// SystemEvent is what a system message is about, so that clients can render
+// it instead of showing its content.
+type SystemEvent string
+
+const (
+	MessagePinnedEvent SystemEvent = "message_pinned"
+	MemberJoinedEvent  SystemEvent = "member_joined"
+	MemberLeftEvent    SystemEvent = "member_left"
+	MemberKickedEvent  SystemEvent = "member_kicked"
+	RoomRenamedEvent   SystemEvent = "room_renamed"
+	TopicChangedEvent  SystemEvent = "topic_changed"
+)
+
+// Message is a chat message. System messages are sent by the server on
+// behalf of SenderID, the user who caused Event, and ReferenceID points at
+// the message the event is about, if any. Messages posted through a webhook
+// have its ID, and are shown with Username and AvatarURL instead of the
+// sender's.
 type Message struct {
 	gorm.Model
-	SenderID  string `gorm:"varchar(255);index"`
-	Sender    User   `gorm:"foreignKey:SenderID"`
-	RoomID    uint
-	Room      ChatRoom `gorm:"foreignKey:RoomID"`
-	Content   string
-	Timestamp time.Time
+	SenderID    string       `gorm:"varchar(255);index"`
+	Sender      User         `gorm:"foreignKey:SenderID"`
+	RoomID      uint         `gorm:"index:idx_messages_room_pinned,priority:1"`
+	Room        ChatRoom     `gorm:"foreignKey:RoomID"`
+	Type        MessageType  `gorm:"type:enum('user', 'system');default:'user'"`
+	Event       SystemEvent  `gorm:"type:varchar(32)"`
+	Content     string       `gorm:"index:,class:FULLTEXT"`
+	Entities    []Entity     `gorm:"type:json;serializer:json"`
+	Attachments []Attachment `gorm:"foreignKey:MessageID"`
+	Embeds      []Embed      `gorm:"type:json;serializer:json"`
+	PinnedAt    *time.Time   `gorm:"index:idx_messages_room_pinned,priority:2"`
+	PinnedByID  string       `gorm:"type:varchar(255)"`
+	WebhookID   *uint        `gorm:"index"`
+	Username    string       `gorm:"type:varchar(80)"`
+	AvatarURL   string       `gorm:"type:varchar(255)"`
+	ReferenceID *uint
+	Timestamp   time.Time
+}
+
+// SenderName returns the name to show for the message's sender, which must
+// have been loaded unless the message was posted through a webhook.
+func (m *Message) SenderName() string {
+	if m.WebhookID != nil {
+		return m.Username
+	}
+	return m.Sender.Name()
 }
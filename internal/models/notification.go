@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+type NotificationLevel string
+
+const (
+	NotifyDefault  NotificationLevel = "default"
+	NotifyAll      NotificationLevel = "all"
+	NotifyMentions NotificationLevel = "mentions"
+	NotifyNone     NotificationLevel = "none"
+)
+
+// RoomSettings are a user's preferences for one chat room. Users without a
+// row use their default notification level and aren't muted.
+type RoomSettings struct {
+	UserID        string            `gorm:"primarykey;type:varchar(255)"`
+	RoomID        uint              `gorm:"primarykey"`
+	Notifications NotificationLevel `gorm:"type:enum('default', 'all', 'mentions', 'none');default:'default'"`
+	MutedUntil    *time.Time
+	UpdatedAt     time.Time
+}
+
+func (s *RoomSettings) Muted(now time.Time) bool {
+	return s.MutedUntil != nil && s.MutedUntil.After(now)
+}
+
+// Notifies reports whether the user should be notified of a message, given
+// whether it mentions them. Notifications must already be resolved from
+// NotifyDefault to the user's default level.
+func (s *RoomSettings) Notifies(mentioned bool, now time.Time) bool {
+	if s.Muted(now) {
+		return false
+	}
+
+	switch s.Notifications {
+	case NotifyAll:
+		return true
+	case NotifyMentions:
+		return mentioned
+	default:
+		return false
+	}
+}
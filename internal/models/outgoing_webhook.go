@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+type WebhookEventType string
+
+const (
+	MessageCreatedWebhookEvent WebhookEventType = "message.created"
+	MemberJoinedWebhookEvent   WebhookEventType = "member.joined"
+	MemberLeftWebhookEvent     WebhookEventType = "member.left"
+)
+
+var WebhookEventTypes = []WebhookEventType{
+	MessageCreatedWebhookEvent,
+	MemberJoinedWebhookEvent,
+	MemberLeftWebhookEvent,
+}
+
+// OutgoingWebhook subscribes a URL to events, either in one room, when
+// RoomID is set, or in every room a bot is in, when BotID is.
+type OutgoingWebhook struct {
+	ID        uint               `gorm:"primarykey"`
+	RoomID    *uint              `gorm:"index"`
+	BotID     string             `gorm:"type:varchar(255);index"`
+	CreatorID string             `gorm:"type:varchar(255)"`
+	URL       string             `gorm:"type:varchar(2048)"`
+	Secret    string             `gorm:"type:varchar(64)"`
+	Events    []WebhookEventType `gorm:"type:json;serializer:json"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// Every attempt failed, leaving the delivery in the dead-letter list
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an event sent, or still to be sent, to an outgoing
+// webhook. MessageID identifies the event to the receiver and is the same
+// for every attempt. LeasedBy is the node that last claimed it, which is
+// sending it until NextAttemptAt.
+type WebhookDelivery struct {
+	ID             uint             `gorm:"primarykey"`
+	WebhookID      uint             `gorm:"index"`
+	MessageID      string           `gorm:"type:varchar(64)"`
+	Event          WebhookEventType `gorm:"type:varchar(32)"`
+	Payload        string           `gorm:"type:mediumtext"`
+	Status         DeliveryStatus   `gorm:"type:enum('pending', 'succeeded', 'failed');default:'pending';index:idx_webhook_deliveries_due,priority:1"`
+	NextAttemptAt  *time.Time       `gorm:"index:idx_webhook_deliveries_due,priority:2"`
+	LastError      string           `gorm:"type:varchar(255)"`
+	LeasedBy       string           `gorm:"type:varchar(255)"`
+	Attempts       int
+	LastStatusCode int
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
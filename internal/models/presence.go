@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+type PresenceStatus string
+
+const (
+	StatusOnline       PresenceStatus = "online"
+	StatusIdle         PresenceStatus = "idle"
+	StatusDoNotDisturb PresenceStatus = "dnd"
+	StatusInvisible    PresenceStatus = "invisible"
+	StatusOffline      PresenceStatus = "offline"
+)
+
+// Presence is stored in Redis rather than MySQL since it changes on every
+// connect and disconnect.
+type Presence struct {
+	UserID       string
+	Status       PresenceStatus
+	CustomStatus *CustomStatus
+	LastSeen     time.Time
+}
+
+type CustomStatus struct {
+	Text      string
+	Emoji     string
+	ExpiresAt *time.Time
+}
+
+func (s *CustomStatus) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !s.ExpiresAt.After(now)
+}
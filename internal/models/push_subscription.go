@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PushSubscription is a browser's Web Push subscription. The keys are kept
+// base64url encoded, as the browser hands them out.
+type PushSubscription struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    string `gorm:"type:varchar(255);index"`
+	Endpoint  string `gorm:"type:varchar(768);uniqueIndex"`
+	P256dh    string `gorm:"type:varchar(255)"`
+	Auth      string `gorm:"type:varchar(255)"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// ReadState marks how far a user has read in a chat room.
+type ReadState struct {
+	UserID        string `gorm:"primarykey;type:varchar(255)"`
+	RoomID        uint   `gorm:"primarykey"`
+	LastMessageID uint
+	UpdatedAt     time.Time
+}
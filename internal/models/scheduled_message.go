@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type ScheduledMessageStatus string
+
+const (
+	ScheduledPending ScheduledMessageStatus = "pending"
+	// The message couldn't be sent, and LastError says why
+	ScheduledFailed ScheduledMessageStatus = "failed"
+)
+
+// ScheduledMessage is a message a user has written to be sent later. It's
+// deleted once it has been sent, while those that couldn't be sent are kept
+// until the sender reschedules or cancels them. LeasedBy and LeasedUntil are
+// set while a node is sending it.
+type ScheduledMessage struct {
+	ID          uint                   `gorm:"primarykey"`
+	RoomID      uint                   `gorm:"index:idx_scheduled_messages_sender,priority:2"`
+	SenderID    string                 `gorm:"type:varchar(255);index:idx_scheduled_messages_sender,priority:1"`
+	Content     string                 `gorm:"type:text"`
+	SendAt      time.Time              `gorm:"index:idx_scheduled_messages_due,priority:2"`
+	Status      ScheduledMessageStatus `gorm:"type:enum('pending', 'failed');default:'pending';index:idx_scheduled_messages_due,priority:1"`
+	LastError   string                 `gorm:"type:varchar(255)"`
+	LeasedBy    string                 `gorm:"type:varchar(255)"`
+	LeasedUntil *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
@@ -6,13 +6,54 @@ import (
 	"gorm.io/gorm"
 )
 
+type DigestFrequency string
+
+const (
+	DigestNever  DigestFrequency = "never"
+	DigestDaily  DigestFrequency = "daily"
+	DigestWeekly DigestFrequency = "weekly"
+)
+
+// Interval returns how often a digest is sent, or zero for DigestNever.
+func (f DigestFrequency) Interval() time.Duration {
+	switch f {
+	case DigestDaily:
+		return 24 * time.Hour
+	case DigestWeekly:
+		return 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
 type User struct {
-	ID           string  `gorm:"primarykey;varchar(255)"`
-	Username     string  `gorm:"uniqueIndex;not null;type:varchar(24)"`
-	ImageURL     string  `gorm:"varchar(100)"`
-	IsOnline     bool    `gorm:"default:false"`
-	BlockedUsers []*User `gorm:"many2many:blocked_users"`
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	ID                   string            `gorm:"primarykey;varchar(255)"`
+	Username             string            `gorm:"uniqueIndex;not null;type:varchar(24)"`
+	ImageURL             string            `gorm:"varchar(255)"`
+	AvatarKey            string            `gorm:"type:varchar(255)"`
+	DisplayName          string            `gorm:"type:varchar(32)"`
+	Bio                  string            `gorm:"type:varchar(190)"`
+	Pronouns             string            `gorm:"type:varchar(40)"`
+	BannerURL            string            `gorm:"type:varchar(255)"`
+	AccentColor          string            `gorm:"type:varchar(7)"`
+	Discoverable         bool              `gorm:"default:true"`
+	DefaultNotifications NotificationLevel `gorm:"type:enum('all', 'mentions', 'none');default:'all'"`
+	Email                string            `gorm:"type:varchar(255)"`
+	DigestFrequency      DigestFrequency   `gorm:"type:enum('never', 'daily', 'weekly');default:'never'"`
+	Bot                  bool              `gorm:"default:false"`
+	OwnerID              string            `gorm:"type:varchar(255);index"` // The user who created the bot
+	BlockedUsers         []*User           `gorm:"many2many:blocked_users"`
+	LastDigestAt         *time.Time
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	DeletedAt            gorm.DeletedAt `gorm:"index"`
+}
+
+// Name returns the name to show for the user, their display name if they
+// have set one.
+func (u *User) Name() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return u.Username
 }
@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type AttachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+func (r *AttachmentRepository) Create(attachment *models.Attachment) error {
+	return r.db.Create(attachment).Error
+}
+
+func (r *AttachmentRepository) GetByID(id uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := r.db.First(&attachment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// FindUnclaimed returns the attachments among ids that were uploaded by the
+// user to the room and aren't part of a message yet.
+func (r *AttachmentRepository) FindUnclaimed(
+	ids []uint,
+	uploaderID string,
+	roomID uint,
+) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := r.db.
+		Where("id IN ? AND uploader_id = ? AND room_id = ?", ids, uploaderID, roomID).
+		Where("message_id IS NULL").
+		Find(&attachments).Error
+	return attachments, err
+}
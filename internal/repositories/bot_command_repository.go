@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type BotCommandRepository struct {
+	db *gorm.DB
+}
+
+func NewBotCommandRepository(db *gorm.DB) *BotCommandRepository {
+	return &BotCommandRepository{db: db}
+}
+
+// ListForBots returns the commands of the given bots, oldest first.
+func (r *BotCommandRepository) ListForBots(botIDs []string) ([]models.BotCommand, error) {
+	var commands []models.BotCommand
+	if len(botIDs) == 0 {
+		return commands, nil
+	}
+
+	err := r.db.Where("bot_id IN ?", botIDs).Order("id").Find(&commands).Error
+	return commands, err
+}
+
+// ReplaceForBot replaces all of a bot's commands. Commands that are kept
+// keep their IDs, so the order they were first registered in is kept too.
+func (r *BotCommandRepository) ReplaceForBot(
+	botID string,
+	commands []models.BotCommand,
+) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		names := make([]string, len(commands))
+		for i, command := range commands {
+			names[i] = command.Name
+		}
+
+		query := tx.Where("bot_id = ?", botID)
+		if len(names) > 0 {
+			query = query.Where("name NOT IN ?", names)
+		}
+		if err := query.Delete(&models.BotCommand{}).Error; err != nil {
+			return err
+		}
+
+		for i := range commands {
+			var existing models.BotCommand
+			err := tx.Where("bot_id = ? AND name = ?", botID, commands[i].Name).
+				Limit(1).
+				Find(&existing).Error
+			if err != nil {
+				return err
+			}
+
+			commands[i].ID = existing.ID
+			commands[i].CreatedAt = existing.CreatedAt
+			if err := tx.Save(&commands[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *BotCommandRepository) DeleteForBot(botID string) error {
+	return r.db.Where("bot_id = ?", botID).Delete(&models.BotCommand{}).Error
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type BotTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewBotTokenRepository(db *gorm.DB) *BotTokenRepository {
+	return &BotTokenRepository{db: db}
+}
+
+func (r *BotTokenRepository) Create(token *models.BotToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *BotTokenRepository) FindByHash(hash string) (*models.BotToken, error) {
+	var token models.BotToken
+	err := r.db.Where("hash = ?", hash).First(&token).Error
+
+	return &token, err
+}
+
+func (r *BotTokenRepository) ListForBot(botID string) ([]models.BotToken, error) {
+	var tokens []models.BotToken
+	err := r.db.Where("bot_id = ?", botID).Order("id").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *BotTokenRepository) CountForBot(botID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.BotToken{}).Where("bot_id = ?", botID).Count(&count).Error
+	return count, err
+}
+
+// UpdateHash replaces a token's hash, reporting whether the bot has a token
+// with that ID.
+func (r *BotTokenRepository) UpdateHash(id uint, botID, hash string) (bool, error) {
+	result := r.db.Model(&models.BotToken{}).
+		Where("id = ? AND bot_id = ?", id, botID).
+		Updates(map[string]any{"hash": hash, "last_used_at": nil})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *BotTokenRepository) UpdateLastUsedAt(id uint, lastUsedAt time.Time) error {
+	return r.db.Model(&models.BotToken{}).
+		Where("id = ?", id).
+		Update("last_used_at", lastUsedAt).
+		Error
+}
+
+// Delete removes a token, reporting whether the bot had a token with that
+// ID.
+func (r *BotTokenRepository) Delete(id uint, botID string) (bool, error) {
+	result := r.db.Where("id = ? AND bot_id = ?", id, botID).Delete(&models.BotToken{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *BotTokenRepository) DeleteForBot(botID string) error {
+	return r.db.Where("bot_id = ?", botID).Delete(&models.BotToken{}).Error
+}
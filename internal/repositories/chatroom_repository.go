@@ -39,10 +39,55 @@ func (r *ChatRoomRepository) List(userID string) ([]*models.ChatRoom, error) {
 	return chatrooms, nil
 }
 
+func (r *ChatRoomRepository) ListIDs(userID string) ([]uint, error) {
+	var ids []uint
+	err := r.db.Table("chat_room_participants").
+		Joins("JOIN chat_rooms ON chat_rooms.id = chat_room_participants.chat_room_id").
+		Where("chat_room_participants.user_id = ? AND chat_rooms.deleted_at IS NULL", userID).
+		Pluck("chat_rooms.id", &ids).Error
+	return ids, err
+}
+
+func (r *ChatRoomRepository) IsParticipant(chatroomID uint, userID string) (bool, error) {
+	var count int64
+	err := r.db.Table("chat_room_participants").
+		Joins("JOIN chat_rooms ON chat_rooms.id = chat_room_participants.chat_room_id").
+		Where("chat_rooms.deleted_at IS NULL").
+		Where(
+			"chat_room_participants.chat_room_id = ? AND chat_room_participants.user_id = ?",
+			chatroomID,
+			userID,
+		).
+		Count(&count).Error
+	return count > 0, err
+}
+
 func (r *ChatRoomRepository) Update(chatroom *models.ChatRoom) error {
 	return r.db.Save(chatroom).Error
 }
 
+func (r *ChatRoomRepository) UpdateIcon(chatroom *models.ChatRoom) error {
+	return r.db.Model(&models.ChatRoom{}).
+		Where("id = ?", chatroom.ID).
+		Select("image_url", "icon_key").
+		Updates(chatroom).
+		Error
+}
+
+func (r *ChatRoomRepository) UpdateName(chatroom *models.ChatRoom) error {
+	return r.db.Model(&models.ChatRoom{}).
+		Where("id = ?", chatroom.ID).
+		Update("name", chatroom.Name).
+		Error
+}
+
+func (r *ChatRoomRepository) UpdateTopic(chatroom *models.ChatRoom) error {
+	return r.db.Model(&models.ChatRoom{}).
+		Where("id = ?", chatroom.ID).
+		Update("topic", chatroom.Topic).
+		Error
+}
+
 func (r *ChatRoomRepository) Delete(id uint) error {
 	return r.db.Delete(&models.ChatRoom{}, id).Error
 }
@@ -64,3 +109,17 @@ func (r *ChatRoomRepository) RemoveParticipant(chatroomID uint, userID string) e
 	user := &models.User{ID: userID}
 	return r.db.Model(chatroom).Association("Participants").Delete(user)
 }
+
+// ListCoParticipantIDs returns the IDs of every user who shares at least one
+// chat room with the given user.
+func (r *ChatRoomRepository) ListCoParticipantIDs(userID string) ([]string, error) {
+	var ids []string
+	err := r.db.Table("chat_room_participants AS self").
+		Distinct("other.user_id").
+		Joins("JOIN chat_room_participants AS other ON other.chat_room_id = self.chat_room_id").
+		Joins("JOIN chat_rooms ON chat_rooms.id = self.chat_room_id").
+		Where("chat_rooms.deleted_at IS NULL").
+		Where("self.user_id = ? AND other.user_id <> ?", userID, userID).
+		Pluck("other.user_id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type IncomingWebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewIncomingWebhookRepository(db *gorm.DB) *IncomingWebhookRepository {
+	return &IncomingWebhookRepository{db: db}
+}
+
+func (r *IncomingWebhookRepository) Create(webhook *models.IncomingWebhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *IncomingWebhookRepository) GetByID(id uint) (*models.IncomingWebhook, error) {
+	var webhook models.IncomingWebhook
+	err := r.db.Where("id = ?", id).First(&webhook).Error
+
+	return &webhook, err
+}
+
+func (r *IncomingWebhookRepository) ListForRoom(roomID uint) ([]models.IncomingWebhook, error) {
+	var webhooks []models.IncomingWebhook
+	err := r.db.Where("room_id = ?", roomID).Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *IncomingWebhookRepository) CountForRoom(roomID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.IncomingWebhook{}).Where("room_id = ?", roomID).Count(&count).Error
+	return count, err
+}
+
+// Delete removes a webhook, reporting whether the room had a webhook with
+// that ID.
+func (r *IncomingWebhookRepository) Delete(id, roomID uint) (bool, error) {
+	result := r.db.Where("id = ? AND room_id = ?", id, roomID).Delete(&models.IncomingWebhook{})
+	return result.RowsAffected > 0, result.Error
+}
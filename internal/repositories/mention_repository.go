@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type MentionRepository struct {
+	db *gorm.DB
+}
+
+func NewMentionRepository(db *gorm.DB) *MentionRepository {
+	return &MentionRepository{db: db}
+}
+
+func (r *MentionRepository) CreateBatch(mentions []models.Mention) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&mentions).Error
+}
+
+// ListForUser returns a page of the user's mentions, newest first, starting
+// after the mention with ID cursor if it isn't zero.
+func (r *MentionRepository) ListForUser(
+	userID string,
+	unreadOnly bool,
+	cursor uint,
+	limit int,
+) ([]models.Mention, error) {
+	query := r.forUser(userID).Preload("Message")
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	if cursor != 0 {
+		query = query.Where("id < ?", cursor)
+	}
+
+	var mentions []models.Mention
+	err := query.Order("id desc").Limit(limit).Find(&mentions).Error
+	return mentions, err
+}
+
+// ListUnreadSince returns the user's unread mentions made after since,
+// oldest first, along with their messages' senders and rooms.
+func (r *MentionRepository) ListUnreadSince(
+	userID string,
+	since time.Time,
+	limit int,
+) ([]models.Mention, error) {
+	var mentions []models.Mention
+	err := r.forUser(userID).
+		Preload("Message.Sender").
+		Preload("Message.Room").
+		Where("read_at IS NULL AND created_at > ?", since).
+		Order("id").
+		Limit(limit).
+		Find(&mentions).Error
+	return mentions, err
+}
+
+// MarkRead marks one of the user's mentions as read, returning whether it
+// exists.
+func (r *MentionRepository) MarkRead(userID string, id uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Mention{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Count(&count).Error
+	if err != nil || count == 0 {
+		return false, err
+	}
+
+	err = r.db.Model(&models.Mention{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", id, userID).
+		Update("read_at", time.Now()).Error
+	return true, err
+}
+
+// forUser scopes a query to the user's mentions, skipping those from rooms
+// the user has since left.
+func (r *MentionRepository) forUser(userID string) *gorm.DB {
+	return r.db.
+		Where("user_id = ?", userID).
+		Where(
+			"room_id IN (?)",
+			r.db.Table("chat_room_participants").
+				Select("chat_room_id").
+				Where("user_id = ?", userID),
+		)
+}
+
+// MarkAllRead marks all of the user's mentions as read, only in one room if
+// roomID isn't zero.
+func (r *MentionRepository) MarkAllRead(userID string, roomID uint) error {
+	query := r.db.Model(&models.Mention{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if roomID != 0 {
+		query = query.Where("room_id = ?", roomID)
+	}
+	return query.Update("read_at", time.Now()).Error
+}
@@ -1,7 +1,10 @@
 package repositories
 
 import (
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/teamyapchat/yapchat-server/internal/models"
 )
@@ -18,9 +21,92 @@ func (r *MessageRepository) Create(message *models.Message) error {
 	return r.db.Create(message).Error
 }
 
+func (r *MessageRepository) UpdateEmbeds(id uint, embeds []models.Embed) error {
+	return r.db.Model(&models.Message{Model: gorm.Model{ID: id}}).
+		Update("embeds", embeds).
+		Error
+}
+
+func (r *MessageRepository) GetByID(id uint) (*models.Message, error) {
+	var message models.Message
+	err := r.db.Preload("Attachments").First(&message, id).Error
+	return &message, err
+}
+
+// SetPinned pins or unpins a message in a room, reporting false if it was
+// already in that state.
+func (r *MessageRepository) SetPinned(
+	id, roomID uint,
+	pinnedByID string,
+	pinnedAt *time.Time,
+) (bool, error) {
+	query := r.db.Model(&models.Message{}).Where("id = ? AND room_id = ?", id, roomID)
+	if pinnedAt != nil {
+		query = query.Where("pinned_at IS NULL")
+	} else {
+		query = query.Where("pinned_at IS NOT NULL")
+	}
+
+	result := query.Updates(map[string]any{"pinned_at": pinnedAt, "pinned_by_id": pinnedByID})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Pin pins a message in a room unless the room already has limit pinned
+// messages, reporting whether it was pinned and whether the room was full.
+// The room's row is locked while its pins are counted, so that pinning
+// several messages at once can't go over the limit.
+func (r *MessageRepository) Pin(
+	id, roomID uint,
+	pinnedByID string,
+	pinnedAt time.Time,
+	limit int,
+) (pinned, full bool, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			Where("id = ?", roomID).
+			Take(&models.ChatRoom{}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		err = tx.Model(&models.Message{}).
+			Where("room_id = ? AND pinned_at IS NOT NULL", roomID).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count >= int64(limit) {
+			full = true
+			return nil
+		}
+
+		result := tx.Model(&models.Message{}).
+			Where("id = ? AND room_id = ? AND pinned_at IS NULL", id, roomID).
+			Updates(map[string]any{"pinned_at": pinnedAt, "pinned_by_id": pinnedByID})
+		pinned = result.RowsAffected > 0
+		return result.Error
+	})
+	return pinned, full, err
+}
+
+// ListPinned returns the pinned messages in a room, most recently pinned
+// first.
+func (r *MessageRepository) ListPinned(roomID uint) ([]models.Message, error) {
+	var messages []models.Message
+	err := r.db.Preload("Attachments").
+		Where("room_id = ? AND pinned_at IS NOT NULL", roomID).
+		Order("pinned_at desc").
+		Find(&messages).Error
+	return messages, err
+}
+
 func (r *MessageRepository) GetByRoomID(roomID uint, limit, offset int) ([]models.Message, error) {
 	var messages []models.Message
-	err := r.db.Where("room_id = ?", roomID).
+	err := r.db.Preload("Attachments").
+		Where("room_id = ?", roomID).
 		Order("created_at desc").
 		Limit(limit).
 		Offset(offset).
@@ -33,3 +119,47 @@ func (r *MessageRepository) GetCountByRoomID(roomID uint) (int, error) {
 	err := r.db.Model(&models.Message{}).Where("room_id = ?", roomID).Count(&count).Error
 	return int(count), err
 }
+
+// ListUnreadDirect returns the messages others sent the user in DMs after
+// since that are past the user's read marker, oldest first. System messages
+// are left out.
+func (r *MessageRepository) ListUnreadDirect(
+	userID string,
+	since time.Time,
+	limit int,
+) ([]models.Message, error) {
+	var messages []models.Message
+	err := r.db.Preload("Sender").
+		Joins(
+			"JOIN chat_rooms ON chat_rooms.id = messages.room_id "+
+				"AND chat_rooms.type = ? AND chat_rooms.deleted_at IS NULL",
+			models.DirectMessageRoom,
+		).
+		Joins(
+			"JOIN chat_room_participants ON chat_room_participants.chat_room_id = messages.room_id "+
+				"AND chat_room_participants.user_id = ?",
+			userID,
+		).
+		Joins(
+			"LEFT JOIN read_states ON read_states.room_id = messages.room_id "+
+				"AND read_states.user_id = ?",
+			userID,
+		).
+		Where("messages.sender_id <> ? AND messages.created_at > ?", userID, since).
+		Where("messages.type = ?", models.UserMessage).
+		Where("messages.id > COALESCE(read_states.last_message_id, 0)").
+		Order("messages.id").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// FindInBatches calls fn with successive batches of every message, oldest
+// first.
+func (r *MessageRepository) FindInBatches(batchSize int, fn func([]models.Message) error) error {
+	var messages []models.Message
+	return r.db.Preload("Attachments").
+		FindInBatches(&messages, batchSize, func(tx *gorm.DB, batch int) error {
+			return fn(messages)
+		}).Error
+}
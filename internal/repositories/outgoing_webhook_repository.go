@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type OutgoingWebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewOutgoingWebhookRepository(db *gorm.DB) *OutgoingWebhookRepository {
+	return &OutgoingWebhookRepository{db: db}
+}
+
+func (r *OutgoingWebhookRepository) Create(webhook *models.OutgoingWebhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *OutgoingWebhookRepository) GetByID(id uint) (*models.OutgoingWebhook, error) {
+	var webhook models.OutgoingWebhook
+	err := r.db.Where("id = ?", id).First(&webhook).Error
+
+	return &webhook, err
+}
+
+func (r *OutgoingWebhookRepository) ListForRoom(roomID uint) ([]models.OutgoingWebhook, error) {
+	var webhooks []models.OutgoingWebhook
+	err := r.db.Where("room_id = ?", roomID).Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *OutgoingWebhookRepository) ListForBots(botIDs []string) ([]models.OutgoingWebhook, error) {
+	var webhooks []models.OutgoingWebhook
+	err := r.db.Where("bot_id IN ?", botIDs).Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// Delete removes a webhook along with its deliveries.
+func (r *OutgoingWebhookRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.OutgoingWebhook{}).Error
+	})
+}
+
+// DeleteForBot removes a bot's webhooks along with their deliveries.
+func (r *OutgoingWebhookRepository) DeleteForBot(botID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Where("webhook_id IN (?)", tx.Model(&models.OutgoingWebhook{}).
+				Select("id").
+				Where("bot_id = ?", botID)).
+			Delete(&models.WebhookDelivery{}).
+			Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("bot_id = ?", botID).Delete(&models.OutgoingWebhook{}).Error
+	})
+}
+
+func (r *OutgoingWebhookRepository) CreateDeliveries(deliveries []models.WebhookDelivery) error {
+	return r.db.Create(&deliveries).Error
+}
+
+func (r *OutgoingWebhookRepository) GetDelivery(
+	id, webhookID uint,
+) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.db.Where("id = ? AND webhook_id = ?", id, webhookID).First(&delivery).Error
+
+	return &delivery, err
+}
+
+// ListDeliveries returns a webhook's latest deliveries, only those with the
+// given status if it isn't empty.
+func (r *OutgoingWebhookRepository) ListDeliveries(
+	webhookID uint,
+	status models.DeliveryStatus,
+	limit int,
+) ([]models.WebhookDelivery, error) {
+	query := r.db.Where("webhook_id = ?", webhookID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ListDueDeliveries returns pending deliveries whose next attempt is due,
+// longest waiting first.
+func (r *OutgoingWebhookRepository) ListDueDeliveries(
+	now time.Time,
+	limit int,
+) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.
+		Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&deliveries).Error
+
+	return deliveries, err
+}
+
+// LeaseDeliveries marks deliveries as being sent by a node by moving their
+// next attempt to until, so they aren't picked up again in the meantime.
+func (r *OutgoingWebhookRepository) LeaseDeliveries(
+	ids []uint,
+	nodeID string,
+	until time.Time,
+) error {
+	return r.db.Model(&models.WebhookDelivery{}).
+		Where("id IN ?", ids).
+		Updates(map[string]any{"leased_by": nodeID, "next_attempt_at": until}).
+		Error
+}
+
+// RenewDeliveryLease extends a node's lease on a delivery, reporting false if
+// the lease has already run out, since the delivery may have been claimed
+// again.
+func (r *OutgoingWebhookRepository) RenewDeliveryLease(
+	id uint,
+	nodeID string,
+	now, until time.Time,
+) (bool, error) {
+	result := r.db.Model(&models.WebhookDelivery{}).
+		Where(
+			"id = ? AND status = ? AND leased_by = ? AND next_attempt_at > ?",
+			id, models.DeliveryPending, nodeID, now,
+		).
+		Update("next_attempt_at", until)
+
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateDelivery saves the outcome of an attempt.
+func (r *OutgoingWebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Model(&models.WebhookDelivery{}).
+		Where("id = ?", delivery.ID).
+		Select(
+			"status",
+			"attempts",
+			"next_attempt_at",
+			"last_status_code",
+			"last_error",
+			"delivered_at",
+		).
+		Updates(delivery).
+		Error
+}
+
+// DeleteDeliveredBefore removes successful deliveries older than before.
+func (r *OutgoingWebhookRepository) DeleteDeliveredBefore(before time.Time) error {
+	return r.db.
+		Where("status = ? AND created_at < ?", models.DeliverySucceeded, before).
+		Delete(&models.WebhookDelivery{}).
+		Error
+}
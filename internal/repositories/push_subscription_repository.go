@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type PushSubscriptionRepository struct {
+	db *gorm.DB
+}
+
+func NewPushSubscriptionRepository(db *gorm.DB) *PushSubscriptionRepository {
+	return &PushSubscriptionRepository{db: db}
+}
+
+// Save stores a subscription, taking the endpoint over if it was registered
+// before, since a browser keeps its endpoint when another user logs in.
+func (r *PushSubscriptionRepository) Save(subscription *models.PushSubscription) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "p256dh", "auth", "updated_at"}),
+	}).Create(subscription).Error
+}
+
+func (r *PushSubscriptionRepository) ListForUsers(
+	userIDs []string,
+) ([]models.PushSubscription, error) {
+	var subscriptions []models.PushSubscription
+	err := r.db.Where("user_id IN ?", userIDs).Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (r *PushSubscriptionRepository) Delete(userID, endpoint string) error {
+	return r.db.
+		Where("user_id = ? AND endpoint = ?", userID, endpoint).
+		Delete(&models.PushSubscription{}).Error
+}
+
+func (r *PushSubscriptionRepository) DeleteByEndpoint(endpoint string) error {
+	return r.db.Where("endpoint = ?", endpoint).Delete(&models.PushSubscription{}).Error
+}
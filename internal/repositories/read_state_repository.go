@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type ReadStateRepository struct {
+	db *gorm.DB
+}
+
+func NewReadStateRepository(db *gorm.DB) *ReadStateRepository {
+	return &ReadStateRepository{db: db}
+}
+
+// Save moves the user's read marker in a room forward. A marker older than
+// the stored one is ignored, so clients racing each other can't move it back.
+func (r *ReadStateRepository) Save(state *models.ReadState) error {
+	return r.db.Clauses(clause.OnConflict{
+		DoUpdates: clause.Set{
+			{
+				Column: clause.Column{Name: "last_message_id"},
+				Value:  gorm.Expr("GREATEST(last_message_id, VALUES(last_message_id))"),
+			},
+			{
+				Column: clause.Column{Name: "updated_at"},
+				Value:  gorm.Expr("VALUES(updated_at)"),
+			},
+		},
+	}).Create(state).Error
+}
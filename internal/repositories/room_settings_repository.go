@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type RoomSettingsRepository struct {
+	db *gorm.DB
+}
+
+func NewRoomSettingsRepository(db *gorm.DB) *RoomSettingsRepository {
+	return &RoomSettingsRepository{db: db}
+}
+
+// Get returns the user's settings for a room, or the defaults if they never
+// changed them.
+func (r *RoomSettingsRepository) Get(userID string, roomID uint) (*models.RoomSettings, error) {
+	settings := models.RoomSettings{
+		UserID:        userID,
+		RoomID:        roomID,
+		Notifications: models.NotifyDefault,
+	}
+	err := r.db.Where("user_id = ? AND room_id = ?", userID, roomID).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *RoomSettingsRepository) ListForUser(userID string) ([]models.RoomSettings, error) {
+	var settings []models.RoomSettings
+	err := r.db.Where("user_id = ?", userID).Find(&settings).Error
+	return settings, err
+}
+
+// ListEffective returns the settings of each of the users for a room, with
+// NotifyDefault resolved to their default notification level.
+func (r *RoomSettingsRepository) ListEffective(
+	roomID uint,
+	userIDs []string,
+) ([]models.RoomSettings, error) {
+	var settings []models.RoomSettings
+	err := r.db.Table("users").
+		Select(
+			"users.id AS user_id, ? AS room_id, room_settings.muted_until, "+
+				"CASE WHEN room_settings.notifications IS NULL "+
+				"OR room_settings.notifications = ? "+
+				"THEN users.default_notifications "+
+				"ELSE room_settings.notifications END AS notifications",
+			roomID,
+			models.NotifyDefault,
+		).
+		Joins(
+			"LEFT JOIN room_settings ON room_settings.user_id = users.id "+
+				"AND room_settings.room_id = ?",
+			roomID,
+		).
+		Where("users.id IN ?", userIDs).
+		Scan(&settings).Error
+	return settings, err
+}
+
+func (r *RoomSettingsRepository) Save(settings *models.RoomSettings) error {
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(settings).Error
+}
@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+type ScheduledMessageRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduledMessageRepository(db *gorm.DB) *ScheduledMessageRepository {
+	return &ScheduledMessageRepository{db: db}
+}
+
+func (r *ScheduledMessageRepository) Create(message *models.ScheduledMessage) error {
+	return r.db.Create(message).Error
+}
+
+func (r *ScheduledMessageRepository) GetByID(id uint) (*models.ScheduledMessage, error) {
+	var message models.ScheduledMessage
+	err := r.db.First(&message, id).Error
+
+	return &message, err
+}
+
+// ListForSender returns the messages a user has scheduled in a room, soonest
+// first.
+func (r *ScheduledMessageRepository) ListForSender(
+	senderID string,
+	roomID uint,
+) ([]models.ScheduledMessage, error) {
+	var messages []models.ScheduledMessage
+	err := r.db.
+		Where("sender_id = ? AND room_id = ?", senderID, roomID).
+		Order("send_at").
+		Find(&messages).Error
+
+	return messages, err
+}
+
+func (r *ScheduledMessageRepository) CountForSender(senderID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ScheduledMessage{}).
+		Where("sender_id = ?", senderID).
+		Count(&count).Error
+	return count, err
+}
+
+// UpdateIfIdle saves changes to a message unless it's being sent, reporting
+// whether it was.
+func (r *ScheduledMessageRepository) UpdateIfIdle(
+	message *models.ScheduledMessage,
+	now time.Time,
+) (bool, error) {
+	result := r.db.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND (leased_until IS NULL OR leased_until <= ?)", message.ID, now).
+		Select("content", "send_at", "status", "last_error").
+		Updates(message)
+
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteIfIdle deletes a message unless it's being sent, reporting whether it
+// was.
+func (r *ScheduledMessageRepository) DeleteIfIdle(id uint, now time.Time) (bool, error) {
+	result := r.db.
+		Where("id = ? AND (leased_until IS NULL OR leased_until <= ?)", id, now).
+		Delete(&models.ScheduledMessage{})
+
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListDue returns pending messages that are due and not being sent, longest
+// overdue first.
+func (r *ScheduledMessageRepository) ListDue(
+	now time.Time,
+	limit int,
+) ([]models.ScheduledMessage, error) {
+	var messages []models.ScheduledMessage
+	err := r.db.
+		Where("status = ? AND send_at <= ?", models.ScheduledPending, now).
+		Where("leased_until IS NULL OR leased_until <= ?", now).
+		Order("send_at").
+		Limit(limit).
+		Find(&messages).Error
+
+	return messages, err
+}
+
+// Lease marks messages as being sent by a node until until, after which
+// they're due again if they're still around.
+func (r *ScheduledMessageRepository) Lease(ids []uint, nodeID string, until time.Time) error {
+	return r.db.Model(&models.ScheduledMessage{}).
+		Where("id IN ?", ids).
+		Updates(map[string]any{"leased_by": nodeID, "leased_until": until}).
+		Error
+}
+
+// RenewLease extends a node's lease on a message, reporting false if the
+// lease has already run out, since the message may have been claimed again.
+func (r *ScheduledMessageRepository) RenewLease(
+	id uint,
+	nodeID string,
+	now, until time.Time,
+) (bool, error) {
+	result := r.db.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND leased_by = ? AND leased_until > ?", id, nodeID, now).
+		Update("leased_until", until)
+
+	return result.RowsAffected > 0, result.Error
+}
+
+// MarkFailed records why a message couldn't be sent and releases it.
+func (r *ScheduledMessageRepository) MarkFailed(id uint, reason string) error {
+	return r.db.Model(&models.ScheduledMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":       models.ScheduledFailed,
+			"last_error":   reason,
+			"leased_until": nil,
+		}).
+		Error
+}
+
+func (r *ScheduledMessageRepository) Delete(id uint) error {
+	return r.db.Delete(&models.ScheduledMessage{}, id).Error
+}
@@ -1,7 +1,11 @@
 package repositories
 
 import (
+	"strings"
+	"time"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/teamyapchat/yapchat-server/internal/models"
 )
@@ -9,11 +13,19 @@ import (
 type UserRepository interface {
 	Create(user *models.User) error
 	Update(user *models.User) error
-	UpdateStatus(user *models.User) error
 	UpdateImage(user *models.User) error
+	UpdateAvatar(user *models.User) error
 	UpdateUsername(user *models.User) error
+	UpdateProfile(user *models.User) error
+	UpdateEmail(user *models.User) error
+	UpdateDigestFrequency(id string, frequency models.DigestFrequency) error
+	UpdateLastDigestAt(id string, lastDigestAt time.Time) error
 	FindByID(id string) (*models.User, error)
 	FindByUsername(username string) (*models.User, error)
+	FilterExisting(ids []string) ([]string, error)
+	Search(query, callerID string, limit, offset int) ([]*models.User, int, error)
+	ListDueForDigest(now time.Time, limit int) ([]*models.User, error)
+	ListBots(ownerID string) ([]*models.User, error)
 	Delete(id string) error
 }
 
@@ -40,10 +52,11 @@ func (r *MySQLUserRepository) UpdateImage(user *models.User) error {
 		Error
 }
 
-func (r *MySQLUserRepository) UpdateStatus(user *models.User) error {
+func (r *MySQLUserRepository) UpdateAvatar(user *models.User) error {
 	return r.db.Model(&models.User{}).
 		Where("id = ?", user.ID).
-		Update("is_online", user.IsOnline).
+		Select("image_url", "avatar_key").
+		Updates(user).
 		Error
 }
 
@@ -54,6 +67,49 @@ func (r *MySQLUserRepository) UpdateUsername(user *models.User) error {
 		Error
 }
 
+// UpdateProfile only writes the locally edited profile fields so that it
+// never clobbers the fields synced from Clerk.
+func (r *MySQLUserRepository) UpdateProfile(user *models.User) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", user.ID).
+		Select(
+			"display_name",
+			"bio",
+			"pronouns",
+			"banner_url",
+			"accent_color",
+			"discoverable",
+			"default_notifications",
+			"digest_frequency",
+		).
+		Updates(user).
+		Error
+}
+
+func (r *MySQLUserRepository) UpdateEmail(user *models.User) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", user.ID).
+		Update("email", user.Email).
+		Error
+}
+
+func (r *MySQLUserRepository) UpdateDigestFrequency(
+	id string,
+	frequency models.DigestFrequency,
+) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", id).
+		Update("digest_frequency", frequency).
+		Error
+}
+
+func (r *MySQLUserRepository) UpdateLastDigestAt(id string, lastDigestAt time.Time) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", id).
+		Update("last_digest_at", lastDigestAt).
+		Error
+}
+
 func (r *MySQLUserRepository) FindByID(id string) (*models.User, error) {
 	var user models.User
 	err := r.db.Where("id = ?", id).First(&user).Error
@@ -61,6 +117,13 @@ func (r *MySQLUserRepository) FindByID(id string) (*models.User, error) {
 	return &user, err
 }
 
+// FilterExisting returns the IDs among ids that belong to a user.
+func (r *MySQLUserRepository) FilterExisting(ids []string) ([]string, error) {
+	var existing []string
+	err := r.db.Model(&models.User{}).Where("id IN ?", ids).Pluck("id", &existing).Error
+	return existing, err
+}
+
 func (r *MySQLUserRepository) FindByUsername(username string) (*models.User, error) {
 	var user models.User
 	err := r.db.Where("username = ?", username).First(&user).Error
@@ -68,6 +131,104 @@ func (r *MySQLUserRepository) FindByUsername(username string) (*models.User, err
 	return &user, err
 }
 
+// Search returns a page of discoverable users whose username or display
+// name resembles query, leaving out the caller and anyone who has blocked
+// them, along with the total number of matches. Exact matches rank above
+// prefix matches, then substring matches, then those that only sound alike,
+// and people who share a room with the caller are boosted.
+func (r *MySQLUserRepository) Search(
+	query, callerID string,
+	limit, offset int,
+) ([]*models.User, int, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query)
+	prefix := escaped + "%"
+	contains := "%" + escaped + "%"
+
+	matches := r.db.Where("username LIKE ? OR display_name LIKE ?", contains, contains)
+	// SOUNDEX ignores anything but letters, so without any every name with
+	// none either would sound alike
+	if strings.ContainsFunc(query, isASCIILetter) {
+		matches = matches.Or(
+			"(username <> '' AND SOUNDEX(username) = SOUNDEX(?)) OR "+
+				"(display_name <> '' AND SOUNDEX(display_name) = SOUNDEX(?))",
+			query,
+			query,
+		)
+	}
+
+	search := func() *gorm.DB {
+		return r.db.Model(&models.User{}).
+			Where("discoverable = ? AND id <> ?", true, callerID).
+			Where(matches).
+			Where(
+				"NOT EXISTS (SELECT 1 FROM blocked_users WHERE blocked_users.user_id = users.id AND blocked_users.blocked_user_id = ?)",
+				callerID,
+			)
+	}
+
+	var total int64
+	if err := search().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []*models.User
+	err := search().
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL: "CASE " +
+				"WHEN username = ? OR display_name = ? THEN 100 " +
+				"WHEN username LIKE ? OR display_name LIKE ? THEN 80 " +
+				"WHEN username LIKE ? OR display_name LIKE ? THEN 50 " +
+				"ELSE 10 END + " +
+				"CASE WHEN EXISTS (" +
+				"SELECT 1 FROM chat_room_participants AS self " +
+				"JOIN chat_room_participants AS other ON other.chat_room_id = self.chat_room_id " +
+				"JOIN chat_rooms ON chat_rooms.id = self.chat_room_id " +
+				"WHERE chat_rooms.deleted_at IS NULL AND self.user_id = ? AND other.user_id = users.id" +
+				") THEN 25 ELSE 0 END DESC, username",
+			Vars:               []any{query, query, prefix, prefix, contains, contains, callerID},
+			WithoutParentheses: true,
+		}}).
+		Limit(limit).
+		Offset(offset).
+		Find(&users).Error
+
+	return users, int(total), err
+}
+
+func isASCIILetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+// ListDueForDigest returns users with an email address whose last digest
+// was longer ago than their digest frequency, longest waiting first.
+func (r *MySQLUserRepository) ListDueForDigest(now time.Time, limit int) ([]*models.User, error) {
+	due := r.db
+	for _, frequency := range []models.DigestFrequency{models.DigestDaily, models.DigestWeekly} {
+		due = due.Or(
+			"digest_frequency = ? AND (last_digest_at IS NULL OR last_digest_at <= ?)",
+			frequency,
+			now.Add(-frequency.Interval()),
+		)
+	}
+
+	var users []*models.User
+	err := r.db.
+		Where("email <> ''").
+		Where(due).
+		Order("last_digest_at").
+		Limit(limit).
+		Find(&users).Error
+
+	return users, err
+}
+
+func (r *MySQLUserRepository) ListBots(ownerID string) ([]*models.User, error) {
+	var bots []*models.User
+	err := r.db.Where("bot = ? AND owner_id = ?", true, ownerID).Order("created_at").Find(&bots).Error
+
+	return bots, err
+}
+
 func (r *MySQLUserRepository) Delete(id string) error {
 	var user models.User
 	err := r.db.Where("id = ?", id).Delete(&user).Error
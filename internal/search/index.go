@@ -0,0 +1,134 @@
+package search
+
+import (
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+var ErrEmptyQuery = errors.New("empty search query")
+
+var linkRegex = regexp.MustCompile(`https?://\S+`)
+
+// Index is a full-text index over messages. Implementations are expected to
+// only return messages from Query.RoomIDs.
+type Index interface {
+	Index(message *models.Message) error
+	Remove(messageID uint) error
+	Search(query Query) ([]Hit, error)
+}
+
+type Query struct {
+	Text          string
+	RoomIDs       []uint
+	AuthorID      string
+	After         *time.Time
+	Before        *time.Time
+	HasAttachment bool
+	HasLink       bool
+	// Cursor is the ID of the last message of the previous page; results
+	// continue with older messages.
+	Cursor uint
+	Limit  int
+}
+
+type Hit struct {
+	Message models.Message
+	Snippet string
+}
+
+func Terms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func HasLink(content string) bool {
+	return linkRegex.MatchString(content)
+}
+
+const snippetRadius = 60
+
+// Snippet returns an HTML-escaped excerpt of content around the first
+// matching term, with every match wrapped in <mark> tags.
+func Snippet(content string, terms []string) string {
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+
+	type match struct{ start, end int }
+	var matches []match
+	for i := 0; i < len(lower); {
+		matched := 0
+		for _, term := range terms {
+			termRunes := []rune(term)
+			if len(termRunes) > matched && hasPrefixAt(lower, termRunes, i) {
+				matched = len(termRunes)
+			}
+		}
+
+		if matched > 0 && (i == 0 || !isWordRune(lower[i-1])) {
+			// Highlight the rest of the word so prefix matches read naturally
+			end := i + matched
+			for end < len(lower) && isWordRune(lower[end]) {
+				end++
+			}
+			matches = append(matches, match{i, end})
+			i = end
+			continue
+		}
+		i++
+	}
+
+	start, end := 0, len(runes)
+	if len(matches) > 0 {
+		start = max(0, matches[0].start-snippetRadius)
+		end = min(len(runes), matches[0].end+snippetRadius)
+	} else if end > 2*snippetRadius {
+		end = 2 * snippetRadius
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+
+	pos := start
+	for _, m := range matches {
+		if m.start < start || m.end > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:m.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[m.start:m.end])))
+		b.WriteString("</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
+
+func hasPrefixAt(s, prefix []rune, i int) bool {
+	if i+len(prefix) > len(s) {
+		return false
+	}
+	for j, r := range prefix {
+		if s[i+j] != r {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
@@ -0,0 +1,146 @@
+package search
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+// MemoryIndex is an in-process inverted index for local development, where
+// running MySQL with a FULLTEXT index is more hassle than it's worth. It has
+// to be rebuilt from the database on every start.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	messages map[uint]models.Message
+	postings map[string]map[uint]struct{} // term -> message IDs
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		messages: make(map[uint]models.Message),
+		postings: make(map[string]map[uint]struct{}),
+	}
+}
+
+func (i *MemoryIndex) Index(message *models.Message) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(message.ID)
+
+	doc := *message
+	doc.Sender = models.User{}
+	doc.Room = models.ChatRoom{}
+	i.messages[message.ID] = doc
+
+	for _, term := range Terms(message.Content) {
+		if i.postings[term] == nil {
+			i.postings[term] = make(map[uint]struct{})
+		}
+		i.postings[term][message.ID] = struct{}{}
+	}
+
+	return nil
+}
+
+func (i *MemoryIndex) Remove(messageID uint) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(messageID)
+	return nil
+}
+
+func (i *MemoryIndex) remove(messageID uint) {
+	message, exists := i.messages[messageID]
+	if !exists {
+		return
+	}
+
+	for _, term := range Terms(message.Content) {
+		delete(i.postings[term], messageID)
+		if len(i.postings[term]) == 0 {
+			delete(i.postings, term)
+		}
+	}
+	delete(i.messages, messageID)
+}
+
+func (i *MemoryIndex) Search(query Query) ([]Hit, error) {
+	terms := Terms(query.Text)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	// Every term has to match the start of some word in the message
+	var candidates map[uint]struct{}
+	for _, term := range terms {
+		matches := make(map[uint]struct{})
+		for indexed, ids := range i.postings {
+			if !strings.HasPrefix(indexed, term) {
+				continue
+			}
+			for id := range ids {
+				if _, ok := candidates[id]; candidates == nil || ok {
+					matches[id] = struct{}{}
+				}
+			}
+		}
+		candidates = matches
+	}
+
+	ids := make([]uint, 0, len(candidates))
+	for id := range candidates {
+		message := i.messages[id]
+		if i.matches(&message, &query) {
+			ids = append(ids, id)
+		}
+	}
+
+	// Newest first, matching the MySQL index
+	slices.SortFunc(ids, func(a, b uint) int { return cmp.Compare(b, a) })
+	if len(ids) > query.Limit {
+		ids = ids[:query.Limit]
+	}
+
+	hits := make([]Hit, 0, len(ids))
+	for _, id := range ids {
+		hits = append(hits, Hit{
+			Message: i.messages[id],
+			Snippet: Snippet(i.messages[id].Content, terms),
+		})
+	}
+
+	return hits, nil
+}
+
+func (i *MemoryIndex) matches(message *models.Message, query *Query) bool {
+	if !slices.Contains(query.RoomIDs, message.RoomID) {
+		return false
+	}
+	if query.AuthorID != "" && message.SenderID != query.AuthorID {
+		return false
+	}
+	if query.After != nil && message.CreatedAt.Before(*query.After) {
+		return false
+	}
+	if query.Before != nil && !message.CreatedAt.Before(*query.Before) {
+		return false
+	}
+	if query.HasLink && !HasLink(message.Content) {
+		return false
+	}
+	if query.HasAttachment && len(message.Attachments) == 0 {
+		return false
+	}
+	if query.Cursor != 0 && message.ID >= query.Cursor {
+		return false
+	}
+	return true
+}
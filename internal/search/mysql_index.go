@@ -0,0 +1,85 @@
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+// MySQLIndex searches the messages table through its FULLTEXT index, so
+// there is nothing to keep in sync when messages are written.
+type MySQLIndex struct {
+	db *gorm.DB
+}
+
+func NewMySQLIndex(db *gorm.DB) *MySQLIndex {
+	return &MySQLIndex{db: db}
+}
+
+func (i *MySQLIndex) Index(message *models.Message) error {
+	return nil
+}
+
+func (i *MySQLIndex) Remove(messageID uint) error {
+	return nil
+}
+
+func (i *MySQLIndex) Search(query Query) ([]Hit, error) {
+	terms := Terms(query.Text)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	if len(query.RoomIDs) == 0 {
+		return []Hit{}, nil
+	}
+
+	// Require every term and allow prefix matches, e.g. "+deploy* +fail*"
+	booleanTerms := make([]string, 0, len(terms))
+	for _, term := range terms {
+		booleanTerms = append(booleanTerms, "+"+term+"*")
+	}
+
+	tx := i.db.Model(&models.Message{}).
+		Where("MATCH(content) AGAINST (? IN BOOLEAN MODE)", strings.Join(booleanTerms, " ")).
+		Where("room_id IN ?", query.RoomIDs).
+		Where("type = ?", models.UserMessage)
+
+	if query.AuthorID != "" {
+		tx = tx.Where("sender_id = ?", query.AuthorID)
+	}
+	if query.After != nil {
+		tx = tx.Where("created_at >= ?", *query.After)
+	}
+	if query.Before != nil {
+		tx = tx.Where("created_at < ?", *query.Before)
+	}
+	if query.HasLink {
+		tx = tx.Where("(content LIKE ? OR content LIKE ?)", "%http://%", "%https://%")
+	}
+	if query.HasAttachment {
+		tx = tx.Where(
+			"EXISTS (SELECT 1 FROM attachments WHERE attachments.message_id = messages.id AND attachments.deleted_at IS NULL)",
+		)
+	}
+	if query.Cursor != 0 {
+		tx = tx.Where("id < ?", query.Cursor)
+	}
+
+	var messages []models.Message
+	err := tx.Preload("Attachments").Order("id desc").Limit(query.Limit).Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, 0, len(messages))
+	for _, message := range messages {
+		hits = append(hits, Hit{
+			Message: message,
+			Snippet: Snippet(message.Content, terms),
+		})
+	}
+
+	return hits, nil
+}
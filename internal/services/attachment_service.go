@@ -0,0 +1,539 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/audio"
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/imaging"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+	"github.com/teamyapchat/yapchat-server/internal/storage"
+)
+
+var (
+	ErrAttachmentNotFound   = errors.New("attachment not found")
+	ErrAttachmentTooLarge   = errors.New("attachment too large")
+	ErrUnsupportedMediaType = errors.New("unsupported attachment type")
+	ErrInvalidAttachments   = errors.New("invalid attachments")
+	ErrInvalidSignature     = errors.New("invalid or expired signature")
+	ErrInvalidVoiceMessage  = errors.New("invalid voice message")
+)
+
+const (
+	attachmentURLExpiry      = 15 * time.Minute
+	maxAttachmentsPerMessage = 10
+	maxFilenameLength        = 255
+	maxVoiceMessageDuration  = 20 * time.Minute
+)
+
+// Content types are sniffed from the file itself rather than trusted from
+// the client.
+var allowedAttachmentTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"video/mp4",
+	"video/webm",
+	"audio/mpeg",
+	"audio/wave",
+	"application/ogg",
+	"application/pdf",
+	"application/zip",
+	"text/plain",
+}
+
+// Images of these types are decoded and re-encoded on upload, which strips
+// their metadata and lets us generate thumbnails
+var processedImageTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+}
+
+var attachmentThumbnailSizes = []int{256, 1024}
+
+// Voice messages have to be Opus, in either of the containers browsers
+// record to
+var voiceMessageTypes = []string{
+	"application/ogg",
+	"video/webm",
+}
+
+// AttachmentContent is an opened attachment, or one of its thumbnails.
+type AttachmentContent struct {
+	io.ReadCloser
+	Filename    string
+	ContentType string
+	Size        int64
+}
+
+type AttachmentService struct {
+	attachmentRepo *repositories.AttachmentRepository
+	chatroomRepo   *repositories.ChatRoomRepository
+	store          storage.BlobStore
+	signingKey     []byte
+	maxSize        int64
+	baseURL        string
+}
+
+func NewAttachmentService(
+	attachmentRepo *repositories.AttachmentRepository,
+	chatroomRepo *repositories.ChatRoomRepository,
+	store storage.BlobStore,
+	signingKey string,
+	maxSize int64,
+	baseURL string,
+) *AttachmentService {
+	return &AttachmentService{
+		attachmentRepo: attachmentRepo,
+		chatroomRepo:   chatroomRepo,
+		store:          store,
+		signingKey:     []byte(signingKey),
+		maxSize:        maxSize,
+		baseURL:        baseURL,
+	}
+}
+
+func (s *AttachmentService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Upload stores a file for a room. The attachment stays unclaimed until the
+// uploader sends a message referencing it. Voice messages are probed for their
+// duration and waveform so clients can show a player without downloading
+// them.
+func (s *AttachmentService) Upload(
+	ctx context.Context,
+	uploaderID string,
+	roomID uint,
+	filename string,
+	r io.Reader,
+	size int64,
+	voiceMessage bool,
+) (*models.Attachment, error) {
+	if size > s.maxSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	br := bufio.NewReaderSize(r, 512)
+	head, err := br.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil || !slices.Contains(allowedAttachmentTypes, contentType) {
+		return nil, ErrUnsupportedMediaType
+	}
+	if voiceMessage && !slices.Contains(voiceMessageTypes, contentType) {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	filename = filepath.Base(filepath.Clean("/" + filename))
+	if filename == "/" || filename == "." {
+		filename = "file"
+	}
+	if len(filename) > maxFilenameLength {
+		filename = filename[len(filename)-maxFilenameLength:]
+	}
+
+	attachment := &models.Attachment{
+		RoomID:      roomID,
+		UploaderID:  uploaderID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        size,
+		StorageKey:  fmt.Sprintf("attachments/%d/%s", roomID, generateULID()),
+	}
+
+	if voiceMessage {
+		err = s.putVoiceMessage(ctx, attachment, io.LimitReader(br, size))
+	} else if slices.Contains(processedImageTypes, contentType) {
+		err = s.putImage(ctx, attachment, io.LimitReader(br, size))
+	} else {
+		err = s.store.Put(ctx, attachment.StorageKey, io.LimitReader(br, size), size, contentType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.attachmentRepo.Create(attachment); err != nil {
+		s.deleteBlobs(ctx, attachment)
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// putImage normalises an image attachment and stores it along with its
+// thumbnails, filling in the attachment's image metadata.
+func (s *AttachmentService) putImage(
+	ctx context.Context,
+	attachment *models.Attachment,
+	r io.Reader,
+) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	processed, err := imaging.Process(data, imaging.Options{
+		ThumbnailSizes: attachmentThumbnailSizes,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+
+	if processed.ContentType != attachment.ContentType {
+		ext := filepath.Ext(attachment.Filename)
+		attachment.Filename = strings.TrimSuffix(attachment.Filename, ext) +
+			imageExtensions[processed.ContentType]
+	}
+
+	attachment.ContentType = processed.ContentType
+	attachment.Size = int64(len(processed.Data))
+	attachment.Width = processed.Width
+	attachment.Height = processed.Height
+	attachment.BlurHash = processed.BlurHash
+
+	err = s.store.Put(
+		ctx,
+		attachment.StorageKey,
+		bytes.NewReader(processed.Data),
+		attachment.Size,
+		attachment.ContentType,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, thumbnail := range processed.Thumbnails {
+		attachment.Thumbnails = append(attachment.Thumbnails, models.AttachmentThumbnail{
+			Size:        thumbnail.Size,
+			Width:       thumbnail.Width,
+			Height:      thumbnail.Height,
+			ContentType: thumbnail.ContentType,
+			Length:      int64(len(thumbnail.Data)),
+		})
+
+		err := s.store.Put(
+			ctx,
+			thumbnailKey(attachment.StorageKey, thumbnail.Size),
+			bytes.NewReader(thumbnail.Data),
+			int64(len(thumbnail.Data)),
+			thumbnail.ContentType,
+		)
+		if err != nil {
+			s.deleteBlobs(ctx, attachment)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putVoiceMessage checks that a voice message is Opus audio of a sensible
+// length and stores it along with its duration and waveform.
+func (s *AttachmentService) putVoiceMessage(
+	ctx context.Context,
+	attachment *models.Attachment,
+	r io.Reader,
+) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	info, err := audio.Probe(data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidVoiceMessage, err)
+	}
+	if info.Duration <= 0 || info.Duration > maxVoiceMessageDuration {
+		return fmt.Errorf(
+			"%w: must be at most %d minutes long",
+			ErrInvalidVoiceMessage,
+			int(maxVoiceMessageDuration.Minutes()),
+		)
+	}
+
+	attachment.IsVoiceMessage = true
+	attachment.ContentType = info.ContentType
+	attachment.Size = int64(len(data))
+	attachment.DurationMs = int(info.Duration.Milliseconds())
+	attachment.Waveform = info.Waveform
+
+	return s.store.Put(
+		ctx,
+		attachment.StorageKey,
+		bytes.NewReader(data),
+		attachment.Size,
+		attachment.ContentType,
+	)
+}
+
+func thumbnailKey(storageKey string, size int) string {
+	return fmt.Sprintf("%s_thumb_%d", storageKey, size)
+}
+
+func (s *AttachmentService) deleteBlobs(ctx context.Context, attachment *models.Attachment) {
+	keys := []string{attachment.StorageKey}
+	for _, thumbnail := range attachment.Thumbnails {
+		keys = append(keys, thumbnailKey(attachment.StorageKey, thumbnail.Size))
+	}
+
+	for _, key := range keys {
+		if err := s.store.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrBlobNotFound) {
+			log.Error("Failed to delete orphaned blob", "key", key, "err", err.Error())
+		}
+	}
+}
+
+// Resolve loads the attachments a message is about to claim, making sure
+// each was uploaded by the sender to the same room and isn't already part
+// of another message.
+func (s *AttachmentService) Resolve(
+	ids []uint,
+	uploaderID string,
+	roomID uint,
+) ([]models.Attachment, error) {
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	if len(ids) > maxAttachmentsPerMessage {
+		return nil, fmt.Errorf(
+			"%w: at most %d attachments per message",
+			ErrInvalidAttachments,
+			maxAttachmentsPerMessage,
+		)
+	}
+
+	attachments, err := s.attachmentRepo.FindUnclaimed(ids, uploaderID, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(attachments) != len(ids) {
+		return nil, ErrInvalidAttachments
+	}
+
+	isVoiceMessage := func(a models.Attachment) bool { return a.IsVoiceMessage }
+	if len(attachments) > 1 && slices.ContainsFunc(attachments, isVoiceMessage) {
+		return nil, fmt.Errorf(
+			"%w: voice messages can't have other attachments",
+			ErrInvalidAttachments,
+		)
+	}
+
+	return attachments, nil
+}
+
+// GetForUser returns an attachment if the user is allowed to see it: they
+// have to be in its room, and unsent uploads are only visible to the
+// uploader.
+func (s *AttachmentService) GetForUser(id uint, userID string) (*models.Attachment, error) {
+	attachment, err := s.attachmentRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+
+	if attachment.MessageID == nil && attachment.UploaderID != userID {
+		return nil, ErrAttachmentNotFound
+	}
+
+	isParticipant, err := s.chatroomRepo.IsParticipant(attachment.RoomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrAttachmentNotFound
+	}
+
+	return attachment, nil
+}
+
+// SignedURL returns a download URL for the attachment, or for one of its
+// thumbnails when thumbnailSize isn't zero, that stops working after a short
+// while. Callers must have checked room membership.
+func (s *AttachmentService) SignedURL(
+	ctx context.Context,
+	attachment *models.Attachment,
+	thumbnailSize int,
+) (string, time.Time, error) {
+	expiresAt := time.Now().Add(attachmentURLExpiry)
+
+	if presigner, ok := s.store.(storage.Presigner); ok {
+		key := attachment.StorageKey
+		if thumbnailSize != 0 {
+			key = thumbnailKey(key, thumbnailSize)
+		}
+
+		url, err := presigner.PresignGet(ctx, key, attachment.Filename, attachmentURLExpiry)
+		return url, expiresAt, err
+	}
+
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	url := fmt.Sprintf(
+		"%s/attachments/%d/download?expires=%s&signature=%s",
+		s.baseURL,
+		attachment.ID,
+		expires,
+		s.sign(attachment.ID, thumbnailSize, expires),
+	)
+	if thumbnailSize != 0 {
+		url += fmt.Sprintf("&thumbnail=%d", thumbnailSize)
+	}
+
+	return url, expiresAt, nil
+}
+
+// Open verifies a signed download URL and returns the contents of the
+// attachment or thumbnail it points to.
+func (s *AttachmentService) Open(
+	ctx context.Context,
+	id uint,
+	thumbnail, expires, signature string,
+) (*AttachmentContent, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nil, ErrInvalidSignature
+	}
+
+	thumbnailSize := 0
+	if thumbnail != "" {
+		thumbnailSize, err = strconv.Atoi(thumbnail)
+		if err != nil {
+			return nil, ErrInvalidSignature
+		}
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(s.sign(id, thumbnailSize, expires))) {
+		return nil, ErrInvalidSignature
+	}
+
+	attachment, err := s.attachmentRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+
+	content := &AttachmentContent{
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+	}
+	key := attachment.StorageKey
+
+	if thumbnailSize != 0 {
+		i := slices.IndexFunc(attachment.Thumbnails, func(t models.AttachmentThumbnail) bool {
+			return t.Size == thumbnailSize
+		})
+		if i == -1 {
+			return nil, ErrAttachmentNotFound
+		}
+
+		content.ContentType = attachment.Thumbnails[i].ContentType
+		content.Size = attachment.Thumbnails[i].Length
+		key = thumbnailKey(key, thumbnailSize)
+	}
+
+	content.ReadCloser, err = s.store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+
+	return content, nil
+}
+
+func (s *AttachmentService) sign(id uint, thumbnailSize int, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%d:%d:%s", id, thumbnailSize, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ToResponses converts attachments into responses with freshly signed
+// download URLs.
+func (s *AttachmentService) ToResponses(attachments []models.Attachment) []dtos.AttachmentResponse {
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	responses := make([]dtos.AttachmentResponse, 0, len(attachments))
+	for i := range attachments {
+		responses = append(responses, s.ToResponse(&attachments[i]))
+	}
+	return responses
+}
+
+func (s *AttachmentService) ToResponse(attachment *models.Attachment) dtos.AttachmentResponse {
+	response := dtos.AttachmentResponse{
+		ID:          attachment.ID,
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		Width:       attachment.Width,
+		Height:      attachment.Height,
+		BlurHash:    attachment.BlurHash,
+	}
+
+	if attachment.IsVoiceMessage {
+		response.VoiceMessage = true
+		response.DurationSecs = float64(attachment.DurationMs) / 1000
+		response.Waveform = base64.StdEncoding.EncodeToString(attachment.Waveform)
+	}
+
+	url, expiresAt, err := s.SignedURL(context.Background(), attachment, 0)
+	if err != nil {
+		log.Error("Failed to sign attachment URL", "attachmentID", attachment.ID, "err", err.Error())
+		return response
+	}
+
+	response.URL = url
+	response.URLExpiresAt = expiresAt.Format(time.RFC3339)
+
+	for _, thumbnail := range attachment.Thumbnails {
+		url, _, err := s.SignedURL(context.Background(), attachment, thumbnail.Size)
+		if err != nil {
+			log.Error("Failed to sign thumbnail URL", "attachmentID", attachment.ID, "err", err.Error())
+			continue
+		}
+
+		response.Thumbnails = append(response.Thumbnails, dtos.AttachmentThumbnailResponse{
+			Size:   thumbnail.Size,
+			Width:  thumbnail.Width,
+			Height: thumbnail.Height,
+			URL:    url,
+		})
+	}
+
+	return response
+}
@@ -0,0 +1,435 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+)
+
+var (
+	ErrBotNotFound      = errors.New("bot not found")
+	ErrBotTokenNotFound = errors.New("bot token not found")
+	ErrInvalidBot       = errors.New("invalid bot")
+	ErrUsernameTaken    = errors.New("username taken")
+	ErrBotLimitReached  = errors.New("bot limit reached")
+)
+
+const (
+	MaxBotsPerOwner    = 20
+	MaxTokensPerBot    = 10
+	MaxCommandsPerBot  = 50
+	maxBotTokenNameLen = 64
+	maxCommandOptions  = 10
+	// Descriptions are shown in autocomplete, so they're kept short
+	maxCommandDescriptionLen = 100
+	// Tokens start with this so they're easy to tell apart from session
+	// tokens, and for secret scanners to find
+	botTokenPrefix = "ybot_"
+	// How often a token's last use is recorded
+	botTokenTouchInterval = time.Minute
+)
+
+var (
+	botUsernameRegex = regexp.MustCompile(`^[A-Za-z0-9_]{3,24}$`)
+	// Used for command option names too
+	commandNameRegex = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+)
+
+// BotService manages bot accounts and the tokens they authenticate with.
+// Bots are users owned by the user who created them, and only their owner
+// can manage them, except that bots can register their own commands.
+type BotService struct {
+	userRepo       repositories.UserRepository
+	botTokenRepo   *repositories.BotTokenRepository
+	botCommandRepo *repositories.BotCommandRepository
+	webhookRepo    *repositories.OutgoingWebhookRepository
+}
+
+func NewBotService(
+	userRepo repositories.UserRepository,
+	botTokenRepo *repositories.BotTokenRepository,
+	botCommandRepo *repositories.BotCommandRepository,
+	webhookRepo *repositories.OutgoingWebhookRepository,
+) *BotService {
+	return &BotService{
+		userRepo:       userRepo,
+		botTokenRepo:   botTokenRepo,
+		botCommandRepo: botCommandRepo,
+		webhookRepo:    webhookRepo,
+	}
+}
+
+func (s *BotService) List(ownerID string) ([]*models.User, error) {
+	return s.userRepo.ListBots(ownerID)
+}
+
+func (s *BotService) Create(ownerID string, request dtos.CreateBotRequest) (*models.User, error) {
+	if !botUsernameRegex.MatchString(request.Username) {
+		return nil, fmt.Errorf(
+			"%w: username must be 3 to 24 letters, numbers or underscores",
+			ErrInvalidBot,
+		)
+	}
+	displayName := strings.TrimSpace(request.DisplayName)
+	if err := validateProfileText("display name", displayName, maxDisplayNameLength); err != nil {
+		return nil, err
+	}
+
+	bots, err := s.userRepo.ListBots(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(bots) >= MaxBotsPerOwner {
+		return nil, ErrBotLimitReached
+	}
+
+	if _, err := s.userRepo.FindByUsername(request.Username); err == nil {
+		return nil, ErrUsernameTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	id := make([]byte, 12)
+	rand.Read(id)
+	bot := &models.User{
+		ID:          "bot_" + hex.EncodeToString(id),
+		Username:    request.Username,
+		DisplayName: displayName,
+		Bot:         true,
+		OwnerID:     ownerID,
+	}
+	if err := s.userRepo.Create(bot); err != nil {
+		return nil, err
+	}
+
+	return bot, nil
+}
+
+// Delete deletes a bot, revokes its tokens and unregisters its commands.
+func (s *BotService) Delete(ownerID, botID string) error {
+	if _, err := s.getOwned(ownerID, botID); err != nil {
+		return err
+	}
+
+	if err := s.botTokenRepo.DeleteForBot(botID); err != nil {
+		return err
+	}
+	if err := s.botCommandRepo.DeleteForBot(botID); err != nil {
+		return err
+	}
+	if err := s.webhookRepo.DeleteForBot(botID); err != nil {
+		return err
+	}
+	return s.userRepo.Delete(botID)
+}
+
+func (s *BotService) ListTokens(ownerID, botID string) ([]models.BotToken, error) {
+	if _, err := s.getOwned(ownerID, botID); err != nil {
+		return nil, err
+	}
+
+	return s.botTokenRepo.ListForBot(botID)
+}
+
+// CreateToken issues a token for a bot, returning it along with the secret
+// token itself, which isn't stored.
+func (s *BotService) CreateToken(
+	ownerID, botID string,
+	request dtos.CreateBotTokenRequest,
+) (*models.BotToken, string, error) {
+	if _, err := s.getOwned(ownerID, botID); err != nil {
+		return nil, "", err
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxBotTokenNameLen {
+		return nil, "", fmt.Errorf(
+			"%w: token name must be 1 to %d characters",
+			ErrInvalidBot,
+			maxBotTokenNameLen,
+		)
+	}
+
+	if len(request.Scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: a token needs at least one scope", ErrInvalidBot)
+	}
+	scopes := make([]models.BotScope, 0, len(request.Scopes))
+	for _, scope := range request.Scopes {
+		if !slices.Contains(models.BotScopes, models.BotScope(scope)) {
+			return nil, "", fmt.Errorf("%w: unknown scope %q", ErrInvalidBot, scope)
+		}
+		if !slices.Contains(scopes, models.BotScope(scope)) {
+			scopes = append(scopes, models.BotScope(scope))
+		}
+	}
+
+	count, err := s.botTokenRepo.CountForBot(botID)
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= MaxTokensPerBot {
+		return nil, "", ErrBotLimitReached
+	}
+
+	secret, hash := newBotToken()
+	token := &models.BotToken{
+		BotID:  botID,
+		Name:   name,
+		Hash:   hash,
+		Scopes: scopes,
+	}
+	if err := s.botTokenRepo.Create(token); err != nil {
+		return nil, "", err
+	}
+
+	return token, secret, nil
+}
+
+// RotateToken replaces a token with a new one with the same scopes. The old
+// token stops working immediately.
+func (s *BotService) RotateToken(
+	ownerID, botID string,
+	tokenID uint,
+) (*models.BotToken, string, error) {
+	if _, err := s.getOwned(ownerID, botID); err != nil {
+		return nil, "", err
+	}
+
+	secret, hash := newBotToken()
+	found, err := s.botTokenRepo.UpdateHash(tokenID, botID, hash)
+	if err != nil {
+		return nil, "", err
+	}
+	if !found {
+		return nil, "", ErrBotTokenNotFound
+	}
+
+	token, err := s.botTokenRepo.FindByHash(hash)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return token, secret, nil
+}
+
+func (s *BotService) RevokeToken(ownerID, botID string, tokenID uint) error {
+	if _, err := s.getOwned(ownerID, botID); err != nil {
+		return err
+	}
+
+	found, err := s.botTokenRepo.Delete(tokenID, botID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrBotTokenNotFound
+	}
+
+	return nil
+}
+
+// Authenticate returns the bot token matching a secret token.
+func (s *BotService) Authenticate(secret string) (*models.BotToken, error) {
+	if !strings.HasPrefix(secret, botTokenPrefix) {
+		return nil, ErrInvalidToken
+	}
+
+	token, err := s.botTokenRepo.FindByHash(hashBotToken(secret))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnverifiedToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= botTokenTouchInterval {
+		if err := s.botTokenRepo.UpdateLastUsedAt(token.ID, now); err != nil {
+			log.Error(
+				"Failed to update bot token last use",
+				"tokenID", token.ID,
+				"err", err.Error(),
+			)
+		}
+	}
+
+	return token, nil
+}
+
+// ListCommands returns the commands a bot has registered. Both the bot and
+// its owner can manage them.
+func (s *BotService) ListCommands(userID, botID string) ([]models.BotCommand, error) {
+	if err := s.checkCommandManager(userID, botID); err != nil {
+		return nil, err
+	}
+
+	return s.botCommandRepo.ListForBots([]string{botID})
+}
+
+// SetCommands replaces all of a bot's commands.
+func (s *BotService) SetCommands(
+	userID, botID string,
+	request dtos.SetBotCommandsRequest,
+) ([]models.BotCommand, error) {
+	if err := s.checkCommandManager(userID, botID); err != nil {
+		return nil, err
+	}
+
+	if len(request.Commands) > MaxCommandsPerBot {
+		return nil, fmt.Errorf(
+			"%w: a bot can have at most %d commands",
+			ErrInvalidBot,
+			MaxCommandsPerBot,
+		)
+	}
+
+	commands := make([]models.BotCommand, len(request.Commands))
+	for i, commandRequest := range request.Commands {
+		command, err := newBotCommand(botID, commandRequest)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(commands[:i], func(other models.BotCommand) bool {
+			return other.Name == command.Name
+		}) {
+			return nil, fmt.Errorf("%w: command /%s is given twice", ErrInvalidBot, command.Name)
+		}
+		commands[i] = *command
+	}
+
+	if err := s.botCommandRepo.ReplaceForBot(botID, commands); err != nil {
+		return nil, err
+	}
+	return commands, nil
+}
+
+func (s *BotService) checkCommandManager(userID, botID string) error {
+	if userID == botID {
+		return nil
+	}
+
+	_, err := s.getOwned(userID, botID)
+	return err
+}
+
+func (s *BotService) getOwned(ownerID, botID string) (*models.User, error) {
+	bot, err := s.userRepo.FindByID(botID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBotNotFound
+		}
+		return nil, err
+	}
+	if !bot.Bot || bot.OwnerID != ownerID {
+		return nil, ErrBotNotFound
+	}
+
+	return bot, nil
+}
+
+// newBotToken returns a new secret token and its hash.
+func newBotToken() (string, string) {
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	token := botTokenPrefix + hex.EncodeToString(secret)
+	return token, hashBotToken(token)
+}
+
+func hashBotToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newBotCommand validates a command a bot is registering. Its name can't be
+// one of the built-in commands, which would always take precedence.
+func newBotCommand(botID string, request dtos.BotCommandRequest) (*models.BotCommand, error) {
+	if !commandNameRegex.MatchString(request.Name) {
+		return nil, fmt.Errorf(
+			"%w: command names must be 1 to 32 lowercase letters, numbers, - or _",
+			ErrInvalidBot,
+		)
+	}
+	if slices.ContainsFunc(builtinCommands, func(builtin models.BotCommand) bool {
+		return builtin.Name == request.Name
+	}) {
+		return nil, fmt.Errorf("%w: /%s is a built-in command", ErrInvalidBot, request.Name)
+	}
+	if err := validateCommandDescription(request.Description); err != nil {
+		return nil, err
+	}
+	if len(request.Options) > maxCommandOptions {
+		return nil, fmt.Errorf(
+			"%w: commands can have at most %d options",
+			ErrInvalidBot,
+			maxCommandOptions,
+		)
+	}
+
+	command := &models.BotCommand{
+		BotID:       botID,
+		Name:        request.Name,
+		Description: strings.TrimSpace(request.Description),
+		Options:     make([]models.CommandOption, len(request.Options)),
+	}
+	for i, option := range request.Options {
+		if !commandNameRegex.MatchString(option.Name) {
+			return nil, fmt.Errorf(
+				"%w: option names must be 1 to 32 lowercase letters, numbers, - or _",
+				ErrInvalidBot,
+			)
+		}
+		if slices.ContainsFunc(command.Options[:i], func(other models.CommandOption) bool {
+			return other.Name == option.Name
+		}) {
+			return nil, fmt.Errorf("%w: option %q is given twice", ErrInvalidBot, option.Name)
+		}
+		if err := validateCommandDescription(option.Description); err != nil {
+			return nil, err
+		}
+		if !slices.Contains(models.CommandOptionTypes, models.CommandOptionType(option.Type)) {
+			return nil, fmt.Errorf("%w: unknown option type %q", ErrInvalidBot, option.Type)
+		}
+		// Arguments are positional, so there's no skipping an optional one
+		if option.Required && i > 0 && !command.Options[i-1].Required {
+			return nil, fmt.Errorf(
+				"%w: required options must come before optional ones",
+				ErrInvalidBot,
+			)
+		}
+
+		command.Options[i] = models.CommandOption{
+			Name:        option.Name,
+			Description: strings.TrimSpace(option.Description),
+			Type:        models.CommandOptionType(option.Type),
+			Required:    option.Required,
+		}
+	}
+
+	return command, nil
+}
+
+func validateCommandDescription(description string) error {
+	description = strings.TrimSpace(description)
+	if description == "" || utf8.RuneCountInString(description) > maxCommandDescriptionLen {
+		return fmt.Errorf(
+			"%w: descriptions must be 1 to %d characters",
+			ErrInvalidBot,
+			maxCommandDescriptionLen,
+		)
+	}
+	return nil
+}
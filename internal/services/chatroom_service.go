@@ -2,10 +2,15 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
-	"math/rand"
+	"fmt"
+	"io"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/log"
 	"github.com/oklog/ulid/v2"
@@ -13,31 +18,66 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/markdown"
 	"github.com/teamyapchat/yapchat-server/internal/models"
 	"github.com/teamyapchat/yapchat-server/internal/repositories"
 )
 
-var ErrChatRoomNotFound = errors.New("chat room not found")
+var (
+	ErrChatRoomNotFound = errors.New("chat room not found")
+	ErrNotParticipant   = errors.New("user not in chat room")
+	ErrMessageNotFound  = errors.New("message not found")
+	ErrCannotPin        = errors.New("user can't pin messages in this chat room")
+	ErrPinLimitReached  = errors.New("pin limit reached")
+	ErrNotModerator     = errors.New("user not a moderator of chat room")
+	ErrNotGroup         = errors.New("chat room not a group")
+	ErrInvalidKick      = errors.New("invalid kick")
+	ErrInvalidChatRoom  = errors.New("invalid chat room")
+)
+
+const (
+	MaxPinsPerRoom    = 50
+	maxRoomNameLength = 100
+	maxTopicLength    = 255
+)
 
 type ChatRoomService struct {
-	chatroomRepo *repositories.ChatRoomRepository
-	userRepo     repositories.UserRepository
-	rdb          *redis.Client
+	chatroomRepo   *repositories.ChatRoomRepository
+	userRepo       repositories.UserRepository
+	readStateRepo  *repositories.ReadStateRepository
+	messageRepo    *repositories.MessageRepository
+	rdb            *redis.Client
+	imageService   *ImageService
+	messageService *MessageService
+	eventService   *EventService
+	webhooks       *OutgoingWebhookService
 }
 
 func NewChatRoomService(
 	chatroomRepo *repositories.ChatRoomRepository,
 	userRepo repositories.UserRepository,
+	readStateRepo *repositories.ReadStateRepository,
+	messageRepo *repositories.MessageRepository,
 	redisClient *redis.Client,
+	imageService *ImageService,
+	messageService *MessageService,
+	eventService *EventService,
+	webhookService *OutgoingWebhookService,
 ) *ChatRoomService {
 	return &ChatRoomService{
-		chatroomRepo: chatroomRepo,
-		userRepo:     userRepo,
-		rdb:          redisClient,
+		chatroomRepo:   chatroomRepo,
+		userRepo:       userRepo,
+		readStateRepo:  readStateRepo,
+		messageRepo:    messageRepo,
+		rdb:            redisClient,
+		imageService:   imageService,
+		messageService: messageService,
+		eventService:   eventService,
+		webhooks:       webhookService,
 	}
 }
 
-func (s *ChatRoomService) Create(chatroomReq *dtos.ChatRoomRequest) error {
+func (s *ChatRoomService) Create(ownerID string, chatroomReq *dtos.ChatRoomRequest) error {
 	var participants []*models.User
 	for _, id := range chatroomReq.ParticipantIDs {
 		user, err := s.userRepo.FindByID(id)
@@ -55,20 +95,10 @@ func (s *ChatRoomService) Create(chatroomReq *dtos.ChatRoomRequest) error {
 		participants = append(participants, user)
 	}
 
-	if chatroomReq.ImageURL != "" {
-		chatroom := models.ChatRoom{
-			Name:         chatroomReq.Name,
-			Type:         chatroomReq.Type,
-			Participants: participants,
-			ImageURL:     chatroomReq.ImageURL,
-		}
-
-		return s.chatroomRepo.Create(&chatroom)
-	}
-
 	chatroom := models.ChatRoom{
 		Name:         chatroomReq.Name,
 		Type:         chatroomReq.Type,
+		OwnerID:      ownerID,
 		Participants: participants,
 	}
 
@@ -90,11 +120,59 @@ func (s *ChatRoomService) List(userID string) ([]*models.ChatRoom, error) {
 	return s.chatroomRepo.List(userID)
 }
 
+func (s *ChatRoomService) ListIDs(userID string) ([]uint, error) {
+	return s.chatroomRepo.ListIDs(userID)
+}
+
+func (s *ChatRoomService) IsParticipant(chatroomID uint, userID string) (bool, error) {
+	return s.chatroomRepo.IsParticipant(chatroomID, userID)
+}
+
 func (s *ChatRoomService) Update(chatroom *models.ChatRoom) error {
 	// Add business logic/validation here if needed
 	return s.chatroomRepo.Update(chatroom)
 }
 
+// UpdateIcon replaces the room's icon with an uploaded image. Any participant
+// can change it.
+func (s *ChatRoomService) UpdateIcon(
+	ctx context.Context,
+	id uint,
+	userID string,
+	r io.Reader,
+	size int64,
+) (*models.ChatRoom, error) {
+	chatroom, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	isParticipant, err := s.chatroomRepo.IsParticipant(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isParticipant {
+		return nil, ErrNotParticipant
+	}
+
+	key, url, err := s.imageService.Upload(ctx, fmt.Sprintf("rooms/%d", chatroom.ID), r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	oldKey := chatroom.IconKey
+	chatroom.ImageURL = url
+	chatroom.IconKey = key
+
+	if err := s.chatroomRepo.UpdateIcon(chatroom); err != nil {
+		s.imageService.Delete(ctx, key)
+		return nil, err
+	}
+
+	s.imageService.Delete(ctx, oldKey)
+	return chatroom, nil
+}
+
 func (s *ChatRoomService) Delete(id uint) error {
 	// Add business logic/validation here if needed
 	return s.chatroomRepo.Delete(id)
@@ -110,7 +188,342 @@ func (s *ChatRoomService) RemoveParticipant(chatroomID uint, userID string) erro
 	return s.chatroomRepo.RemoveParticipant(chatroomID, userID)
 }
 
-func (s *ChatRoomService) CreateInviteCode(chatroomID uint) (string, error) {
+// Join adds the user to a room they were invited to by inviterID, which is
+// empty for invite codes made before inviters were recorded.
+func (s *ChatRoomService) Join(chatroomID uint, userID, inviterID string) error {
+	if err := s.AddParticipant(chatroomID, userID); err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("<@%s> joined.", userID)
+	if inviterID != "" && inviterID != userID {
+		content = fmt.Sprintf("<@%s> joined via an invite from <@%s>.", userID, inviterID)
+	}
+	s.announce(&models.Message{
+		SenderID: userID,
+		RoomID:   chatroomID,
+		Event:    models.MemberJoinedEvent,
+		Content:  content,
+	})
+	s.webhooks.Dispatch(models.MemberJoinedWebhookEvent, chatroomID, userID, dtos.MemberEventData{
+		RoomID: chatroomID,
+		UserID: userID,
+	})
+	return nil
+}
+
+func (s *ChatRoomService) Leave(chatroomID uint, userID string) error {
+	if err := s.RemoveParticipant(chatroomID, userID); err != nil {
+		return err
+	}
+
+	s.announce(&models.Message{
+		SenderID: userID,
+		RoomID:   chatroomID,
+		Event:    models.MemberLeftEvent,
+		Content:  fmt.Sprintf("<@%s> left.", userID),
+	})
+	s.webhooks.Dispatch(models.MemberLeftWebhookEvent, chatroomID, userID, dtos.MemberEventData{
+		RoomID: chatroomID,
+		UserID: userID,
+	})
+	return nil
+}
+
+// Kick removes a user from a group. Only moderators can kick, and they can't
+// kick themselves.
+func (s *ChatRoomService) Kick(chatroomID uint, moderatorID, userID string) error {
+	chatroom, err := s.getAsParticipant(chatroomID, moderatorID)
+	if err != nil {
+		return err
+	}
+	if chatroom.Type != models.GroupChatRoom {
+		return ErrNotGroup
+	}
+	if !chatroom.IsModerator(moderatorID) {
+		return ErrNotModerator
+	}
+	if userID == moderatorID {
+		return fmt.Errorf("%w: leave the chat room instead of kicking yourself", ErrInvalidKick)
+	}
+	if !slices.ContainsFunc(chatroom.Participants, func(user *models.User) bool {
+		return user.ID == userID
+	}) {
+		return fmt.Errorf("%w: user not in chat room", ErrInvalidKick)
+	}
+
+	if err := s.RemoveParticipant(chatroomID, userID); err != nil {
+		return err
+	}
+
+	s.announce(&models.Message{
+		SenderID: moderatorID,
+		RoomID:   chatroomID,
+		Event:    models.MemberKickedEvent,
+		Content:  fmt.Sprintf("<@%s> removed <@%s> from the chat room.", moderatorID, userID),
+	})
+	s.webhooks.Dispatch(models.MemberLeftWebhookEvent, chatroomID, userID, dtos.MemberEventData{
+		RoomID:      chatroomID,
+		UserID:      userID,
+		ModeratorID: moderatorID,
+	})
+	return nil
+}
+
+// Rename changes a group's name. Like its icon, any participant can change
+// it.
+func (s *ChatRoomService) Rename(
+	chatroomID uint,
+	userID, name string,
+) (*models.ChatRoom, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxRoomNameLength {
+		return nil, fmt.Errorf(
+			"%w: name must be between 1 and %d characters",
+			ErrInvalidChatRoom,
+			maxRoomNameLength,
+		)
+	}
+
+	chatroom, err := s.getAsParticipant(chatroomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if chatroom.Type != models.GroupChatRoom {
+		return nil, ErrNotGroup
+	}
+	if chatroom.Name == name {
+		return chatroom, nil
+	}
+
+	chatroom.Name = name
+	if err := s.chatroomRepo.UpdateName(chatroom); err != nil {
+		return nil, err
+	}
+
+	s.announce(&models.Message{
+		SenderID: userID,
+		RoomID:   chatroomID,
+		Event:    models.RoomRenamedEvent,
+		Content: fmt.Sprintf(
+			"<@%s> renamed the chat room to %s.",
+			userID,
+			markdown.Escape(name),
+		),
+	})
+	return chatroom, nil
+}
+
+// SetTopic changes a group's topic, which any participant can do like its
+// name.
+func (s *ChatRoomService) SetTopic(
+	chatroomID uint,
+	userID, topic string,
+) (*models.ChatRoom, error) {
+	topic = strings.TrimSpace(topic)
+	if topic == "" || utf8.RuneCountInString(topic) > maxTopicLength {
+		return nil, fmt.Errorf(
+			"%w: topic must be between 1 and %d characters",
+			ErrInvalidChatRoom,
+			maxTopicLength,
+		)
+	}
+
+	chatroom, err := s.getAsParticipant(chatroomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if chatroom.Type != models.GroupChatRoom {
+		return nil, ErrNotGroup
+	}
+	if chatroom.Topic == topic {
+		return chatroom, nil
+	}
+
+	chatroom.Topic = topic
+	if err := s.chatroomRepo.UpdateTopic(chatroom); err != nil {
+		return nil, err
+	}
+
+	s.announce(&models.Message{
+		SenderID: userID,
+		RoomID:   chatroomID,
+		Event:    models.TopicChangedEvent,
+		Content: fmt.Sprintf(
+			"<@%s> changed the topic to %s.",
+			userID,
+			markdown.Escape(topic),
+		),
+	})
+	return chatroom, nil
+}
+
+// announce sends a system message about something that has already
+// happened, so failing to send it only gets logged.
+func (s *ChatRoomService) announce(message *models.Message) {
+	if err := s.messageService.SendSystemMessage(message); err != nil {
+		log.Error(
+			"Failed to send system message",
+			"chatroomID",
+			message.RoomID,
+			"event",
+			message.Event,
+			"err",
+			err.Error(),
+		)
+	}
+}
+
+// MarkRead records that the user has read the room up to messageID. Messages
+// past the marker are what email digests remind them about.
+func (s *ChatRoomService) MarkRead(chatroomID uint, userID string, messageID uint) error {
+	isParticipant, err := s.chatroomRepo.IsParticipant(chatroomID, userID)
+	if err != nil {
+		return err
+	}
+	if !isParticipant {
+		return ErrNotParticipant
+	}
+
+	return s.readStateRepo.Save(&models.ReadState{
+		UserID:        userID,
+		RoomID:        chatroomID,
+		LastMessageID: messageID,
+	})
+}
+
+// ListPins returns the messages pinned in a room, most recently pinned
+// first.
+func (s *ChatRoomService) ListPins(chatroomID uint, userID string) ([]models.Message, error) {
+	if _, err := s.getAsParticipant(chatroomID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.messageRepo.ListPinned(chatroomID)
+}
+
+// Pin pins a message to its room and lets the room know with a system
+// message. Pinning a message that's already pinned does nothing.
+func (s *ChatRoomService) Pin(chatroomID, messageID uint, userID string) error {
+	message, err := s.getPinnable(chatroomID, messageID, userID)
+	if err != nil {
+		return err
+	}
+	if message.PinnedAt != nil {
+		return nil
+	}
+
+	pinnedAt := time.Now()
+	pinned, full, err := s.messageRepo.Pin(
+		messageID,
+		chatroomID,
+		userID,
+		pinnedAt,
+		MaxPinsPerRoom,
+	)
+	if err != nil {
+		return err
+	}
+	if full {
+		return fmt.Errorf(
+			"%w: a chat room can have at most %d pinned messages",
+			ErrPinLimitReached,
+			MaxPinsPerRoom,
+		)
+	}
+	if !pinned {
+		return nil
+	}
+
+	s.eventService.PublishToRoom(
+		dtos.MessagePinEvent,
+		dtos.MessagePinData{
+			MessageID:  messageID,
+			RoomID:     chatroomID,
+			PinnedByID: userID,
+			PinnedAt:   pinnedAt.Format(time.RFC3339),
+		},
+		chatroomID,
+	)
+
+	s.announce(&models.Message{
+		SenderID:    userID,
+		RoomID:      chatroomID,
+		Event:       models.MessagePinnedEvent,
+		ReferenceID: &messageID,
+		Content:     fmt.Sprintf("<@%s> pinned a message to this chat room.", userID),
+	})
+	return nil
+}
+
+// Unpin unpins a message. Unpinning a message that isn't pinned does
+// nothing.
+func (s *ChatRoomService) Unpin(chatroomID, messageID uint, userID string) error {
+	if _, err := s.getPinnable(chatroomID, messageID, userID); err != nil {
+		return err
+	}
+
+	unpinned, err := s.messageRepo.SetPinned(messageID, chatroomID, "", nil)
+	if err != nil || !unpinned {
+		return err
+	}
+
+	s.eventService.PublishToRoom(
+		dtos.MessageUnpinEvent,
+		dtos.MessageUnpinData{MessageID: messageID, RoomID: chatroomID},
+		chatroomID,
+	)
+	return nil
+}
+
+// getPinnable returns a message in a room the user can pin it in. System
+// messages can't be pinned.
+func (s *ChatRoomService) getPinnable(
+	chatroomID, messageID uint,
+	userID string,
+) (*models.Message, error) {
+	chatroom, err := s.getAsParticipant(chatroomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !chatroom.CanPin(userID) {
+		return nil, ErrCannotPin
+	}
+
+	message, err := s.messageRepo.GetByID(messageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	if message.RoomID != chatroomID || message.Type == models.SystemMessage {
+		return nil, ErrMessageNotFound
+	}
+
+	return message, nil
+}
+
+func (s *ChatRoomService) getAsParticipant(
+	chatroomID uint,
+	userID string,
+) (*models.ChatRoom, error) {
+	chatroom, err := s.GetByID(chatroomID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, participant := range chatroom.Participants {
+		if participant.ID == userID {
+			return chatroom, nil
+		}
+	}
+	return nil, ErrNotParticipant
+}
+
+// CreateInviteCode returns a code that lets anyone join the room for a day,
+// remembering who it was made by.
+func (s *ChatRoomService) CreateInviteCode(chatroomID uint, inviterID string) (string, error) {
 	for {
 		key := generateULID()
 
@@ -121,7 +534,8 @@ func (s *ChatRoomService) CreateInviteCode(chatroomID uint) (string, error) {
 		}
 
 		if exists == 0 {
-			err = s.rdb.Set(context.Background(), "invite:"+key, chatroomID, 24*time.Hour).Err()
+			invite := fmt.Sprintf("%d:%s", chatroomID, inviterID)
+			err = s.rdb.Set(context.Background(), "invite:"+key, invite, 24*time.Hour).Err()
 			if err != nil {
 				log.Error("Failed to set invite code", "err", err.Error())
 				return "", err
@@ -132,29 +546,33 @@ func (s *ChatRoomService) CreateInviteCode(chatroomID uint) (string, error) {
 	}
 }
 
-func (s *ChatRoomService) GetByInviteCode(inviteCode string) (*models.ChatRoom, error) {
-	chatroomIDStr, err := s.rdb.Get(context.Background(), "invite:"+inviteCode).Result()
+// GetByInviteCode returns the room an invite code is for and who made it.
+// Codes made before inviters were recorded have no inviter.
+func (s *ChatRoomService) GetByInviteCode(inviteCode string) (*models.ChatRoom, string, error) {
+	invite, err := s.rdb.Get(context.Background(), "invite:"+inviteCode).Result()
 	if err != nil {
 		log.Error("Failed to get invite code", "err", err.Error())
-		return nil, err
+		return nil, "", err
 	}
+	chatroomIDStr, inviterID, _ := strings.Cut(invite, ":")
 
 	chatroomID, err := strconv.ParseUint(chatroomIDStr, 10, 32)
 	if err != nil {
 		log.Error("Failed to parse chatroom ID", "err", err.Error())
-		return nil, err
+		return nil, "", err
 	}
 
 	chatroom, err := s.GetByID(uint(chatroomID))
 	if err != nil {
 		log.Error("Failed to get chat room", "err", err.Error())
-		return nil, err
+		return nil, "", err
 	}
 
-	return chatroom, nil
+	return chatroom, inviterID, nil
 }
 
+// generateULID returns a new ULID for session IDs, invite codes and storage
+// keys, which mustn't be guessable.
 func generateULID() string {
-	entropy := ulid.Monotonic(rand.New(rand.NewSource(time.Now().UnixNano())), 0)
-	return ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
+	return ulid.MustNew(ulid.Now(), rand.Reader).String()
 }
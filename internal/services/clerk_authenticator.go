@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/clerk/clerk-sdk-go/v2/jwt"
+	"github.com/clerk/clerk-sdk-go/v2/user"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+)
+
+// How long a user known to exist is cached for, which is also how long a
+// deleted user's unexpired tokens keep working
+const authUserCacheTTL = 5 * time.Minute
+
+// ClerkAuthenticator accepts Clerk session tokens. Tokens are verified
+// offline against Clerk's cached signing keys, and their users are looked up
+// in the local copy kept in sync by the Clerk webhook. Clerk's API is only
+// asked about users the webhook hasn't delivered yet.
+type ClerkAuthenticator struct {
+	jwksCache *JWKSCache
+	userRepo  repositories.UserRepository
+	rdb       *redis.Client
+}
+
+func NewClerkAuthenticator(
+	jwksCache *JWKSCache,
+	userRepo repositories.UserRepository,
+	rdb *redis.Client,
+) *ClerkAuthenticator {
+	return &ClerkAuthenticator{
+		jwksCache: jwksCache,
+		userRepo:  userRepo,
+		rdb:       rdb,
+	}
+}
+
+func (a *ClerkAuthenticator) Authenticate(
+	ctx context.Context,
+	sessionToken string,
+) (string, error) {
+	unsafeClaims, err := jwt.Decode(ctx, &jwt.DecodeParams{Token: sessionToken})
+	if err != nil {
+		log.Error("Failed to decode JWT", "err", err.Error())
+		return "", ErrInvalidToken
+	}
+
+	keyID := unsafeClaims.KeyID
+	if keyID == "" {
+		log.Error("Failed to extract key ID from claims")
+		return "", ErrInvalidToken
+	}
+
+	jwk, err := a.jwksCache.Get(ctx, keyID)
+	if errors.Is(err, ErrUnknownKeyID) {
+		return "", ErrInvalidToken
+	} else if err != nil {
+		log.Error("Failed to fetch JWK", "err", err.Error())
+		return "", errors.New("failed to fetch JWK")
+	}
+
+	claims, err := jwt.Verify(ctx, &jwt.VerifyParams{
+		Token: sessionToken,
+		JWK:   jwk,
+	})
+	if err != nil {
+		log.Error("Failed to verify token", "err", err.Error())
+		return "", ErrUnverifiedToken
+	}
+
+	if err := a.resolveUser(ctx, claims.Subject); err != nil {
+		return "", err
+	}
+
+	return claims.Subject, nil
+}
+
+// resolveUser checks that a user exists, trying the cache, then the local
+// copy and only then Clerk. Users only found in Clerk are copied locally
+// before they're let through, since everything else expects them to exist.
+func (a *ClerkAuthenticator) resolveUser(ctx context.Context, userID string) error {
+	key := "auth:user:" + userID
+	cached, err := a.rdb.Exists(ctx, key).Result()
+	if err != nil {
+		log.Error("Failed to get cached user", "userID", userID, "err", err.Error())
+	}
+	if cached > 0 {
+		return nil
+	}
+
+	_, err = a.userRepo.FindByID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// The webhook hasn't synced the user yet
+		if err := a.syncUser(ctx, userID); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if err := a.rdb.Set(ctx, key, 1, authUserCacheTTL).Err(); err != nil {
+		log.Error("Failed to cache user", "userID", userID, "err", err.Error())
+	}
+	return nil
+}
+
+// syncUser creates the local copy of a user from Clerk, the same as the
+// user.created webhook would.
+func (a *ClerkAuthenticator) syncUser(ctx context.Context, userID string) error {
+	clerkUser, err := user.Get(ctx, userID)
+	if err != nil {
+		log.Error("Failed to find user in Clerk", "userID", userID, "err", err.Error())
+		return ErrUserNotFound
+	}
+
+	localUser := &models.User{ID: clerkUser.ID}
+	if clerkUser.Username != nil {
+		localUser.Username = *clerkUser.Username
+	}
+	if clerkUser.ImageURL != nil {
+		localUser.ImageURL = *clerkUser.ImageURL
+	}
+	for _, address := range clerkUser.EmailAddresses {
+		if clerkUser.PrimaryEmailAddressID != nil &&
+			address.ID == *clerkUser.PrimaryEmailAddressID {
+			localUser.Email = address.EmailAddress
+		}
+	}
+
+	if err := a.userRepo.Create(localUser); err != nil {
+		// The webhook may have just beaten us to it
+		if _, findErr := a.userRepo.FindByID(userID); findErr == nil {
+			return nil
+		}
+		log.Error("Failed to create user from Clerk", "userID", userID, "err", err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/markdown"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+)
+
+const defaultMuteDuration = time.Hour
+
+// builtinCommands are the commands every room has. /topic and /kick only
+// work in groups.
+var builtinCommands = []models.BotCommand{
+	{
+		Name:        "me",
+		Description: "Send a message about yourself in italics",
+		Options: []models.CommandOption{
+			{
+				Name:        "action",
+				Description: "What you're doing",
+				Type:        models.StringOption,
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "shrug",
+		Description: "Append ¯\\_(ツ)_/¯ to your message",
+		Options: []models.CommandOption{
+			{Name: "message", Description: "Your message", Type: models.StringOption},
+		},
+	},
+	{
+		Name:        "topic",
+		Description: "Show or change the topic",
+		Options: []models.CommandOption{
+			{Name: "topic", Description: "The new topic", Type: models.StringOption},
+		},
+	},
+	{
+		Name:        "invite",
+		Description: "Get an invite code for this chat room",
+	},
+	{
+		Name:        "mute",
+		Description: "Mute notifications from this chat room",
+		Options: []models.CommandOption{
+			{
+				Name:        "duration",
+				Description: "How long for, like 30m or 8h",
+				Type:        models.StringOption,
+			},
+		},
+	},
+	{
+		Name:        "kick",
+		Description: "Remove someone from this chat room",
+		Options: []models.CommandOption{
+			{
+				Name:        "user",
+				Description: "Who to remove",
+				Type:        models.UserOption,
+				Required:    true,
+			},
+		},
+	},
+}
+
+// commandResult is the outcome of a slash command.
+type commandResult struct {
+	// Content is sent as a message from the invoker in place of the command
+	Content string
+	// Reply is sent to the invoker as an ephemeral message
+	Reply string
+}
+
+// CommandService runs the slash commands users send instead of messages.
+// Besides the built-in commands, rooms have the commands of the bots in them,
+// which are passed on to the bot over the gateway.
+type CommandService struct {
+	chatroomService     *ChatRoomService
+	messageService      *MessageService
+	notificationService *NotificationService
+	botCommandRepo      *repositories.BotCommandRepository
+	eventService        *EventService
+}
+
+func NewCommandService(
+	chatroomService *ChatRoomService,
+	messageService *MessageService,
+	notificationService *NotificationService,
+	botCommandRepo *repositories.BotCommandRepository,
+	eventService *EventService,
+) *CommandService {
+	return &CommandService{
+		chatroomService:     chatroomService,
+		messageService:      messageService,
+		notificationService: notificationService,
+		botCommandRepo:      botCommandRepo,
+		eventService:        eventService,
+	}
+}
+
+// List returns the commands the user can invoke in a room, for clients to
+// autocomplete.
+func (s *CommandService) List(chatroomID uint, userID string) ([]models.BotCommand, error) {
+	chatroom, err := s.chatroomService.getAsParticipant(chatroomID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.commands(chatroom)
+}
+
+// Execute runs a command, given as the content of a message starting with a
+// slash, returning the message to send in its place if there is one. Replies
+// only go to the session the command was sent from. Mistakes in the command,
+// and anything the user isn't allowed to do, are replied to rather than
+// returned as errors.
+func (s *CommandService) Execute(
+	chatroomID uint,
+	userID, sessionID, content string,
+) (string, error) {
+	result, err := s.execute(chatroomID, userID, sessionID, content)
+	if err != nil {
+		return "", err
+	}
+
+	if result.Reply != "" {
+		s.messageService.SendEphemeral(&models.Message{
+			RoomID:  chatroomID,
+			Content: result.Reply,
+		}, userID, sessionID)
+	}
+	return result.Content, nil
+}
+
+func (s *CommandService) execute(
+	chatroomID uint,
+	userID, sessionID, content string,
+) (*commandResult, error) {
+	chatroom, err := s.chatroomService.getAsParticipant(chatroomID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	line := strings.TrimPrefix(strings.TrimSpace(content), "/")
+	name, args := line, ""
+	if i := strings.IndexFunc(line, unicode.IsSpace); i >= 0 {
+		name, args = line[:i], line[i+1:]
+	}
+
+	commands, err := s.commands(chatroom)
+	if err != nil {
+		return nil, err
+	}
+	var command *models.BotCommand
+	for i := range commands {
+		if commands[i].Name == name {
+			command = &commands[i]
+			break
+		}
+	}
+	if command == nil {
+		return &commandResult{Reply: fmt.Sprintf("Unknown command /%s.", name)}, nil
+	}
+
+	options, err := parseOptions(command.Options, args)
+	if err != nil {
+		return &commandResult{
+			Reply: fmt.Sprintf(
+				"Couldn't run /%s: %s. Usage: %s",
+				name,
+				err.Error(),
+				usage(command),
+			),
+		}, nil
+	}
+
+	if command.BotID != "" {
+		s.eventService.PublishToUsers(dtos.CommandInvokeEvent, dtos.CommandInvocationData{
+			Name:      command.Name,
+			RoomID:    chatroomID,
+			UserID:    userID,
+			SessionID: sessionID,
+			Options:   options,
+		}, []string{command.BotID})
+		return &commandResult{}, nil
+	}
+
+	result, err := s.runBuiltin(chatroom, userID, command.Name, options)
+	if errors.Is(err, ErrNotModerator) {
+		return &commandResult{Reply: fmt.Sprintf("Only moderators can use /%s.", name)}, nil
+	} else if errors.Is(err, ErrNotGroup) {
+		return &commandResult{Reply: fmt.Sprintf("/%s only works in groups.", name)}, nil
+	} else if errors.Is(err, ErrInvalidChatRoom) ||
+		errors.Is(err, ErrInvalidKick) ||
+		errors.Is(err, ErrInvalidSettings) {
+		return &commandResult{Reply: err.Error()}, nil
+	}
+	return result, err
+}
+
+func (s *CommandService) runBuiltin(
+	chatroom *models.ChatRoom,
+	userID, name string,
+	options map[string]any,
+) (*commandResult, error) {
+	switch name {
+	case "me":
+		return &commandResult{Content: "*" + options["action"].(string) + "*"}, nil
+	case "shrug":
+		message, _ := options["message"].(string)
+		return &commandResult{
+			Content: strings.TrimSpace(message + " " + markdown.Escape(`¯\_(ツ)_/¯`)),
+		}, nil
+	case "topic":
+		topic, ok := options["topic"].(string)
+		if !ok {
+			if chatroom.Topic == "" {
+				return &commandResult{Reply: "There's no topic."}, nil
+			}
+			return &commandResult{Reply: "The topic is: " + markdown.Escape(chatroom.Topic)}, nil
+		}
+		_, err := s.chatroomService.SetTopic(chatroom.ID, userID, topic)
+		return &commandResult{}, err
+	case "invite":
+		code, err := s.chatroomService.CreateInviteCode(chatroom.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		return &commandResult{
+			Reply: fmt.Sprintf(
+				"Anyone can join with invite code `%s` for the next 24 hours.",
+				code,
+			),
+		}, nil
+	case "mute":
+		duration := defaultMuteDuration
+		if durationStr, ok := options["duration"].(string); ok {
+			var err error
+			duration, err = time.ParseDuration(durationStr)
+			if err != nil || duration <= 0 {
+				return &commandResult{Reply: "Durations look like 30m or 8h."}, nil
+			}
+		}
+
+		mutedUntil := time.Now().Add(duration).Format(time.RFC3339)
+		request := dtos.RoomSettingsRequest{MutedUntil: &mutedUntil}
+		if _, err := s.notificationService.UpdateRoomSettings(userID, chatroom.ID, request); err != nil {
+			return nil, err
+		}
+		return &commandResult{
+			Reply: fmt.Sprintf("Muted notifications from this chat room for %s.", duration),
+		}, nil
+	case "kick":
+		err := s.chatroomService.Kick(chatroom.ID, userID, options["user"].(string))
+		return &commandResult{}, err
+	}
+
+	return nil, fmt.Errorf("built-in command /%s isn't implemented", name)
+}
+
+// commands returns the commands in a room, the built-in ones first. Where
+// bots' commands share a name, the one registered first wins, and the others
+// are left out.
+func (s *CommandService) commands(chatroom *models.ChatRoom) ([]models.BotCommand, error) {
+	var botIDs []string
+	for _, participant := range chatroom.Participants {
+		if participant.Bot {
+			botIDs = append(botIDs, participant.ID)
+		}
+	}
+	botCommands, err := s.botCommandRepo.ListForBots(botIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	commands := make([]models.BotCommand, 0, len(builtinCommands)+len(botCommands))
+	names := make(map[string]bool)
+	for _, command := range slices.Concat(builtinCommands, botCommands) {
+		if names[command.Name] {
+			continue
+		}
+		if command.BotID == "" && chatroom.Type != models.GroupChatRoom &&
+			(command.Name == "topic" || command.Name == "kick") {
+			continue
+		}
+		names[command.Name] = true
+		commands = append(commands, command)
+	}
+
+	return commands, nil
+}
+
+// parseOptions parses a command's arguments by its options, returning them
+// by name. Options without an argument are left out.
+func parseOptions(options []models.CommandOption, args string) (map[string]any, error) {
+	values := make(map[string]any, len(options))
+	rest := strings.TrimSpace(args)
+
+	for i, option := range options {
+		if rest == "" {
+			if option.Required {
+				return nil, fmt.Errorf("missing %s", option.Name)
+			}
+			continue
+		}
+
+		arg := rest
+		rest = ""
+		if option.Type != models.StringOption || i < len(options)-1 {
+			if j := strings.IndexFunc(arg, unicode.IsSpace); j >= 0 {
+				arg, rest = arg[:j], strings.TrimSpace(arg[j:])
+			}
+		}
+
+		switch option.Type {
+		case models.StringOption:
+			values[option.Name] = arg
+		case models.IntegerOption:
+			n, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a whole number", option.Name)
+			}
+			values[option.Name] = n
+		case models.BooleanOption:
+			b, err := strconv.ParseBool(arg)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be true or false", option.Name)
+			}
+			values[option.Name] = b
+		case models.UserOption:
+			userID, ok := strings.CutPrefix(arg, "<@")
+			userID, ok2 := strings.CutSuffix(userID, ">")
+			if !ok || !ok2 || userID == "" {
+				return nil, fmt.Errorf("%s must mention a user", option.Name)
+			}
+			values[option.Name] = userID
+		}
+	}
+
+	if rest != "" {
+		return nil, errors.New("too many arguments")
+	}
+	return values, nil
+}
+
+// usage describes how to invoke a command, like /kick <user> [reason].
+func usage(command *models.BotCommand) string {
+	var b strings.Builder
+	b.WriteString("/" + command.Name)
+	for _, option := range command.Options {
+		if option.Required {
+			b.WriteString(" <" + option.Name + ">")
+		} else {
+			b.WriteString(" [" + option.Name + "]")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	htmltemplate "html/template"
+	"net/url"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/teamyapchat/yapchat-server/internal/mail"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+)
+
+var ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+
+const (
+	digestInterval = 15 * time.Minute
+	// Expires before the next run so another node can pick it up
+	digestLockTTL   = digestInterval - time.Minute
+	digestLockKey   = "digest:lock"
+	digestBatchSize = 100
+	// Per section, so a busy inbox doesn't make for an endless email
+	digestMaxItems = 20
+	digestSubject  = "What you missed on YapChat"
+)
+
+//go:embed templates/digest.html templates/digest.txt
+var digestTemplates embed.FS
+
+var (
+	digestHTMLTemplate = htmltemplate.Must(
+		htmltemplate.ParseFS(digestTemplates, "templates/digest.html"),
+	)
+	digestTextTemplate = texttemplate.Must(
+		texttemplate.ParseFS(digestTemplates, "templates/digest.txt"),
+	)
+)
+
+type digestData struct {
+	Name           string
+	Mentions       []digestItem
+	DirectMessages []digestItem
+	UnsubscribeURL string
+}
+
+type digestItem struct {
+	Sender  string
+	Room    string
+	Preview string
+	Time    string
+}
+
+// DigestService emails users who have been away a summary of the mentions
+// and direct messages they haven't read, as often as each user asks for.
+type DigestService struct {
+	rdb            *redis.Client
+	userRepo       repositories.UserRepository
+	mentionRepo    *repositories.MentionRepository
+	messageRepo    *repositories.MessageRepository
+	sessionService *SessionService
+	mailer         mail.Mailer
+	signingKey     []byte
+	publicURL      string
+	nodeID         string
+}
+
+func NewDigestService(
+	rdb *redis.Client,
+	userRepo repositories.UserRepository,
+	mentionRepo *repositories.MentionRepository,
+	messageRepo *repositories.MessageRepository,
+	sessionService *SessionService,
+	mailer mail.Mailer,
+	signingKey string,
+	publicURL string,
+	nodeID string,
+) *DigestService {
+	return &DigestService{
+		rdb:            rdb,
+		userRepo:       userRepo,
+		mentionRepo:    mentionRepo,
+		messageRepo:    messageRepo,
+		sessionService: sessionService,
+		mailer:         mailer,
+		signingKey:     []byte(signingKey),
+		publicURL:      publicURL,
+		nodeID:         nodeID,
+	}
+}
+
+// Run sends the digests that are due every digestInterval until ctx is
+// cancelled. Only one node sends them at a time.
+func (s *DigestService) Run(ctx context.Context) {
+	ticker := time.NewTicker(digestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			acquired, err := s.rdb.SetNX(ctx, digestLockKey, s.nodeID, digestLockTTL).Result()
+			if err != nil {
+				log.Error("Failed to acquire digest lock", "err", err.Error())
+				continue
+			}
+			if acquired {
+				s.sendDue(ctx)
+			}
+		}
+	}
+}
+
+func (s *DigestService) sendDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := time.Now()
+		users, err := s.userRepo.ListDueForDigest(now, digestBatchSize)
+		if err != nil {
+			log.Error("Failed to list users due a digest", "err", err.Error())
+			return
+		}
+
+		for _, user := range users {
+			if err := s.send(ctx, user, now); err != nil {
+				log.Error("Failed to send digest", "userID", user.ID, "err", err.Error())
+			}
+
+			// Move on even if sending failed, rather than retrying every run
+			if err := s.userRepo.UpdateLastDigestAt(user.ID, now); err != nil {
+				log.Error("Failed to record digest", "userID", user.ID, "err", err.Error())
+				return
+			}
+		}
+
+		if len(users) < digestBatchSize {
+			return
+		}
+	}
+}
+
+// send emails the user a digest of what's unread since their last one. Users
+// who are online, or have nothing unread, are skipped.
+func (s *DigestService) send(ctx context.Context, user *models.User, now time.Time) error {
+	sessions, err := s.sessionService.CountLive(user.ID)
+	if err != nil {
+		return err
+	}
+	if sessions[user.ID] > 0 {
+		return nil
+	}
+
+	since := now.Add(-user.DigestFrequency.Interval())
+	if user.LastDigestAt != nil {
+		since = *user.LastDigestAt
+	}
+
+	mentions, err := s.mentionRepo.ListUnreadSince(user.ID, since, digestMaxItems)
+	if err != nil {
+		return err
+	}
+	directMessages, err := s.messageRepo.ListUnreadDirect(user.ID, since, digestMaxItems)
+	if err != nil {
+		return err
+	}
+	if len(mentions) == 0 && len(directMessages) == 0 {
+		return nil
+	}
+
+	unsubscribeURL := s.unsubscribeURL(user.ID)
+	data := digestData{
+		Name:           user.Name(),
+		UnsubscribeURL: unsubscribeURL,
+	}
+	for _, mention := range mentions {
+		data.Mentions = append(data.Mentions, newDigestItem(&mention.Message))
+	}
+	for _, message := range directMessages {
+		data.DirectMessages = append(data.DirectMessages, newDigestItem(&message))
+	}
+
+	var html, text bytes.Buffer
+	if err := digestHTMLTemplate.Execute(&html, data); err != nil {
+		return err
+	}
+	if err := digestTextTemplate.Execute(&text, data); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, &mail.Message{
+		To:      user.Email,
+		Subject: digestSubject,
+		Text:    text.String(),
+		HTML:    html.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+}
+
+// Unsubscribe turns off digests for the user an unsubscribe link was made
+// for.
+func (s *DigestService) Unsubscribe(token string) error {
+	// User IDs can contain dots, but signatures can't
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return ErrInvalidUnsubscribeToken
+	}
+	userID, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(s.sign(userID))) {
+		return ErrInvalidUnsubscribeToken
+	}
+
+	return s.userRepo.UpdateDigestFrequency(userID, models.DigestNever)
+}
+
+func (s *DigestService) unsubscribeURL(userID string) string {
+	token := userID + "." + s.sign(userID)
+	return s.publicURL + "/digest/unsubscribe?token=" + url.QueryEscape(token)
+}
+
+func (s *DigestService) sign(userID string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte("digest-unsubscribe:" + userID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newDigestItem(message *models.Message) digestItem {
+	return digestItem{
+		Sender:  message.SenderName(),
+		Room:    message.Room.Name,
+		Preview: previewContent(message.Content),
+		Time:    message.CreatedAt.UTC().Format("Jan 2, 15:04 MST"),
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/nats-io/nats.go"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+)
+
+const (
+	GatewayEventsSubject = "gateway_events"
+	ChatMessagesSubject  = "chat_messages"
+)
+
+type EventService struct {
+	nc *nats.Conn
+}
+
+func NewEventService(nc *nats.Conn) *EventService {
+	return &EventService{nc: nc}
+}
+
+func (s *EventService) PublishToUsers(eventType dtos.EventType, data any, userIDs []string) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	s.publish(dtos.GatewayEvent{
+		Event:        dtos.Event{Type: eventType, Data: data, Timestamp: time.Now()},
+		RecipientIDs: userIDs,
+	})
+}
+
+// PublishToSession sends an event to one of a user's sessions, or all of
+// them if sessionID is empty.
+func (s *EventService) PublishToSession(
+	eventType dtos.EventType,
+	data any,
+	userID, sessionID string,
+) {
+	s.publish(dtos.GatewayEvent{
+		Event:        dtos.Event{Type: eventType, Data: data, Timestamp: time.Now()},
+		RecipientIDs: []string{userID},
+		SessionID:    sessionID,
+	})
+}
+
+func (s *EventService) PublishToRoom(eventType dtos.EventType, data any, roomID uint) {
+	s.publish(dtos.GatewayEvent{
+		Event:  dtos.Event{Type: eventType, Data: data, Timestamp: time.Now()},
+		RoomID: roomID,
+	})
+}
+
+// PublishMessage hands a chat message to every gateway node to deliver to
+// the room, and to the push service.
+func (s *EventService) PublishMessage(msg dtos.MessageResponse) {
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		log.Error("Error marshaling message", "messageID", msg.ID, "err", err.Error())
+		return
+	}
+
+	if err := s.nc.Publish(ChatMessagesSubject, msgJSON); err != nil {
+		log.Error("Error publishing message", "messageID", msg.ID, "err", err.Error())
+	}
+}
+
+func (s *EventService) publish(event dtos.GatewayEvent) {
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		log.Error("Error marshaling gateway event", "type", event.Event.Type, "err", err.Error())
+		return
+	}
+
+	if err := s.nc.Publish(GatewayEventsSubject, eventJSON); err != nil {
+		log.Error("Error publishing gateway event", "type", event.Event.Type, "err", err.Error())
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/teamyapchat/yapchat-server/internal/imaging"
+	"github.com/teamyapchat/yapchat-server/internal/storage"
+)
+
+var (
+	ErrInvalidImage  = errors.New("invalid image")
+	ErrImageTooLarge = errors.New("image too large")
+	ErrImageNotFound = errors.New("image not found")
+)
+
+const (
+	// Avatars and room icons are shown small, so they're stored no larger
+	// than this
+	maxImageDimension  = 512
+	MaxImageUploadSize = 8 << 20
+
+	imageKeyPrefix = "images/"
+)
+
+// imageExtensions maps the formats the imaging pipeline produces to the file
+// extension their keys end in.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// ImageService stores public images such as avatars and room icons. Unlike
+// attachments they're served without signed URLs, under keys that change
+// every upload so they can be cached forever.
+type ImageService struct {
+	store   storage.BlobStore
+	baseURL string
+}
+
+func NewImageService(store storage.BlobStore, baseURL string) *ImageService {
+	return &ImageService{
+		store:   store,
+		baseURL: baseURL,
+	}
+}
+
+// Upload normalises an image and stores it under dir, returning its key and
+// public URL.
+func (s *ImageService) Upload(
+	ctx context.Context,
+	dir string,
+	r io.Reader,
+	size int64,
+) (string, string, error) {
+	if size > MaxImageUploadSize {
+		return "", "", ErrImageTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, MaxImageUploadSize+1))
+	if err != nil {
+		return "", "", err
+	}
+	if len(data) > MaxImageUploadSize {
+		return "", "", ErrImageTooLarge
+	}
+
+	processed, err := imaging.Process(data, imaging.Options{MaxDimension: maxImageDimension})
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+
+	key := imageKeyPrefix + dir + "/" + generateULID() + imageExtensions[processed.ContentType]
+	err = s.store.Put(
+		ctx,
+		key,
+		bytes.NewReader(processed.Data),
+		int64(len(processed.Data)),
+		processed.ContentType,
+	)
+	if err != nil {
+		return "", "", err
+	}
+
+	return key, s.URL(key), nil
+}
+
+// Delete removes a previously uploaded image, logging rather than failing
+// since a leftover blob is harmless.
+func (s *ImageService) Delete(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+
+	if err := s.store.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrBlobNotFound) {
+		log.Error("Failed to delete image", "key", key, "err", err.Error())
+	}
+}
+
+func (s *ImageService) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// Open returns the contents of a public image along with its content type.
+func (s *ImageService) Open(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if !strings.HasPrefix(key, imageKeyPrefix) || path.Clean(key) != key {
+		return nil, "", ErrImageNotFound
+	}
+
+	var contentType string
+	for ct, ext := range imageExtensions {
+		if path.Ext(key) == ext {
+			contentType = ct
+		}
+	}
+	if contentType == "" {
+		return nil, "", ErrImageNotFound
+	}
+
+	blob, err := s.store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			return nil, "", ErrImageNotFound
+		}
+		return nil, "", err
+	}
+
+	return blob, contentType, nil
+}
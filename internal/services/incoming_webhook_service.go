@@ -0,0 +1,231 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+)
+
+var (
+	ErrWebhookNotFound     = errors.New("webhook not found")
+	ErrInvalidWebhook      = errors.New("invalid webhook")
+	ErrWebhookLimitReached = errors.New("webhook limit reached")
+	ErrWebhookDisabled     = errors.New("webhook disabled")
+)
+
+const (
+	MaxWebhooksPerRoom = 10
+	maxWebhookNameLen  = 80
+)
+
+// IncomingWebhookService manages the webhooks moderators create to let other
+// systems post into their rooms, and posts the messages sent to them.
+type IncomingWebhookService struct {
+	webhookRepo     *repositories.IncomingWebhookRepository
+	chatroomService *ChatRoomService
+	messageService  *MessageService
+	eventService    *EventService
+	publicURL       string
+}
+
+func NewIncomingWebhookService(
+	webhookRepo *repositories.IncomingWebhookRepository,
+	chatroomService *ChatRoomService,
+	messageService *MessageService,
+	eventService *EventService,
+	publicURL string,
+) *IncomingWebhookService {
+	return &IncomingWebhookService{
+		webhookRepo:     webhookRepo,
+		chatroomService: chatroomService,
+		messageService:  messageService,
+		eventService:    eventService,
+		publicURL:       publicURL,
+	}
+}
+
+func (s *IncomingWebhookService) List(
+	chatroomID uint,
+	userID string,
+) ([]models.IncomingWebhook, error) {
+	if _, err := s.getAsModerator(chatroomID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.webhookRepo.ListForRoom(chatroomID)
+}
+
+// Create creates a webhook for a room, returning it along with its URL,
+// which holds the secret token and can't be shown again.
+func (s *IncomingWebhookService) Create(
+	chatroomID uint,
+	userID string,
+	request dtos.CreateIncomingWebhookRequest,
+) (*models.IncomingWebhook, string, error) {
+	if _, err := s.getAsModerator(chatroomID, userID); err != nil {
+		return nil, "", err
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidWebhook)
+	}
+	if err := validateProfileText("name", name, maxWebhookNameLen); err != nil {
+		return nil, "", err
+	}
+	if err := validateImageURL(request.AvatarURL); err != nil {
+		return nil, "", err
+	}
+
+	count, err := s.webhookRepo.CountForRoom(chatroomID)
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= MaxWebhooksPerRoom {
+		return nil, "", ErrWebhookLimitReached
+	}
+
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	token := hex.EncodeToString(secret)
+
+	webhook := &models.IncomingWebhook{
+		RoomID:    chatroomID,
+		CreatorID: userID,
+		Name:      name,
+		AvatarURL: request.AvatarURL,
+		TokenHash: hashWebhookToken(token),
+	}
+	if err := s.webhookRepo.Create(webhook); err != nil {
+		return nil, "", err
+	}
+
+	return webhook, fmt.Sprintf("%s/webhooks/%d/%s", s.publicURL, webhook.ID, token), nil
+}
+
+func (s *IncomingWebhookService) Delete(chatroomID uint, userID string, webhookID uint) error {
+	if _, err := s.getAsModerator(chatroomID, userID); err != nil {
+		return err
+	}
+
+	found, err := s.webhookRepo.Delete(webhookID, chatroomID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// Execute posts a message to the webhook's room, delivering it like any
+// other message. Unknown webhooks and wrong tokens both give
+// ErrWebhookNotFound. Webhooks only work while their creator can still
+// manage webhooks in the room, since messages are sent on their behalf.
+func (s *IncomingWebhookService) Execute(
+	webhookID uint,
+	token string,
+	request dtos.ExecuteIncomingWebhookRequest,
+) (*models.Message, error) {
+	webhook, err := s.webhookRepo.GetByID(webhookID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashWebhookToken(token)), []byte(webhook.TokenHash)) != 1 {
+		return nil, ErrWebhookNotFound
+	}
+
+	_, err = s.getAsModerator(webhook.RoomID, webhook.CreatorID)
+	if errors.Is(err, ErrChatRoomNotFound) {
+		return nil, ErrWebhookNotFound
+	} else if errors.Is(err, ErrNotParticipant) {
+		return nil, fmt.Errorf("%w: its creator has left the chat room", ErrWebhookDisabled)
+	} else if errors.Is(err, ErrNotModerator) {
+		return nil, fmt.Errorf("%w: its creator is no longer a moderator", ErrWebhookDisabled)
+	} else if err != nil {
+		return nil, err
+	}
+
+	username := webhook.Name
+	if request.Username != "" {
+		username = strings.TrimSpace(request.Username)
+		if username == "" {
+			return nil, fmt.Errorf("%w: username must not be blank", ErrInvalidWebhook)
+		}
+		if err := validateProfileText("username", username, maxWebhookNameLen); err != nil {
+			return nil, err
+		}
+	}
+	avatarURL := webhook.AvatarURL
+	if request.AvatarURL != "" {
+		if err := validateImageURL(request.AvatarURL); err != nil {
+			return nil, err
+		}
+		avatarURL = request.AvatarURL
+	}
+
+	content, entities, err := s.messageService.Format(
+		request.Content,
+		webhook.CreatorID,
+		webhook.RoomID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if content == "" {
+		return nil, fmt.Errorf("%w: content is required", ErrInvalidMessage)
+	}
+
+	message := &models.Message{
+		SenderID:  webhook.CreatorID,
+		RoomID:    webhook.RoomID,
+		Type:      models.UserMessage,
+		Content:   content,
+		Entities:  entities,
+		WebhookID: &webhook.ID,
+		Username:  username,
+		AvatarURL: avatarURL,
+		Timestamp: time.Now(),
+	}
+	if err := s.messageService.CreateMessage(message); err != nil {
+		return nil, err
+	}
+
+	s.eventService.PublishMessage(dtos.NewMessageResponse(message))
+	return message, nil
+}
+
+func (s *IncomingWebhookService) getAsModerator(
+	chatroomID uint,
+	userID string,
+) (*models.ChatRoom, error) {
+	chatroom, err := s.chatroomService.getAsParticipant(chatroomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !chatroom.IsModerator(userID) {
+		return nil, ErrNotModerator
+	}
+
+	return chatroom, nil
+}
+
+func hashWebhookToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/clerk/clerk-sdk-go/v2/jwks"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrUnknownKeyID = errors.New("unknown key ID")
+
+const (
+	jwkTTL = time.Hour
+	// Refreshed well before keys expire so verification never waits on Clerk
+	jwksRefreshInterval = 45 * time.Minute
+	// Key IDs Clerk doesn't know are remembered so that tokens with made up
+	// key IDs can't make every request fetch the key set
+	unknownKeyIDTTL  = 5 * time.Minute
+	maxUnknownKeyIDs = 1000
+	// Fetches for a key ID that isn't cached are limited to one per interval
+	minJWKSFetchInterval = 30 * time.Second
+)
+
+// jwksMetrics counts how key lookups were served, published at /debug/vars
+// on the metrics listener.
+var jwksMetrics = expvar.NewMap("jwks")
+
+type cachedJWK struct {
+	jwk       *clerk.JSONWebKey
+	expiresAt time.Time
+}
+
+// JWKSCache caches Clerk's signing keys in memory, in front of Redis, which
+// is shared between nodes, in front of Clerk's JWKS endpoint.
+type JWKSCache struct {
+	jwksClient  *jwks.Client
+	redisClient *redis.Client
+
+	mu      sync.RWMutex
+	keys    map[string]cachedJWK
+	unknown map[string]time.Time // key ID -> when to forget it
+
+	fetchMu      sync.Mutex
+	lastFetch    time.Time
+	lastFetchErr error
+}
+
+func NewJWKSCache(clerkSecretKey string, redisClient *redis.Client) *JWKSCache {
+	config := &clerk.ClientConfig{}
+	config.Key = clerk.String(clerkSecretKey)
+
+	return &JWKSCache{
+		jwksClient:  jwks.NewClient(config),
+		redisClient: redisClient,
+		keys:        make(map[string]cachedJWK),
+		unknown:     make(map[string]time.Time),
+	}
+}
+
+// Run refreshes the key set every jwksRefreshInterval until ctx is
+// cancelled.
+func (c *JWKSCache) Run(ctx context.Context) {
+	if err := c.fetch(ctx); err != nil {
+		log.Error("Failed to fetch JWKS", "err", err.Error())
+	}
+
+	ticker := time.NewTicker(jwksRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.fetch(ctx); err != nil {
+				log.Error("Failed to refresh JWKS", "err", err.Error())
+			}
+		}
+	}
+}
+
+// Get returns the key with the given ID, or ErrUnknownKeyID if Clerk doesn't
+// have one.
+func (c *JWKSCache) Get(ctx context.Context, keyID string) (*clerk.JSONWebKey, error) {
+	now := time.Now()
+
+	c.mu.RLock()
+	cached, ok := c.keys[keyID]
+	unknownUntil, unknown := c.unknown[keyID]
+	c.mu.RUnlock()
+
+	if ok && now.Before(cached.expiresAt) {
+		jwksMetrics.Add("memory_hits", 1)
+		return cached.jwk, nil
+	}
+
+	// Checked before the key ID is taken to be unknown, since another node
+	// may have fetched it since this one last did
+	if jwk, expiresAt := c.getFromRedis(ctx, keyID); jwk != nil {
+		jwksMetrics.Add("redis_hits", 1)
+		c.mu.Lock()
+		c.keys[keyID] = cachedJWK{jwk: jwk, expiresAt: expiresAt}
+		delete(c.unknown, keyID)
+		c.mu.Unlock()
+		return jwk, nil
+	}
+
+	if unknown && now.Before(unknownUntil) {
+		jwksMetrics.Add("unknown_hits", 1)
+		return nil, ErrUnknownKeyID
+	}
+
+	jwksMetrics.Add("misses", 1)
+	return c.fetchKey(ctx, keyID)
+}
+
+// fetchKey fetches the key set for a key ID that isn't cached. Concurrent
+// misses share one fetch, and a key ID still missing after a fetch it made
+// is remembered as unknown.
+func (c *JWKSCache) fetchKey(ctx context.Context, keyID string) (*clerk.JSONWebKey, error) {
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+
+	// Another request may have fetched it while this one waited
+	c.mu.RLock()
+	cached, ok := c.keys[keyID]
+	c.mu.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.jwk, nil
+	}
+
+	// Fetched too recently to fetch again, so the key set may predate the
+	// key ID, which is turned away without being remembered as unknown
+	if time.Since(c.lastFetch) < minJWKSFetchInterval {
+		if c.lastFetchErr != nil {
+			return nil, c.lastFetchErr
+		}
+		return nil, ErrUnknownKeyID
+	}
+
+	// Without an up to date key set there's no telling whether the key ID
+	// is unknown
+	if err := c.fetchLocked(ctx); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	cached, ok = c.keys[keyID]
+	c.mu.RUnlock()
+	if ok {
+		return cached.jwk, nil
+	}
+
+	c.mu.Lock()
+	if len(c.unknown) >= maxUnknownKeyIDs {
+		clear(c.unknown)
+	}
+	c.unknown[keyID] = time.Now().Add(unknownKeyIDTTL)
+	c.mu.Unlock()
+
+	return nil, ErrUnknownKeyID
+}
+
+func (c *JWKSCache) fetch(ctx context.Context) error {
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+	return c.fetchLocked(ctx)
+}
+
+// fetchLocked replaces the cached key set with Clerk's. fetchMu must be
+// held.
+func (c *JWKSCache) fetchLocked(ctx context.Context) error {
+	c.lastFetch = time.Now()
+	jwksMetrics.Add("fetches", 1)
+
+	keySet, err := c.jwksClient.Get(ctx, &jwks.GetParams{})
+	c.lastFetchErr = err
+	if err != nil {
+		jwksMetrics.Add("fetch_errors", 1)
+		return err
+	}
+
+	expiresAt := time.Now().Add(jwkTTL)
+	keys := make(map[string]cachedJWK, len(keySet.Keys))
+	for _, jwk := range keySet.Keys {
+		if jwk == nil || jwk.KeyID == "" {
+			continue
+		}
+		keys[jwk.KeyID] = cachedJWK{jwk: jwk, expiresAt: expiresAt}
+		c.setInRedis(ctx, jwk)
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	for keyID := range keys {
+		delete(c.unknown, keyID)
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *JWKSCache) getFromRedis(
+	ctx context.Context,
+	keyID string,
+) (*clerk.JSONWebKey, time.Time) {
+	pipe := c.redisClient.Pipeline()
+	get := pipe.Get(ctx, "jwks:"+keyID)
+	ttl := pipe.PTTL(ctx, "jwks:"+keyID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Error("Failed to get cached JWK", "keyID", keyID, "err", err.Error())
+		}
+		return nil, time.Time{}
+	}
+
+	var jwk clerk.JSONWebKey
+	if err := json.Unmarshal([]byte(get.Val()), &jwk); err != nil {
+		return nil, time.Time{}
+	}
+
+	return &jwk, time.Now().Add(ttl.Val())
+}
+
+func (c *JWKSCache) setInRedis(ctx context.Context, jwk *clerk.JSONWebKey) {
+	jwkData, err := json.Marshal(jwk)
+	if err != nil {
+		log.Error("Failed to serialize JWK", "err", err.Error())
+		return
+	}
+
+	err = c.redisClient.Set(ctx, "jwks:"+jwk.KeyID, jwkData, jwkTTL).Err()
+	if err != nil {
+		log.Error("Failed to cache JWK", "err", err.Error())
+	}
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+
+	"github.com/charmbracelet/log"
+	"github.com/redis/go-redis/v9"
+)
+
+// releaseLockScript only deletes a lock still held by the node releasing it.
+// A node that stalled past the lock's TTL may find another node holding it.
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// releaseLock releases a lock taken with SetNX and the node's ID as its value.
+func releaseLock(ctx context.Context, rdb *redis.Client, key, nodeID string) {
+	if err := releaseLockScript.Run(ctx, rdb, []string{key}, nodeID).Err(); err != nil {
+		log.Error("Failed to release lock", "key", key, "err", err.Error())
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+)
+
+var ErrMentionNotFound = errors.New("mention not found")
+
+type MentionService struct {
+	mentionRepo     *repositories.MentionRepository
+	chatroomRepo    *repositories.ChatRoomRepository
+	presenceService *PresenceService
+	eventService    *EventService
+}
+
+func NewMentionService(
+	mentionRepo *repositories.MentionRepository,
+	chatroomRepo *repositories.ChatRoomRepository,
+	presenceService *PresenceService,
+	eventService *EventService,
+) *MentionService {
+	return &MentionService{
+		mentionRepo:     mentionRepo,
+		chatroomRepo:    chatroomRepo,
+		presenceService: presenceService,
+		eventService:    eventService,
+	}
+}
+
+// Record adds a persisted message to the inbox of everyone it mentions and
+// notifies them. The MENTION_CREATE event is sent whatever the recipient's
+// notification settings are, so a mention is never silently dropped.
+//
+// Mass mentions are expected to have been checked by MessageService.Format.
+func (s *MentionService) Record(message *models.Message) error {
+	var userIDs []string
+	var here, everyone bool
+	for _, entity := range message.Entities {
+		switch entity.Type {
+		case models.UserMentionEntity:
+			userIDs = append(userIDs, entity.UserID)
+		case models.HereMentionEntity:
+			here = true
+		case models.EveryoneMentionEntity:
+			everyone = true
+		}
+	}
+
+	if len(userIDs) == 0 && !here && !everyone {
+		return nil
+	}
+
+	chatroom, err := s.chatroomRepo.GetByID(message.RoomID)
+	if err != nil {
+		return err
+	}
+
+	participantIDs := make([]string, 0, len(chatroom.Participants))
+	for _, participant := range chatroom.Participants {
+		participantIDs = append(participantIDs, participant.ID)
+	}
+
+	recipients := make(map[string]models.MentionType)
+	switch {
+	case everyone:
+		for _, id := range participantIDs {
+			recipients[id] = models.EveryoneMention
+		}
+	case here:
+		presences, err := s.presenceService.GetVisible(participantIDs...)
+		if err != nil {
+			return err
+		}
+		for id, presence := range presences {
+			if presence.Status != models.StatusOffline {
+				recipients[id] = models.HereMention
+			}
+		}
+	}
+
+	// Being mentioned by name takes precedence over a mass mention
+	for _, id := range userIDs {
+		if _, ok := recipients[id]; ok || slices.Contains(participantIDs, id) {
+			recipients[id] = models.UserMention
+		}
+	}
+
+	if message.WebhookID == nil {
+		delete(recipients, message.SenderID)
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	mentions := make([]models.Mention, 0, len(recipients))
+	for userID, mentionType := range recipients {
+		mentions = append(mentions, models.Mention{
+			UserID:    userID,
+			MessageID: message.ID,
+			RoomID:    message.RoomID,
+			Type:      mentionType,
+		})
+	}
+
+	if err := s.mentionRepo.CreateBatch(mentions); err != nil {
+		return err
+	}
+
+	for i := range mentions {
+		mentions[i].Message = *message
+		s.eventService.PublishToUsers(
+			dtos.MentionCreateEvent,
+			dtos.NewMentionResponse(&mentions[i]),
+			[]string{mentions[i].UserID},
+		)
+	}
+
+	return nil
+}
+
+func (s *MentionService) List(
+	userID string,
+	unreadOnly bool,
+	cursor uint,
+	limit int,
+) ([]models.Mention, error) {
+	return s.mentionRepo.ListForUser(userID, unreadOnly, cursor, limit)
+}
+
+func (s *MentionService) MarkRead(userID string, id uint) error {
+	found, err := s.mentionRepo.MarkRead(userID, id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrMentionNotFound
+	}
+	return nil
+}
+
+// MarkAllRead clears the user's inbox, or only the mentions from one room if
+// roomID isn't zero.
+func (s *MentionService) MarkAllRead(userID string, roomID uint) error {
+	if err := s.mentionRepo.MarkAllRead(userID, roomID); err != nil {
+		log.Error("Failed to mark mentions as read", "userID", userID, "err", err.Error())
+		return err
+	}
+	return nil
+}
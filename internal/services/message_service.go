@@ -1,20 +1,242 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/markdown"
 	"github.com/teamyapchat/yapchat-server/internal/models"
 	"github.com/teamyapchat/yapchat-server/internal/repositories"
+	"github.com/teamyapchat/yapchat-server/internal/search"
+)
+
+var (
+	ErrInvalidMessage = errors.New("invalid message")
+	ErrNotBot         = errors.New("user not a bot")
+)
+
+const (
+	reindexBatchSize = 500
+	MaxMessageLength = 4000
+	// How much of a message notifications and emails show
+	previewLength = 200
 )
 
 type MessageService struct {
-	messageRepo repositories.MessageRepository
+	messageRepo    repositories.MessageRepository
+	userRepo       repositories.UserRepository
+	chatroomRepo   *repositories.ChatRoomRepository
+	searchIndex    search.Index
+	unfurlService  *UnfurlService
+	mentionService *MentionService
+	eventService   *EventService
+	webhooks       *OutgoingWebhookService
+}
+
+func NewMessageService(
+	messageRepo *repositories.MessageRepository,
+	userRepo repositories.UserRepository,
+	chatroomRepo *repositories.ChatRoomRepository,
+	searchIndex search.Index,
+	unfurlService *UnfurlService,
+	mentionService *MentionService,
+	eventService *EventService,
+	webhookService *OutgoingWebhookService,
+) *MessageService {
+	return &MessageService{
+		messageRepo:    *messageRepo,
+		userRepo:       userRepo,
+		chatroomRepo:   chatroomRepo,
+		searchIndex:    searchIndex,
+		unfurlService:  unfurlService,
+		mentionService: mentionService,
+		eventService:   eventService,
+		webhooks:       webhookService,
+	}
 }
 
-func NewMessageService(messageRepo *repositories.MessageRepository) *MessageService {
-	return &MessageService{messageRepo: *messageRepo}
+// Format normalises the content of a message about to be sent and parses its
+// formatting. Mentions of users that don't exist, or of rooms the sender
+// isn't in, are left as plain text, as are @everyone and @here from senders
+// who aren't allowed to use them in the room.
+func (s *MessageService) Format(
+	content, senderID string,
+	roomID uint,
+) (string, []models.Entity, error) {
+	content = markdown.Normalize(content)
+	if utf8.RuneCountInString(content) > MaxMessageLength {
+		return "", nil, fmt.Errorf(
+			"%w: content must be at most %d characters",
+			ErrInvalidMessage,
+			MaxMessageLength,
+		)
+	}
+
+	entities := markdown.Parse(content)
+
+	var userIDs []string
+	var mentionsRooms, mentionsEveryone bool
+	for _, entity := range entities {
+		switch entity.Type {
+		case models.UserMentionEntity:
+			userIDs = append(userIDs, entity.UserID)
+		case models.RoomMentionEntity:
+			mentionsRooms = true
+		case models.EveryoneMentionEntity, models.HereMentionEntity:
+			mentionsEveryone = true
+		}
+	}
+
+	var existingUserIDs []string
+	if len(userIDs) > 0 {
+		var err error
+		existingUserIDs, err = s.userRepo.FilterExisting(userIDs)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	var roomIDs []uint
+	if mentionsRooms {
+		var err error
+		roomIDs, err = s.chatroomRepo.ListIDs(senderID)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	var canMentionEveryone bool
+	if mentionsEveryone {
+		chatroom, err := s.chatroomRepo.GetByID(roomID)
+		if err != nil {
+			return "", nil, err
+		}
+		canMentionEveryone = chatroom.CanMentionEveryone(senderID)
+	}
+
+	entities = slices.DeleteFunc(entities, func(entity models.Entity) bool {
+		switch entity.Type {
+		case models.UserMentionEntity:
+			return !slices.Contains(existingUserIDs, entity.UserID)
+		case models.RoomMentionEntity:
+			return !slices.Contains(roomIDs, entity.RoomID)
+		case models.EveryoneMentionEntity, models.HereMentionEntity:
+			return !canMentionEveryone
+		}
+		return false
+	})
+
+	return content, entities, nil
 }
 
 func (s *MessageService) CreateMessage(message *models.Message) error {
-	return s.messageRepo.Create(message)
+	if err := s.messageRepo.Create(message); err != nil {
+		return err
+	}
+
+	if err := s.searchIndex.Index(message); err != nil {
+		log.Error("Failed to index message", "messageID", message.ID, "err", err.Error())
+	}
+
+	if err := s.mentionService.Record(message); err != nil {
+		log.Error("Failed to record mentions", "messageID", message.ID, "err", err.Error())
+	}
+
+	s.unfurlService.Enqueue(message)
+	s.webhooks.Dispatch(
+		models.MessageCreatedWebhookEvent,
+		message.RoomID,
+		message.SenderID,
+		dtos.NewMessageResponse(message),
+	)
+
+	return nil
+}
+
+// SendSystemMessage persists a system message and broadcasts it to the room.
+// Its content is parsed for formatting, but system messages aren't
+// searchable and don't notify anyone they mention or unfurl links.
+func (s *MessageService) SendSystemMessage(message *models.Message) error {
+	message.Type = models.SystemMessage
+	message.Entities = markdown.Parse(message.Content)
+	message.Timestamp = time.Now()
+	if err := s.messageRepo.Create(message); err != nil {
+		return err
+	}
+
+	s.eventService.PublishMessage(dtos.NewMessageResponse(message))
+	return nil
+}
+
+// SendEphemeral delivers a message to one user, or only one of their
+// sessions if sessionID isn't empty. Ephemeral messages are never stored, so
+// they're gone once the client forgets them. Messages without a sender are
+// notices from the server.
+func (s *MessageService) SendEphemeral(message *models.Message, userID, sessionID string) {
+	if message.SenderID == "" {
+		message.Type = models.SystemMessage
+		message.Entities = markdown.Parse(message.Content)
+	}
+	message.Timestamp = time.Now()
+
+	response := dtos.NewMessageResponse(message)
+	response.Ephemeral = true
+	s.eventService.PublishToSession(dtos.EphemeralMessageEvent, response, userID, sessionID)
+}
+
+// SendEphemeralAs sends an ephemeral message from a bot to someone in a room
+// it's in, such as a reply to one of its commands.
+func (s *MessageService) SendEphemeralAs(
+	roomID uint,
+	senderID string,
+	request dtos.EphemeralMessageRequest,
+) error {
+	chatroom, err := s.chatroomRepo.GetByID(roomID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrChatRoomNotFound
+		}
+		return err
+	}
+
+	senderIndex := slices.IndexFunc(chatroom.Participants, func(user *models.User) bool {
+		return user.ID == senderID
+	})
+	if senderIndex < 0 {
+		return ErrNotParticipant
+	}
+	if !chatroom.Participants[senderIndex].Bot {
+		return ErrNotBot
+	}
+	if !slices.ContainsFunc(chatroom.Participants, func(user *models.User) bool {
+		return user.ID == request.UserID
+	}) {
+		return fmt.Errorf("%w: recipient not in chat room", ErrInvalidMessage)
+	}
+
+	content, entities, err := s.Format(request.Content, senderID, roomID)
+	if err != nil {
+		return err
+	}
+	if content == "" {
+		return fmt.Errorf("%w: message is empty", ErrInvalidMessage)
+	}
+
+	s.SendEphemeral(&models.Message{
+		SenderID: senderID,
+		RoomID:   roomID,
+		Type:     models.UserMessage,
+		Content:  content,
+		Entities: entities,
+	}, request.UserID, request.SessionID)
+	return nil
 }
 
 func (s *MessageService) GetMessagesByRoomID(
@@ -27,3 +249,36 @@ func (s *MessageService) GetMessagesByRoomID(
 func (s *MessageService) GetCountByRoomID(roomID uint) (int, error) {
 	return s.messageRepo.GetCountByRoomID(roomID)
 }
+
+func (s *MessageService) Search(query search.Query) ([]search.Hit, error) {
+	return s.searchIndex.Search(query)
+}
+
+// RebuildIndex feeds every stored message to the search index, which is
+// needed for indexes that don't persist between restarts.
+func (s *MessageService) RebuildIndex() error {
+	return s.messageRepo.FindInBatches(reindexBatchSize, func(messages []models.Message) error {
+		for i := range messages {
+			if messages[i].Type == models.SystemMessage {
+				continue
+			}
+			if err := s.searchIndex.Index(&messages[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// previewContent shortens a message's content for notifications and emails.
+func previewContent(content string) string {
+	if content == "" {
+		return "Sent an attachment"
+	}
+
+	if utf8.RuneCountInString(content) <= previewLength {
+		return content
+	}
+	runes := []rune(content)
+	return string(runes[:previewLength-1]) + "…"
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+)
+
+var ErrInvalidSettings = errors.New("invalid settings")
+
+var roomNotificationLevels = []models.NotificationLevel{
+	models.NotifyDefault,
+	models.NotifyAll,
+	models.NotifyMentions,
+	models.NotifyNone,
+}
+
+// NotificationService decides who gets notified of a message, based on each
+// user's per-room settings and default notification level. It doesn't affect
+// delivery, or mentions, which always reach the user's inbox.
+type NotificationService struct {
+	settingsRepo *repositories.RoomSettingsRepository
+	chatroomRepo *repositories.ChatRoomRepository
+	eventService *EventService
+}
+
+func NewNotificationService(
+	settingsRepo *repositories.RoomSettingsRepository,
+	chatroomRepo *repositories.ChatRoomRepository,
+	eventService *EventService,
+) *NotificationService {
+	return &NotificationService{
+		settingsRepo: settingsRepo,
+		chatroomRepo: chatroomRepo,
+		eventService: eventService,
+	}
+}
+
+func (s *NotificationService) GetRoomSettings(
+	userID string,
+	roomID uint,
+) (*models.RoomSettings, error) {
+	if err := s.checkParticipant(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.settingsRepo.Get(userID, roomID)
+}
+
+// ListRoomSettings returns the settings the user has changed, by room ID.
+func (s *NotificationService) ListRoomSettings(
+	userID string,
+) (map[uint]*models.RoomSettings, error) {
+	settings, err := s.settingsRepo.ListForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byRoom := make(map[uint]*models.RoomSettings, len(settings))
+	for i := range settings {
+		byRoom[settings[i].RoomID] = &settings[i]
+	}
+	return byRoom, nil
+}
+
+func (s *NotificationService) UpdateRoomSettings(
+	userID string,
+	roomID uint,
+	data dtos.RoomSettingsRequest,
+) (*models.RoomSettings, error) {
+	settings, err := s.GetRoomSettings(userID, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Notifications != nil {
+		level := models.NotificationLevel(*data.Notifications)
+		if !slices.Contains(roomNotificationLevels, level) {
+			return nil, fmt.Errorf(
+				"%w: notifications must be default, all, mentions or none",
+				ErrInvalidSettings,
+			)
+		}
+		settings.Notifications = level
+	}
+	if data.MutedUntil != nil {
+		settings.MutedUntil = nil
+		if *data.MutedUntil != "" {
+			mutedUntil, err := time.Parse(time.RFC3339, *data.MutedUntil)
+			if err != nil {
+				return nil, fmt.Errorf("%w: muted until must be an RFC 3339 time", ErrInvalidSettings)
+			}
+			if mutedUntil.After(time.Now()) {
+				settings.MutedUntil = &mutedUntil
+			}
+		}
+	}
+
+	if err := s.settingsRepo.Save(settings); err != nil {
+		return nil, err
+	}
+
+	// Keep the user's other sessions in sync
+	s.eventService.PublishToUsers(
+		dtos.RoomSettingsUpdateEvent,
+		dtos.NewRoomSettingsResponse(settings),
+		[]string{userID},
+	)
+
+	return settings, nil
+}
+
+// FilterNotified returns the users among userIDs who should be notified of a
+// message in the room. mentioned reports whether the message mentions a user.
+func (s *NotificationService) FilterNotified(
+	roomID uint,
+	userIDs []string,
+	mentioned func(userID string) bool,
+) ([]string, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	settings, err := s.settingsRepo.ListEffective(roomID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	notified := make([]string, 0, len(settings))
+	for _, userSettings := range settings {
+		if userSettings.Notifies(mentioned(userSettings.UserID), now) {
+			notified = append(notified, userSettings.UserID)
+		}
+	}
+	return notified, nil
+}
+
+func (s *NotificationService) checkParticipant(roomID uint, userID string) error {
+	chatroom, err := s.chatroomRepo.GetByID(roomID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrChatRoomNotFound
+		}
+		return err
+	}
+
+	for _, participant := range chatroom.Participants {
+		if participant.ID == userID {
+			return nil
+		}
+	}
+	return ErrNotParticipant
+}
@@ -0,0 +1,542 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+	"github.com/teamyapchat/yapchat-server/internal/webhook"
+)
+
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+const (
+	// Per room, and per bot
+	MaxOutgoingWebhooks = 10
+	maxWebhookURLLength = 2048
+
+	webhookPollInterval = 5 * time.Second
+	webhookLockKey      = "outgoing_webhooks:lock"
+	// Only held while claiming deliveries, so it's released long before this
+	webhookLockTTL   = 30 * time.Second
+	webhookBatchSize = 100
+	webhookWorkers   = 4
+	// Claimed deliveries are due again after this if the node sending them
+	// dies first. Each is leased again just before it's sent, so this only
+	// needs to cover one attempt.
+	webhookAttemptLease = time.Minute
+
+	// Attempts are spaced out by 5s, 20s, 80s, ... up to 6h, for about 7.5
+	// hours in total before a delivery is given up on
+	webhookMaxAttempts    = 8
+	webhookRetryDelay     = 5 * time.Second
+	webhookMaxRetryDelay  = 6 * time.Hour
+	webhookDeliveryMaxAge = 7 * 24 * time.Hour
+	maxDeliveriesListed   = 100
+	maxDeliveryErrorLen   = 255
+)
+
+// OutgoingWebhookService sends room events to the URLs subscribed to them,
+// either by a room's moderators or by a bot's owner for every room the bot
+// is in. Deliveries are stored and sent by whichever node claims them, and
+// retried with exponential backoff until they're given up on and left in
+// the dead-letter list.
+type OutgoingWebhookService struct {
+	webhookRepo  *repositories.OutgoingWebhookRepository
+	chatroomRepo *repositories.ChatRoomRepository
+	botService   *BotService
+	sender       *webhook.Sender
+	rdb          *redis.Client
+	nodeID       string
+	wake         chan struct{}
+}
+
+func NewOutgoingWebhookService(
+	webhookRepo *repositories.OutgoingWebhookRepository,
+	chatroomRepo *repositories.ChatRoomRepository,
+	botService *BotService,
+	sender *webhook.Sender,
+	rdb *redis.Client,
+	nodeID string,
+) *OutgoingWebhookService {
+	return &OutgoingWebhookService{
+		webhookRepo:  webhookRepo,
+		chatroomRepo: chatroomRepo,
+		botService:   botService,
+		sender:       sender,
+		rdb:          rdb,
+		nodeID:       nodeID,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+func (s *OutgoingWebhookService) ListForRoom(
+	chatroomID uint,
+	userID string,
+) ([]models.OutgoingWebhook, error) {
+	if err := s.checkModerator(chatroomID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.webhookRepo.ListForRoom(chatroomID)
+}
+
+func (s *OutgoingWebhookService) ListForBot(
+	botID, userID string,
+) ([]models.OutgoingWebhook, error) {
+	if _, err := s.botService.getOwned(userID, botID); err != nil {
+		return nil, err
+	}
+
+	return s.webhookRepo.ListForBots([]string{botID})
+}
+
+func (s *OutgoingWebhookService) CreateForRoom(
+	chatroomID uint,
+	userID string,
+	request dtos.CreateOutgoingWebhookRequest,
+) (*models.OutgoingWebhook, error) {
+	if err := s.checkModerator(chatroomID, userID); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.webhookRepo.ListForRoom(chatroomID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.create(&models.OutgoingWebhook{
+		RoomID:    &chatroomID,
+		CreatorID: userID,
+	}, len(existing), request)
+}
+
+func (s *OutgoingWebhookService) CreateForBot(
+	botID, userID string,
+	request dtos.CreateOutgoingWebhookRequest,
+) (*models.OutgoingWebhook, error) {
+	if _, err := s.botService.getOwned(userID, botID); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.webhookRepo.ListForBots([]string{botID})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.create(&models.OutgoingWebhook{
+		BotID:     botID,
+		CreatorID: userID,
+	}, len(existing), request)
+}
+
+func (s *OutgoingWebhookService) Delete(webhookID uint, userID string) error {
+	if _, err := s.getManaged(webhookID, userID); err != nil {
+		return err
+	}
+
+	return s.webhookRepo.Delete(webhookID)
+}
+
+// ListDeliveries returns a webhook's latest deliveries, only those with the
+// given status if it isn't empty. The failed ones are its dead-letter list.
+func (s *OutgoingWebhookService) ListDeliveries(
+	webhookID uint,
+	userID, status string,
+) ([]models.WebhookDelivery, error) {
+	if status != "" && !slices.Contains([]models.DeliveryStatus{
+		models.DeliveryPending,
+		models.DeliverySucceeded,
+		models.DeliveryFailed,
+	}, models.DeliveryStatus(status)) {
+		return nil, fmt.Errorf("%w: unknown delivery status %q", ErrInvalidWebhook, status)
+	}
+
+	if _, err := s.getManaged(webhookID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.webhookRepo.ListDeliveries(
+		webhookID,
+		models.DeliveryStatus(status),
+		maxDeliveriesListed,
+	)
+}
+
+// RetryDelivery sends a delivery again straight away, with a fresh set of
+// attempts if it had been given up on.
+func (s *OutgoingWebhookService) RetryDelivery(
+	webhookID, deliveryID uint,
+	userID string,
+) (*models.WebhookDelivery, error) {
+	if _, err := s.getManaged(webhookID, userID); err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.webhookRepo.GetDelivery(deliveryID, webhookID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	if err := s.webhookRepo.UpdateDelivery(delivery); err != nil {
+		return nil, err
+	}
+
+	s.notify()
+	return delivery, nil
+}
+
+// Dispatch queues an event in a room for every webhook subscribed to it.
+// userID is who the event is about, whose own bot webhooks don't receive it.
+// It returns straight away, logging any failure.
+func (s *OutgoingWebhookService) Dispatch(
+	event models.WebhookEventType,
+	roomID uint,
+	userID string,
+	data any,
+) {
+	go func() {
+		if err := s.dispatch(event, roomID, userID, data); err != nil {
+			log.Error("Failed to queue webhook deliveries",
+				"event", event,
+				"roomID", roomID,
+				"err", err.Error())
+		}
+	}()
+}
+
+func (s *OutgoingWebhookService) dispatch(
+	event models.WebhookEventType,
+	roomID uint,
+	userID string,
+	data any,
+) error {
+	webhooks, err := s.webhookRepo.ListForRoom(roomID)
+	if err != nil {
+		return err
+	}
+
+	chatroom, err := s.chatroomRepo.GetByID(roomID)
+	if err != nil {
+		return err
+	}
+	var botIDs []string
+	for _, participant := range chatroom.Participants {
+		if participant.Bot && participant.ID != userID {
+			botIDs = append(botIDs, participant.ID)
+		}
+	}
+	if len(botIDs) > 0 {
+		botWebhooks, err := s.webhookRepo.ListForBots(botIDs)
+		if err != nil {
+			return err
+		}
+		webhooks = append(webhooks, botWebhooks...)
+	}
+
+	webhooks = slices.DeleteFunc(webhooks, func(target models.OutgoingWebhook) bool {
+		return !slices.Contains(target.Events, event)
+	})
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	payload, err := json.Marshal(dtos.WebhookEvent{
+		Type:      string(event),
+		Timestamp: now.Format(time.RFC3339),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	messageID := make([]byte, 12)
+	rand.Read(messageID)
+
+	deliveries := make([]models.WebhookDelivery, len(webhooks))
+	for i, target := range webhooks {
+		deliveries[i] = models.WebhookDelivery{
+			WebhookID:     target.ID,
+			MessageID:     "msg_" + hex.EncodeToString(messageID),
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+		}
+	}
+	if err := s.webhookRepo.CreateDeliveries(deliveries); err != nil {
+		return err
+	}
+
+	s.notify()
+	return nil
+}
+
+// Run sends due deliveries until ctx is cancelled, checking every
+// webhookPollInterval and whenever this node queues any.
+func (s *OutgoingWebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	pruneTicker := time.NewTicker(time.Hour)
+	defer pruneTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.deliverDue(ctx)
+		case <-s.wake:
+			s.deliverDue(ctx)
+		case <-pruneTicker.C:
+			err := s.webhookRepo.DeleteDeliveredBefore(time.Now().Add(-webhookDeliveryMaxAge))
+			if err != nil {
+				log.Error("Failed to prune webhook deliveries", "err", err.Error())
+			}
+		}
+	}
+}
+
+func (s *OutgoingWebhookService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDue claims a batch of due deliveries and sends them. Claiming is
+// done under a lock so that nodes don't send the same deliveries, and
+// claimed deliveries are leased for webhookAttemptLease, so they're only
+// picked up again if this node dies before recording the outcome.
+func (s *OutgoingWebhookService) deliverDue(ctx context.Context) {
+	acquired, err := s.rdb.SetNX(ctx, webhookLockKey, s.nodeID, webhookLockTTL).Result()
+	if err != nil {
+		log.Error("Failed to acquire webhook lock", "err", err.Error())
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	now := time.Now()
+	deliveries, err := s.webhookRepo.ListDueDeliveries(now, webhookBatchSize)
+	if err == nil && len(deliveries) > 0 {
+		ids := make([]uint, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		err = s.webhookRepo.LeaseDeliveries(ids, s.nodeID, now.Add(webhookAttemptLease))
+	}
+	releaseLock(ctx, s.rdb, webhookLockKey, s.nodeID)
+	if err != nil {
+		log.Error("Failed to claim webhook deliveries", "err", err.Error())
+		return
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, webhookWorkers)
+	for i := range deliveries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(delivery *models.WebhookDelivery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.deliver(ctx, delivery)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+}
+
+// deliver makes one attempt at a delivery and records how it went.
+func (s *OutgoingWebhookService) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	now := time.Now()
+	renewed, err := s.webhookRepo.RenewDeliveryLease(
+		delivery.ID,
+		s.nodeID,
+		now,
+		now.Add(webhookAttemptLease),
+	)
+	if err != nil {
+		log.Error("Failed to renew webhook delivery lease", "deliveryID", delivery.ID, "err", err.Error())
+		return
+	} else if !renewed {
+		// Waited too long behind the rest of the batch, and may have been
+		// claimed again
+		return
+	}
+
+	target, err := s.webhookRepo.GetByID(delivery.WebhookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Deleted along with its deliveries since they were claimed
+		return
+	} else if err != nil {
+		log.Error("Failed to get webhook", "webhookID", delivery.WebhookID, "err", err.Error())
+		return
+	}
+
+	err = s.sender.Send(ctx, target.URL, target.Secret, delivery.MessageID, []byte(delivery.Payload))
+	now = time.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+
+	if err == nil {
+		delivery.Status = models.DeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.LastStatusCode = 200
+	} else {
+		var statusErr *webhook.StatusError
+		if errors.As(err, &statusErr) {
+			delivery.LastStatusCode = statusErr.StatusCode
+		}
+		delivery.LastError = err.Error()
+		if len(delivery.LastError) > maxDeliveryErrorLen {
+			delivery.LastError = delivery.LastError[:maxDeliveryErrorLen]
+		}
+
+		if delivery.Attempts >= webhookMaxAttempts {
+			delivery.Status = models.DeliveryFailed
+			log.Warn("Giving up on webhook delivery",
+				"webhookID", target.ID,
+				"deliveryID", delivery.ID,
+				"err", delivery.LastError)
+		} else {
+			next := now.Add(retryDelay(delivery.Attempts))
+			delivery.NextAttemptAt = &next
+		}
+	}
+
+	if err := s.webhookRepo.UpdateDelivery(delivery); err != nil {
+		log.Error("Failed to save webhook delivery", "deliveryID", delivery.ID, "err", err.Error())
+	}
+}
+
+func (s *OutgoingWebhookService) create(
+	target *models.OutgoingWebhook,
+	existing int,
+	request dtos.CreateOutgoingWebhookRequest,
+) (*models.OutgoingWebhook, error) {
+	if err := validateWebhookURL(request.URL); err != nil {
+		return nil, err
+	}
+
+	if len(request.Events) == 0 {
+		return nil, fmt.Errorf("%w: subscribe to at least one event", ErrInvalidWebhook)
+	}
+	for _, event := range request.Events {
+		if !slices.Contains(models.WebhookEventTypes, models.WebhookEventType(event)) {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+		if !slices.Contains(target.Events, models.WebhookEventType(event)) {
+			target.Events = append(target.Events, models.WebhookEventType(event))
+		}
+	}
+
+	if existing >= MaxOutgoingWebhooks {
+		return nil, ErrWebhookLimitReached
+	}
+
+	target.URL = request.URL
+	target.Secret = webhook.NewSecret()
+	if err := s.webhookRepo.Create(target); err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// getManaged returns a webhook the user can manage, either as a moderator
+// of its room or as the owner of its bot.
+func (s *OutgoingWebhookService) getManaged(
+	webhookID uint,
+	userID string,
+) (*models.OutgoingWebhook, error) {
+	target, err := s.webhookRepo.GetByID(webhookID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+
+	if target.RoomID != nil {
+		err = s.checkModerator(*target.RoomID, userID)
+	} else if _, err = s.botService.getOwned(userID, target.BotID); errors.Is(err, ErrBotNotFound) {
+		err = ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return target, nil
+}
+
+func (s *OutgoingWebhookService) checkModerator(chatroomID uint, userID string) error {
+	chatroom, err := s.chatroomRepo.GetByID(chatroomID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrChatRoomNotFound
+		}
+		return err
+	}
+
+	if !slices.ContainsFunc(chatroom.Participants, func(user *models.User) bool {
+		return user.ID == userID
+	}) {
+		return ErrNotParticipant
+	}
+	if !chatroom.IsModerator(userID) {
+		return ErrNotModerator
+	}
+
+	return nil
+}
+
+// retryDelay returns how long to wait after a delivery's nth failed attempt.
+func retryDelay(attempts int) time.Duration {
+	delay := webhookRetryDelay
+	for range attempts - 1 {
+		delay *= 4
+		if delay >= webhookMaxRetryDelay {
+			return webhookMaxRetryDelay
+		}
+	}
+	return delay
+}
+
+// validateWebhookURL only allows https URLs. Where they point is checked
+// when they're dialled.
+func validateWebhookURL(rawURL string) error {
+	if len(rawURL) > maxWebhookURLLength {
+		return fmt.Errorf("%w: url is too long", ErrInvalidWebhook)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return fmt.Errorf("%w: url must be an https URL", ErrInvalidWebhook)
+	}
+
+	return nil
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+)
+
+var (
+	ErrInvalidStatus       = errors.New("invalid presence status")
+	ErrInvalidCustomStatus = errors.New("invalid custom status")
+)
+
+const (
+	maxCustomStatusText  = 128
+	maxCustomStatusEmoji = 32
+
+	// Users by when their custom status expires
+	customStatusExpiryKey      = "presence:custom_status_expiry"
+	customStatusExpiryInterval = 5 * time.Second
+)
+
+// expireCustomStatusScript clears a user's custom status if it's due to
+// expire, unless it has been replaced since. It's run by every node, and only
+// the one that clears the status gets 1 back.
+var expireCustomStatusScript = redis.NewScript(`
+local expiresAt = redis.call("ZSCORE", KEYS[2], ARGV[1])
+if not expiresAt or tonumber(expiresAt) > tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("ZREM", KEYS[2], ARGV[1])
+if tonumber(redis.call("HGET", KEYS[1], "custom_expires_at")) ~= tonumber(expiresAt) then
+	return 0
+end
+redis.call("HDEL", KEYS[1], "custom_text", "custom_emoji", "custom_expires_at")
+return 1
+`)
+
+type PresenceService struct {
+	rdb            *redis.Client
+	chatroomRepo   *repositories.ChatRoomRepository
+	eventService   *EventService
+	sessionService *SessionService
+}
+
+func NewPresenceService(
+	redisClient *redis.Client,
+	chatroomRepo *repositories.ChatRoomRepository,
+	eventService *EventService,
+	sessionService *SessionService,
+) *PresenceService {
+	return &PresenceService{
+		rdb:            redisClient,
+		chatroomRepo:   chatroomRepo,
+		eventService:   eventService,
+		sessionService: sessionService,
+	}
+}
+
+func presenceKey(userID string) string {
+	return "presence:" + userID
+}
+
+// Connect registers a new gateway session for the user, notifying everyone
+// sharing a room with them if they just came online, and returns the
+// session ID.
+func (s *PresenceService) Connect(userID string) (string, error) {
+	sessionID, err := s.sessionService.Register(userID)
+	if err != nil {
+		return "", err
+	}
+
+	counts, err := s.sessionService.CountLive(userID)
+	if err != nil {
+		log.Error("Failed to count live sessions", "userID", userID, "err", err.Error())
+	} else if counts[userID] == 1 {
+		s.broadcast(userID)
+	}
+
+	return sessionID, nil
+}
+
+// Disconnect removes a gateway session for the user, recording when they
+// were last seen once they have no sessions left anywhere in the cluster.
+func (s *PresenceService) Disconnect(userID, sessionID string) error {
+	if err := s.sessionService.Unregister(userID, sessionID); err != nil {
+		return err
+	}
+
+	s.markOffline(userID)
+	return nil
+}
+
+// Start drops the sessions a previous run of this node left behind and sends
+// its first heartbeat. It must finish before the gateway accepts
+// connections, or it would drop their sessions too.
+func (s *PresenceService) Start() {
+	userIDs, err := s.sessionService.Reset()
+	if err != nil {
+		log.Error("Failed to reset node sessions", "err", err.Error())
+	}
+	s.markOffline(userIDs...)
+
+	if err := s.sessionService.Heartbeat(); err != nil {
+		log.Error("Failed to send node heartbeat", "err", err.Error())
+	}
+}
+
+// Run keeps this node's session leases alive, periodically sweeps the
+// sessions of nodes that died without disconnecting their clients and clears
+// expired custom statuses. Start must have been called first.
+func (s *PresenceService) Run(ctx context.Context) {
+	heartbeat := time.NewTicker(sessionRefreshPeriod)
+	defer heartbeat.Stop()
+
+	sweep := time.NewTicker(sessionSweepInterval)
+	defer sweep.Stop()
+
+	expiry := time.NewTicker(customStatusExpiryInterval)
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if err := s.sessionService.Heartbeat(); err != nil {
+				log.Error("Failed to send node heartbeat", "err", err.Error())
+			}
+		case <-sweep.C:
+			userIDs, err := s.sessionService.SweepDeadNodes()
+			if err != nil {
+				log.Error("Failed to sweep dead nodes", "err", err.Error())
+			}
+			s.markOffline(userIDs...)
+		case <-expiry.C:
+			s.expireCustomStatuses(ctx)
+		}
+	}
+}
+
+// expireCustomStatuses clears the custom statuses that have expired and
+// tells everyone sharing a room with their users.
+func (s *PresenceService) expireCustomStatuses(ctx context.Context) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	userIDs, err := s.rdb.ZRangeByScore(ctx, customStatusExpiryKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: now,
+	}).Result()
+	if err != nil {
+		log.Error("Failed to list expired custom statuses", "err", err.Error())
+		return
+	}
+
+	for _, userID := range userIDs {
+		cleared, err := expireCustomStatusScript.Run(
+			ctx,
+			s.rdb,
+			[]string{presenceKey(userID), customStatusExpiryKey},
+			userID,
+			now,
+		).Int()
+		if err != nil {
+			log.Error("Failed to expire custom status", "userID", userID, "err", err.Error())
+			continue
+		}
+		if cleared == 1 {
+			s.broadcast(userID)
+		}
+	}
+}
+
+// Shutdown drops every session connected to this node so that other nodes
+// see its users go offline straight away.
+func (s *PresenceService) Shutdown() {
+	userIDs, err := s.sessionService.Reset()
+	if err != nil {
+		log.Error("Failed to remove node sessions", "err", err.Error())
+		return
+	}
+
+	s.markOffline(userIDs...)
+}
+
+// markOffline records the last-seen time of and broadcasts presence for each
+// of the given users that no longer has a live session.
+func (s *PresenceService) markOffline(userIDs ...string) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	counts, err := s.sessionService.CountLive(userIDs...)
+	if err != nil {
+		log.Error("Failed to count live sessions", "err", err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	for _, userID := range userIDs {
+		if counts[userID] > 0 {
+			continue
+		}
+
+		err := s.rdb.HSet(ctx, presenceKey(userID), "last_seen", time.Now().Unix()).Err()
+		if err != nil {
+			log.Error("Failed to record last seen", "userID", userID, "err", err.Error())
+		}
+
+		s.broadcast(userID)
+	}
+}
+
+func (s *PresenceService) SetStatus(userID string, status models.PresenceStatus) error {
+	switch status {
+	case models.StatusOnline, models.StatusIdle, models.StatusDoNotDisturb, models.StatusInvisible:
+	default:
+		return ErrInvalidStatus
+	}
+
+	err := s.rdb.HSet(context.Background(), presenceKey(userID), "status", string(status)).Err()
+	if err != nil {
+		return err
+	}
+
+	s.broadcast(userID)
+	return nil
+}
+
+// SetCustomStatus replaces the user's custom status, or clears it when
+// customStatus is nil.
+func (s *PresenceService) SetCustomStatus(userID string, customStatus *models.CustomStatus) error {
+	ctx := context.Background()
+
+	if customStatus == nil {
+		_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, presenceKey(userID), "custom_text", "custom_emoji", "custom_expires_at")
+			pipe.ZRem(ctx, customStatusExpiryKey, userID)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		s.broadcast(userID)
+		return nil
+	}
+
+	if utf8.RuneCountInString(customStatus.Text) > maxCustomStatusText ||
+		utf8.RuneCountInString(customStatus.Emoji) > maxCustomStatusEmoji ||
+		customStatus.Expired(time.Now()) {
+		return ErrInvalidCustomStatus
+	}
+
+	var expiresAt int64
+	if customStatus.ExpiresAt != nil {
+		expiresAt = customStatus.ExpiresAt.Unix()
+	}
+
+	// Statuses are also checked for expiry when read, but are tracked so the
+	// change can be broadcast when they do
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, presenceKey(userID),
+			"custom_text", customStatus.Text,
+			"custom_emoji", customStatus.Emoji,
+			"custom_expires_at", expiresAt,
+		)
+		if expiresAt > 0 {
+			pipe.ZAdd(ctx, customStatusExpiryKey, redis.Z{Score: float64(expiresAt), Member: userID})
+		} else {
+			pipe.ZRem(ctx, customStatusExpiryKey, userID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.broadcast(userID)
+	return nil
+}
+
+// Get returns the presence of a user as they see it themselves, so an
+// invisible user still sees that they are invisible.
+func (s *PresenceService) Get(userID string) (*models.Presence, error) {
+	presences, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return presences[userID], nil
+}
+
+// GetVisible returns the presence of each user as everybody else sees it.
+func (s *PresenceService) GetVisible(userIDs ...string) (map[string]*models.Presence, error) {
+	presences, err := s.load(userIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, presence := range presences {
+		if presence.Status == models.StatusInvisible {
+			presence.Status = models.StatusOffline
+		}
+		if presence.Status == models.StatusOffline {
+			presence.CustomStatus = nil
+		}
+	}
+
+	return presences, nil
+}
+
+func (s *PresenceService) load(userIDs ...string) (map[string]*models.Presence, error) {
+	ctx := context.Background()
+
+	counts, err := s.sessionService.CountLive(userIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]*redis.MapStringStringCmd, len(userIDs))
+	_, err = s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range userIDs {
+			fields[i] = pipe.HGetAll(ctx, presenceKey(id))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	now := time.Now()
+	presences := make(map[string]*models.Presence, len(userIDs))
+	for i, id := range userIDs {
+		presences[id] = parsePresence(id, fields[i].Val(), counts[id] > 0, now)
+	}
+
+	return presences, nil
+}
+
+func parsePresence(
+	userID string,
+	fields map[string]string,
+	connected bool,
+	now time.Time,
+) *models.Presence {
+	presence := &models.Presence{
+		UserID: userID,
+		Status: models.StatusOffline,
+	}
+
+	if lastSeen, err := strconv.ParseInt(fields["last_seen"], 10, 64); err == nil {
+		presence.LastSeen = time.Unix(lastSeen, 0)
+	}
+
+	if connected {
+		presence.Status = models.StatusOnline
+		if status := fields["status"]; status != "" {
+			presence.Status = models.PresenceStatus(status)
+		}
+	}
+
+	if fields["custom_text"] != "" || fields["custom_emoji"] != "" {
+		customStatus := &models.CustomStatus{
+			Text:  fields["custom_text"],
+			Emoji: fields["custom_emoji"],
+		}
+		if expiresAt, err := strconv.ParseInt(fields["custom_expires_at"], 10, 64); err == nil &&
+			expiresAt > 0 {
+			t := time.Unix(expiresAt, 0)
+			customStatus.ExpiresAt = &t
+		}
+
+		if !customStatus.Expired(now) {
+			presence.CustomStatus = customStatus
+		}
+	}
+
+	return presence
+}
+
+// broadcast pushes the user's visible presence to everyone they share a room
+// with.
+func (s *PresenceService) broadcast(userID string) {
+	recipientIDs, err := s.chatroomRepo.ListCoParticipantIDs(userID)
+	if err != nil {
+		log.Error("Failed to find presence recipients", "userID", userID, "err", err.Error())
+		return
+	}
+
+	presences, err := s.GetVisible(userID)
+	if err != nil {
+		log.Error("Failed to load presence", "userID", userID, "err", err.Error())
+		return
+	}
+
+	s.eventService.PublishToUsers(
+		dtos.PresenceUpdateEvent,
+		dtos.NewPresenceResponse(presences[userID]),
+		recipientIDs,
+	)
+}
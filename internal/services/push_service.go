@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/nats-io/nats.go"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+	"github.com/teamyapchat/yapchat-server/internal/webpush"
+)
+
+var (
+	ErrPushDisabled            = errors.New("push notifications are disabled")
+	ErrInvalidPushSubscription = webpush.ErrInvalidSubscription
+)
+
+const (
+	pushWorkers   = 4
+	pushQueueSize = 256
+	// Only one node sends the push notifications for each message
+	pushQueueGroup = "push_notifications"
+
+	pushTTL         = 24 * time.Hour
+	pushMaxAttempts = 3
+	pushRetryDelay  = 2 * time.Second
+
+	maxPushEndpointLength = 768
+)
+
+type pushJob struct {
+	subscription models.PushSubscription
+	payload      []byte
+	topic        string
+}
+
+// PushService sends Web Push notifications for new messages to users who
+// aren't connected anywhere, as their notification settings allow.
+type PushService struct {
+	nc                  *nats.Conn
+	sender              *webpush.Sender
+	subscriptionRepo    *repositories.PushSubscriptionRepository
+	userRepo            repositories.UserRepository
+	chatroomRepo        *repositories.ChatRoomRepository
+	sessionService      *SessionService
+	notificationService *NotificationService
+	queue               chan pushJob
+}
+
+// NewPushService creates the service. sender may be nil, which disables
+// push notifications.
+func NewPushService(
+	nc *nats.Conn,
+	sender *webpush.Sender,
+	subscriptionRepo *repositories.PushSubscriptionRepository,
+	userRepo repositories.UserRepository,
+	chatroomRepo *repositories.ChatRoomRepository,
+	sessionService *SessionService,
+	notificationService *NotificationService,
+) *PushService {
+	return &PushService{
+		nc:                  nc,
+		sender:              sender,
+		subscriptionRepo:    subscriptionRepo,
+		userRepo:            userRepo,
+		chatroomRepo:        chatroomRepo,
+		sessionService:      sessionService,
+		notificationService: notificationService,
+		queue:               make(chan pushJob, pushQueueSize),
+	}
+}
+
+// VAPIDPublicKey returns the key clients pass as applicationServerKey when
+// subscribing.
+func (s *PushService) VAPIDPublicKey() (string, error) {
+	if s.sender == nil {
+		return "", ErrPushDisabled
+	}
+	return s.sender.PublicKey(), nil
+}
+
+func (s *PushService) Subscribe(userID string, data dtos.PushSubscriptionRequest) error {
+	if s.sender == nil {
+		return ErrPushDisabled
+	}
+
+	subscription := webpush.Subscription{
+		Endpoint: data.Endpoint,
+		P256dh:   data.Keys.P256dh,
+		Auth:     data.Keys.Auth,
+	}
+	if len(data.Endpoint) > maxPushEndpointLength {
+		return fmt.Errorf("%w: invalid endpoint", ErrInvalidPushSubscription)
+	}
+	if err := s.sender.Validate(subscription); errors.Is(err, webpush.ErrForbiddenEndpoint) {
+		return fmt.Errorf("%w: endpoint must use https", ErrInvalidPushSubscription)
+	} else if err != nil {
+		return err
+	}
+
+	return s.subscriptionRepo.Save(&models.PushSubscription{
+		UserID:   userID,
+		Endpoint: data.Endpoint,
+		P256dh:   data.Keys.P256dh,
+		Auth:     data.Keys.Auth,
+	})
+}
+
+func (s *PushService) Unsubscribe(userID, endpoint string) error {
+	return s.subscriptionRepo.Delete(userID, endpoint)
+}
+
+// Run sends push notifications for new messages until ctx is cancelled. It
+// does nothing when push notifications are disabled.
+func (s *PushService) Run(ctx context.Context) {
+	if s.sender == nil {
+		return
+	}
+
+	sub, err := s.nc.QueueSubscribe(ChatMessagesSubject, pushQueueGroup, func(m *nats.Msg) {
+		var msg dtos.MessageResponse
+		if err := json.Unmarshal(m.Data, &msg); err != nil {
+			log.Error("Error unmarshaling NATS message", "err", err.Error())
+			return
+		}
+
+		if err := s.enqueue(msg); err != nil {
+			log.Error("Failed to queue push notifications", "messageID", msg.ID, "err", err.Error())
+		}
+	})
+	if err != nil {
+		log.Error("Error subscribing to NATS subject", "err", err.Error())
+		return
+	}
+
+	for range pushWorkers {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.queue:
+					s.deliver(ctx, job)
+				}
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	sub.Unsubscribe()
+}
+
+// enqueue queues a notification for each subscription of the offline
+// participants who should be notified of the message. System messages never
+// notify anyone.
+func (s *PushService) enqueue(msg dtos.MessageResponse) error {
+	if msg.Type == string(models.SystemMessage) {
+		return nil
+	}
+
+	chatroom, err := s.chatroomRepo.GetByID(msg.RoomID)
+	if err != nil {
+		return err
+	}
+
+	// Webhook messages are sent on behalf of their creator, who should still
+	// be notified of them
+	recipientIDs := make([]string, 0, len(chatroom.Participants))
+	for _, participant := range chatroom.Participants {
+		if participant.ID != msg.SenderID || msg.WebhookID != 0 {
+			recipientIDs = append(recipientIDs, participant.ID)
+		}
+	}
+	if len(recipientIDs) == 0 {
+		return nil
+	}
+
+	sessions, err := s.sessionService.CountLive(recipientIDs...)
+	if err != nil {
+		return err
+	}
+
+	offlineIDs := make([]string, 0, len(recipientIDs))
+	for _, id := range recipientIDs {
+		if sessions[id] == 0 {
+			offlineIDs = append(offlineIDs, id)
+		}
+	}
+
+	notifiedIDs, err := s.notificationService.FilterNotified(
+		msg.RoomID,
+		offlineIDs,
+		func(userID string) bool {
+			return mentionsOffline(msg.Entities, userID)
+		},
+	)
+	if err != nil || len(notifiedIDs) == 0 {
+		return err
+	}
+
+	subscriptions, err := s.subscriptionRepo.ListForUsers(notifiedIDs)
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+
+	senderName := msg.Username
+	if msg.WebhookID == 0 {
+		sender, err := s.userRepo.FindByID(msg.SenderID)
+		if err != nil {
+			return err
+		}
+		senderName = sender.Name()
+	}
+	payload, err := json.Marshal(dtos.PushNotification{
+		Type:       "message",
+		RoomID:     msg.RoomID,
+		RoomName:   chatroom.Name,
+		MessageID:  msg.ID,
+		SenderID:   msg.SenderID,
+		SenderName: senderName,
+		Body:       previewContent(msg.Content),
+		Timestamp:  msg.Timestamp,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		job := pushJob{
+			subscription: subscription,
+			payload:      payload,
+			// A device that was off only gets the latest message per room
+			topic: fmt.Sprintf("room-%d", msg.RoomID),
+		}
+
+		select {
+		case s.queue <- job:
+		default:
+			log.Warn("Push queue is full, skipping notification", "messageID", msg.ID)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// deliver sends a notification, retrying when the push service is
+// unavailable and deleting the subscription once it's gone, or if its
+// endpoint isn't allowed.
+func (s *PushService) deliver(ctx context.Context, job pushJob) {
+	subscription := webpush.Subscription{
+		Endpoint: job.subscription.Endpoint,
+		P256dh:   job.subscription.P256dh,
+		Auth:     job.subscription.Auth,
+	}
+	opts := webpush.Options{
+		TTL:     pushTTL,
+		Urgency: webpush.UrgencyNormal,
+		Topic:   job.topic,
+	}
+
+	delay := pushRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := s.sender.Send(ctx, subscription, job.payload, opts)
+		if err == nil {
+			return
+		}
+
+		// Endpoints resolving to forbidden addresses are dropped rather than
+		// retried, since they'll keep resolving there
+		if errors.Is(err, webpush.ErrSubscriptionGone) ||
+			errors.Is(err, webpush.ErrInvalidSubscription) ||
+			errors.Is(err, webpush.ErrForbiddenEndpoint) {
+			if err := s.subscriptionRepo.DeleteByEndpoint(subscription.Endpoint); err != nil {
+				log.Error("Failed to delete push subscription", "err", err.Error())
+			}
+			return
+		}
+
+		var statusErr *webpush.StatusError
+		retryable := !errors.As(err, &statusErr) || statusErr.Temporary()
+		if !retryable || attempt == pushMaxAttempts || ctx.Err() != nil {
+			log.Error(
+				"Failed to send push notification",
+				"userID",
+				job.subscription.UserID,
+				"attempts",
+				attempt,
+				"err",
+				err.Error(),
+			)
+			return
+		}
+
+		if statusErr != nil && statusErr.RetryAfter > delay {
+			delay = statusErr.RetryAfter
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// mentionsOffline reports whether a message's entities mention an offline
+// user, which @here doesn't.
+func mentionsOffline(entities []dtos.EntityResponse, userID string) bool {
+	for _, entity := range entities {
+		switch models.EntityType(entity.Type) {
+		case models.EveryoneMentionEntity:
+			return true
+		case models.UserMentionEntity:
+			if entity.UserID == userID {
+				return true
+			}
+		}
+	}
+	return false
+}
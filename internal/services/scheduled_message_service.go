@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+)
+
+var (
+	ErrScheduledMessageNotFound = errors.New("scheduled message not found")
+	ErrScheduledMessageSending  = errors.New("scheduled message is being sent")
+	ErrInvalidSchedule          = errors.New("invalid schedule")
+	ErrScheduleLimitReached     = errors.New("scheduled message limit reached")
+)
+
+const (
+	MaxScheduledMessagesPerUser = 100
+	maxScheduleAhead            = 365 * 24 * time.Hour
+
+	scheduledPollInterval = 5 * time.Second
+	scheduledLockKey      = "scheduled_messages:lock"
+	// Only held while claiming messages, so it's released long before this
+	scheduledLockTTL   = 30 * time.Second
+	scheduledBatchSize = 100
+	// Claimed messages are due again after this if the node sending them
+	// dies first. Each is leased again just before it's sent, so this only
+	// needs to cover sending one.
+	scheduledLease = time.Minute
+)
+
+// ScheduledMessageService holds messages users have written to be sent later
+// and sends them when they're due, the same way as messages sent over the
+// gateway. Whichever node claims a due message sends it.
+type ScheduledMessageService struct {
+	scheduledRepo   *repositories.ScheduledMessageRepository
+	chatroomService *ChatRoomService
+	messageService  *MessageService
+	eventService    *EventService
+	rdb             *redis.Client
+	nodeID          string
+}
+
+func NewScheduledMessageService(
+	scheduledRepo *repositories.ScheduledMessageRepository,
+	chatroomService *ChatRoomService,
+	messageService *MessageService,
+	eventService *EventService,
+	rdb *redis.Client,
+	nodeID string,
+) *ScheduledMessageService {
+	return &ScheduledMessageService{
+		scheduledRepo:   scheduledRepo,
+		chatroomService: chatroomService,
+		messageService:  messageService,
+		eventService:    eventService,
+		rdb:             rdb,
+		nodeID:          nodeID,
+	}
+}
+
+func (s *ScheduledMessageService) List(
+	chatroomID uint,
+	userID string,
+) ([]models.ScheduledMessage, error) {
+	if _, err := s.chatroomService.getAsParticipant(chatroomID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.scheduledRepo.ListForSender(userID, chatroomID)
+}
+
+func (s *ScheduledMessageService) Schedule(
+	chatroomID uint,
+	userID string,
+	request dtos.ScheduleMessageRequest,
+) (*models.ScheduledMessage, error) {
+	if _, err := s.chatroomService.getAsParticipant(chatroomID, userID); err != nil {
+		return nil, err
+	}
+
+	sendAt, err := parseSendAt(request.SendAt)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.format(request.Content, userID, chatroomID)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.scheduledRepo.CountForSender(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxScheduledMessagesPerUser {
+		return nil, ErrScheduleLimitReached
+	}
+
+	message := &models.ScheduledMessage{
+		RoomID:   chatroomID,
+		SenderID: userID,
+		Content:  content,
+		SendAt:   sendAt,
+		Status:   models.ScheduledPending,
+	}
+	if err := s.scheduledRepo.Create(message); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// Update edits a scheduled message. Messages that failed to send are
+// scheduled again, so they can be retried by editing nothing.
+func (s *ScheduledMessageService) Update(
+	chatroomID, messageID uint,
+	userID string,
+	request dtos.UpdateScheduledMessageRequest,
+) (*models.ScheduledMessage, error) {
+	message, err := s.getOwned(chatroomID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.SendAt != nil {
+		if message.SendAt, err = parseSendAt(*request.SendAt); err != nil {
+			return nil, err
+		}
+	} else if message.Status == models.ScheduledFailed && message.SendAt.Before(time.Now()) {
+		message.SendAt = time.Now()
+	}
+	if request.Content != nil {
+		if message.Content, err = s.format(*request.Content, userID, chatroomID); err != nil {
+			return nil, err
+		}
+	}
+	message.Status = models.ScheduledPending
+	message.LastError = ""
+
+	updated, err := s.scheduledRepo.UpdateIfIdle(message, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrScheduledMessageSending
+	}
+
+	return message, nil
+}
+
+// Cancel deletes a scheduled message, unless it's already being sent.
+func (s *ScheduledMessageService) Cancel(chatroomID, messageID uint, userID string) error {
+	if _, err := s.getOwned(chatroomID, messageID, userID); err != nil {
+		return err
+	}
+
+	deleted, err := s.scheduledRepo.DeleteIfIdle(messageID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrScheduledMessageSending
+	}
+	return nil
+}
+
+// Run sends due messages every scheduledPollInterval until ctx is cancelled.
+func (s *ScheduledMessageService) Run(ctx context.Context) {
+	ticker := time.NewTicker(scheduledPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sendDue(ctx)
+		}
+	}
+}
+
+// sendDue claims a batch of due messages and sends them. Claiming is done
+// under a lock so that nodes don't send the same messages, and claimed
+// messages are leased for scheduledLease, so they're only picked up again if
+// this node dies before it's done with them. Messages whose lease runs out
+// while earlier ones are being sent are left to be claimed again.
+func (s *ScheduledMessageService) sendDue(ctx context.Context) {
+	acquired, err := s.rdb.SetNX(ctx, scheduledLockKey, s.nodeID, scheduledLockTTL).Result()
+	if err != nil {
+		log.Error("Failed to acquire scheduled message lock", "err", err.Error())
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	now := time.Now()
+	messages, err := s.scheduledRepo.ListDue(now, scheduledBatchSize)
+	if err == nil && len(messages) > 0 {
+		ids := make([]uint, len(messages))
+		for i, message := range messages {
+			ids[i] = message.ID
+		}
+		err = s.scheduledRepo.Lease(ids, s.nodeID, now.Add(scheduledLease))
+	}
+	releaseLock(ctx, s.rdb, scheduledLockKey, s.nodeID)
+	if err != nil {
+		log.Error("Failed to claim scheduled messages", "err", err.Error())
+		return
+	}
+
+	for i := range messages {
+		s.send(&messages[i])
+	}
+}
+
+// send sends a scheduled message to its room. Messages that can no longer be
+// sent are marked as failed, while other errors leave them to be retried
+// once their lease runs out.
+func (s *ScheduledMessageService) send(scheduled *models.ScheduledMessage) {
+	now := time.Now()
+	renewed, err := s.scheduledRepo.RenewLease(
+		scheduled.ID,
+		s.nodeID,
+		now,
+		now.Add(scheduledLease),
+	)
+	if err != nil {
+		log.Error("Failed to renew scheduled message lease", "id", scheduled.ID, "err", err.Error())
+		return
+	} else if !renewed {
+		return
+	}
+
+	_, err = s.chatroomService.getAsParticipant(scheduled.RoomID, scheduled.SenderID)
+	if errors.Is(err, ErrChatRoomNotFound) {
+		s.fail(scheduled, "the chat room no longer exists")
+		return
+	} else if errors.Is(err, ErrNotParticipant) {
+		s.fail(scheduled, "sender is no longer in the chat room")
+		return
+	} else if err != nil {
+		log.Error("Failed to get chat room", "chatroomID", scheduled.RoomID, "err", err.Error())
+		return
+	}
+
+	// Formatted again since who can be mentioned may have changed
+	content, entities, err := s.messageService.Format(
+		scheduled.Content,
+		scheduled.SenderID,
+		scheduled.RoomID,
+	)
+	if errors.Is(err, ErrInvalidMessage) {
+		s.fail(scheduled, err.Error())
+		return
+	} else if err != nil {
+		log.Error("Failed to format scheduled message", "id", scheduled.ID, "err", err.Error())
+		return
+	}
+
+	message := &models.Message{
+		SenderID:  scheduled.SenderID,
+		RoomID:    scheduled.RoomID,
+		Type:      models.UserMessage,
+		Content:   content,
+		Entities:  entities,
+		Timestamp: time.Now(),
+	}
+	if err := s.messageService.CreateMessage(message); err != nil {
+		log.Error("Failed to persist scheduled message", "id", scheduled.ID, "err", err.Error())
+		return
+	}
+	s.eventService.PublishMessage(dtos.NewMessageResponse(message))
+
+	// If this fails the message is sent again once its lease runs out, which
+	// is better than not at all
+	if err := s.scheduledRepo.Delete(scheduled.ID); err != nil {
+		log.Error("Failed to delete scheduled message", "id", scheduled.ID, "err", err.Error())
+	}
+}
+
+func (s *ScheduledMessageService) fail(scheduled *models.ScheduledMessage, reason string) {
+	if err := s.scheduledRepo.MarkFailed(scheduled.ID, reason); err != nil {
+		log.Error("Failed to mark scheduled message as failed", "id", scheduled.ID, "err", err.Error())
+	}
+}
+
+// format validates the content of a message being scheduled, returning it
+// normalised.
+func (s *ScheduledMessageService) format(content, userID string, chatroomID uint) (string, error) {
+	content, _, err := s.messageService.Format(content, userID, chatroomID)
+	if err != nil {
+		return "", err
+	}
+	if content == "" {
+		return "", fmt.Errorf("%w: message is empty", ErrInvalidMessage)
+	}
+
+	return content, nil
+}
+
+func (s *ScheduledMessageService) getOwned(
+	chatroomID, messageID uint,
+	userID string,
+) (*models.ScheduledMessage, error) {
+	if _, err := s.chatroomService.getAsParticipant(chatroomID, userID); err != nil {
+		return nil, err
+	}
+
+	message, err := s.scheduledRepo.GetByID(messageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScheduledMessageNotFound
+		}
+		return nil, err
+	}
+	if message.RoomID != chatroomID || message.SenderID != userID {
+		return nil, ErrScheduledMessageNotFound
+	}
+
+	return message, nil
+}
+
+func parseSendAt(sendAtStr string) (time.Time, error) {
+	sendAt, err := time.Parse(time.RFC3339, sendAtStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: send at must be an RFC 3339 time", ErrInvalidSchedule)
+	}
+
+	now := time.Now()
+	if !sendAt.After(now) {
+		return time.Time{}, fmt.Errorf("%w: send at must be in the future", ErrInvalidSchedule)
+	}
+	if sendAt.After(now.Add(maxScheduleAhead)) {
+		return time.Time{}, fmt.Errorf(
+			"%w: messages can be scheduled at most a year ahead",
+			ErrInvalidSchedule,
+		)
+	}
+
+	return sendAt, nil
+}
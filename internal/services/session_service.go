@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Sessions and node heartbeats expire unless refreshed, so a node that
+	// crashes stops counting its users as online once the lease runs out.
+	sessionLeaseTTL       = 45 * time.Second
+	sessionRefreshPeriod  = 15 * time.Second
+	sessionSweepInterval  = 30 * time.Second
+	sessionSweeperLockKey = "sessions:sweeper"
+	nodesKey              = "nodes"
+)
+
+// SessionService tracks gateway sessions across every node in the cluster.
+type SessionService struct {
+	rdb    *redis.Client
+	nodeID string
+
+	mu    sync.Mutex
+	local map[string]string // session ID -> user ID
+}
+
+func NewSessionService(redisClient *redis.Client, nodeID string) *SessionService {
+	return &SessionService{
+		rdb:    redisClient,
+		nodeID: nodeID,
+		local:  make(map[string]string),
+	}
+}
+
+func sessionKey(sessionID string) string {
+	return "session:" + sessionID
+}
+
+func userSessionsKey(userID string) string {
+	return "user_sessions:" + userID
+}
+
+func nodeKey(nodeID string) string {
+	return "node:" + nodeID
+}
+
+func nodeSessionsKey(nodeID string) string {
+	return "node_sessions:" + nodeID
+}
+
+// Register creates a leased session for a user connected to this node and
+// returns its ID.
+func (s *SessionService) Register(userID string) (string, error) {
+	ctx := context.Background()
+	sessionID := generateULID()
+
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, sessionKey(sessionID),
+			"user_id", userID,
+			"node_id", s.nodeID,
+			"connected_at", time.Now().Unix(),
+		)
+		pipe.Expire(ctx, sessionKey(sessionID), sessionLeaseTTL)
+		pipe.SAdd(ctx, userSessionsKey(userID), sessionID)
+		pipe.HSet(ctx, nodeSessionsKey(s.nodeID), sessionID, userID)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.local[sessionID] = userID
+	s.mu.Unlock()
+
+	return sessionID, nil
+}
+
+func (s *SessionService) Unregister(userID, sessionID string) error {
+	ctx := context.Background()
+
+	s.mu.Lock()
+	delete(s.local, sessionID)
+	s.mu.Unlock()
+
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(sessionID))
+		pipe.SRem(ctx, userSessionsKey(userID), sessionID)
+		pipe.HDel(ctx, nodeSessionsKey(s.nodeID), sessionID)
+		return nil
+	})
+	return err
+}
+
+// CountLive returns the number of sessions with an unexpired lease that each
+// user has anywhere in the cluster. Sessions whose lease has lapsed are
+// pruned along the way.
+func (s *SessionService) CountLive(userIDs ...string) (map[string]int, error) {
+	ctx := context.Background()
+
+	members := make([]*redis.StringSliceCmd, len(userIDs))
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range userIDs {
+			members[i] = pipe.SMembers(ctx, userSessionsKey(id))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	type userSession struct {
+		userID    string
+		sessionID string
+		exists    *redis.IntCmd
+	}
+
+	var sessions []userSession
+	for i, id := range userIDs {
+		for _, sessionID := range members[i].Val() {
+			sessions = append(sessions, userSession{userID: id, sessionID: sessionID})
+		}
+	}
+
+	counts := make(map[string]int, len(userIDs))
+	if len(sessions) == 0 {
+		return counts, nil
+	}
+
+	_, err = s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := range sessions {
+			sessions[i].exists = pipe.Exists(ctx, sessionKey(sessions[i].sessionID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		if session.exists.Val() > 0 {
+			counts[session.userID]++
+			continue
+		}
+
+		s.rdb.SRem(ctx, userSessionsKey(session.userID), session.sessionID)
+	}
+
+	return counts, nil
+}
+
+// Heartbeat renews this node's lease and the leases of every session
+// connected to it. Sessions are written again rather than just extended, so
+// one whose lease lapsed while Redis was unreachable, and that was pruned
+// along with it, comes back.
+func (s *SessionService) Heartbeat() error {
+	ctx := context.Background()
+
+	// Held throughout so that a session unregistered meanwhile isn't
+	// written back after it's deleted
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ctx, nodesKey, s.nodeID)
+		pipe.Set(ctx, nodeKey(s.nodeID), time.Now().Unix(), sessionLeaseTTL)
+		for sessionID, userID := range s.local {
+			// connected_at is only kept if the session is still there
+			pipe.HSet(ctx, sessionKey(sessionID), "user_id", userID, "node_id", s.nodeID)
+			pipe.Expire(ctx, sessionKey(sessionID), sessionLeaseTTL)
+			pipe.SAdd(ctx, userSessionsKey(userID), sessionID)
+			pipe.HSet(ctx, nodeSessionsKey(s.nodeID), sessionID, userID)
+		}
+		return nil
+	})
+	return err
+}
+
+// SweepDeadNodes removes every session belonging to a node whose heartbeat
+// has lapsed and returns the IDs of the users those sessions belonged to.
+// Only one node sweeps at a time.
+func (s *SessionService) SweepDeadNodes() ([]string, error) {
+	ctx := context.Background()
+
+	acquired, err := s.rdb.SetNX(ctx, sessionSweeperLockKey, s.nodeID, sessionSweepInterval).
+		Result()
+	if err != nil || !acquired {
+		return nil, err
+	}
+
+	nodeIDs, err := s.rdb.SMembers(ctx, nodesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []string
+	for _, nodeID := range nodeIDs {
+		if nodeID == s.nodeID {
+			continue
+		}
+
+		alive, err := s.rdb.Exists(ctx, nodeKey(nodeID)).Result()
+		if err != nil {
+			return userIDs, err
+		}
+		if alive > 0 {
+			continue
+		}
+
+		log.Warn("Sweeping sessions of dead node", "nodeID", nodeID)
+
+		affected, err := s.removeNode(nodeID)
+		if err != nil {
+			return userIDs, err
+		}
+		userIDs = append(userIDs, affected...)
+	}
+
+	return userIDs, nil
+}
+
+// Reset removes every session recorded for this node, whether left over
+// from a previous process with the same node ID or still connected during
+// shutdown, and returns the IDs of the users they belonged to.
+func (s *SessionService) Reset() ([]string, error) {
+	s.mu.Lock()
+	s.local = make(map[string]string)
+	s.mu.Unlock()
+
+	return s.removeNode(s.nodeID)
+}
+
+func (s *SessionService) removeNode(nodeID string) ([]string, error) {
+	ctx := context.Background()
+
+	sessions, err := s.rdb.HGetAll(ctx, nodeSessionsKey(nodeID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(sessions))
+	_, err = s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for sessionID, userID := range sessions {
+			pipe.Del(ctx, sessionKey(sessionID))
+			pipe.SRem(ctx, userSessionsKey(userID), sessionID)
+			userIDs = append(userIDs, userID)
+		}
+		pipe.Del(ctx, nodeSessionsKey(nodeID), nodeKey(nodeID))
+		pipe.SRem(ctx, nodesKey, nodeID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
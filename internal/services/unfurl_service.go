@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/teamyapchat/yapchat-server/internal/dtos"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+	"github.com/teamyapchat/yapchat-server/internal/unfurl"
+)
+
+const (
+	unfurlWorkers   = 4
+	unfurlQueueSize = 256
+	unfurlTimeout   = 10 * time.Second
+
+	embedCacheTTL = 24 * time.Hour
+	// Failures are cached too, but not for as long, so a link that was down
+	// gets another chance
+	embedMissCacheTTL = time.Hour
+)
+
+type unfurlJob struct {
+	messageID uint
+	roomID    uint
+	urls      []string
+}
+
+// UnfurlService turns links in messages into embeds in the background and
+// tells the room once they're ready.
+type UnfurlService struct {
+	rdb          *redis.Client
+	messageRepo  *repositories.MessageRepository
+	eventService *EventService
+	fetcher      *unfurl.Fetcher
+	queue        chan unfurlJob
+}
+
+func NewUnfurlService(
+	rdb *redis.Client,
+	messageRepo *repositories.MessageRepository,
+	eventService *EventService,
+	fetcher *unfurl.Fetcher,
+) *UnfurlService {
+	return &UnfurlService{
+		rdb:          rdb,
+		messageRepo:  messageRepo,
+		eventService: eventService,
+		fetcher:      fetcher,
+		queue:        make(chan unfurlJob, unfurlQueueSize),
+	}
+}
+
+// Enqueue schedules the links in a persisted message to be unfurled. When
+// the queue is full the message is skipped rather than holding up the
+// sender.
+func (s *UnfurlService) Enqueue(message *models.Message) {
+	urls := unfurl.ExtractURLs(message.Content)
+	if len(urls) == 0 {
+		return
+	}
+
+	select {
+	case s.queue <- unfurlJob{messageID: message.ID, roomID: message.RoomID, urls: urls}:
+	default:
+		log.Warn("Unfurl queue is full, skipping message", "messageID", message.ID)
+	}
+}
+
+// Run processes queued messages until ctx is cancelled.
+func (s *UnfurlService) Run(ctx context.Context) {
+	for range unfurlWorkers {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.queue:
+					s.unfurl(ctx, job)
+				}
+			}
+		}()
+	}
+}
+
+func (s *UnfurlService) unfurl(ctx context.Context, job unfurlJob) {
+	var embeds []models.Embed
+	for _, url := range job.urls {
+		embed, err := s.getEmbed(ctx, url)
+		if err != nil {
+			log.Error("Failed to unfurl link", "url", url, "err", err.Error())
+			continue
+		}
+		if embed != nil {
+			embeds = append(embeds, *embed)
+		}
+	}
+
+	if len(embeds) == 0 {
+		return
+	}
+
+	if err := s.messageRepo.UpdateEmbeds(job.messageID, embeds); err != nil {
+		log.Error("Failed to save embeds", "messageID", job.messageID, "err", err.Error())
+		return
+	}
+
+	s.eventService.PublishToRoom(
+		dtos.MessageEmbedsUpdateEvent,
+		dtos.MessageEmbedsUpdateData{
+			MessageID: job.messageID,
+			RoomID:    job.roomID,
+			Embeds:    dtos.NewEmbedResponses(embeds),
+		},
+		job.roomID,
+	)
+}
+
+// getEmbed returns the embed for a URL from the cache, fetching it on a
+// miss. Links that can't be unfurled come back as nil without an error.
+func (s *UnfurlService) getEmbed(ctx context.Context, url string) (*models.Embed, error) {
+	key := embedCacheKey(url)
+
+	cached, err := s.rdb.Get(ctx, key).Bytes()
+	if err == nil {
+		if len(cached) == 0 {
+			return nil, nil
+		}
+
+		var embed models.Embed
+		if err := json.Unmarshal(cached, &embed); err != nil {
+			return nil, err
+		}
+		return &embed, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, unfurlTimeout)
+	defer cancel()
+
+	embed, err := s.fetcher.Fetch(fetchCtx, url)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.rdb.Set(ctx, key, "", embedMissCacheTTL)
+		}
+		if errors.Is(err, unfurl.ErrNotUnfurlable) || errors.Is(err, unfurl.ErrForbiddenURL) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	embedJSON, err := json.Marshal(embed)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.rdb.Set(ctx, key, embedJSON, embedCacheTTL).Err(); err != nil {
+		log.Error("Failed to cache embed", "url", url, "err", err.Error())
+	}
+
+	return embed, nil
+}
+
+func embedCacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return "embed:" + hex.EncodeToString(sum[:])
+}
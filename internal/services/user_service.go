@@ -1,17 +1,66 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+
 	"github.com/teamyapchat/yapchat-server/internal/dtos"
 	"github.com/teamyapchat/yapchat-server/internal/models"
 	"github.com/teamyapchat/yapchat-server/internal/repositories"
 )
 
+var ErrInvalidProfile = errors.New("invalid profile")
+
+const (
+	maxDisplayNameLength = 32
+	maxBioLength         = 190
+	maxPronounsLength    = 40
+	maxBannerURLLength   = 255
+)
+
+var accentColorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+var defaultNotificationLevels = []models.NotificationLevel{
+	models.NotifyAll,
+	models.NotifyMentions,
+	models.NotifyNone,
+}
+
+var digestFrequencies = []models.DigestFrequency{
+	models.DigestNever,
+	models.DigestDaily,
+	models.DigestWeekly,
+}
+
 type UserService struct {
-	userRepo repositories.UserRepository
+	userRepo     repositories.UserRepository
+	chatroomRepo *repositories.ChatRoomRepository
+	eventService *EventService
+	imageService *ImageService
 }
 
-func NewUserService(userRepo repositories.UserRepository) *UserService {
-	return &UserService{userRepo: userRepo}
+func NewUserService(
+	userRepo repositories.UserRepository,
+	chatroomRepo *repositories.ChatRoomRepository,
+	eventService *EventService,
+	imageService *ImageService,
+) *UserService {
+	return &UserService{
+		userRepo:     userRepo,
+		chatroomRepo: chatroomRepo,
+		eventService: eventService,
+		imageService: imageService,
+	}
 }
 
 func (s *UserService) Create(user *models.User) error {
@@ -36,18 +85,184 @@ func (s *UserService) Update(id string, data dtos.UpdateUserRequest) (*models.Us
 		user.Username = data.Username
 		err = s.userRepo.UpdateUsername(user)
 	}
-	if data.ImageURL != "" {
+	// An avatar uploaded here takes precedence over the Clerk profile image
+	if data.ImageURL != "" && user.AvatarKey == "" {
 		user.ImageURL = data.ImageURL
 		err = s.userRepo.UpdateImage(user)
 	}
-	if data.Status != "" {
-		user.IsOnline = data.Status == "online"
-		err = s.userRepo.UpdateStatus(user)
+	if data.Email != "" && data.Email != user.Email {
+		user.Email = data.Email
+		err = s.userRepo.UpdateEmail(user)
 	}
 
 	return user, err
 }
 
+// UpdateAvatar replaces the user's avatar with an uploaded image and notifies
+// everyone sharing a room with them.
+func (s *UserService) UpdateAvatar(
+	ctx context.Context,
+	id string,
+	r io.Reader,
+	size int64,
+) (*models.User, error) {
+	user, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key, url, err := s.imageService.Upload(ctx, "avatars/"+user.ID, r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	oldKey := user.AvatarKey
+	user.ImageURL = url
+	user.AvatarKey = key
+
+	if err := s.userRepo.UpdateAvatar(user); err != nil {
+		s.imageService.Delete(ctx, key)
+		return nil, err
+	}
+
+	s.imageService.Delete(ctx, oldKey)
+	s.broadcastProfile(user)
+	return user, nil
+}
+
+// UpdateProfile applies a self-service profile edit and notifies everyone
+// sharing a room with the user.
+func (s *UserService) UpdateProfile(
+	id string,
+	data dtos.UpdateProfileRequest,
+) (*models.User, error) {
+	user, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.DisplayName != nil {
+		displayName := strings.TrimSpace(*data.DisplayName)
+		if err := validateProfileText("display name", displayName, maxDisplayNameLength); err != nil {
+			return nil, err
+		}
+		user.DisplayName = displayName
+	}
+	if data.Bio != nil {
+		bio := strings.TrimSpace(*data.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			return nil, fmt.Errorf("%w: bio must be at most %d characters", ErrInvalidProfile, maxBioLength)
+		}
+		user.Bio = bio
+	}
+	if data.Pronouns != nil {
+		pronouns := strings.TrimSpace(*data.Pronouns)
+		if err := validateProfileText("pronouns", pronouns, maxPronounsLength); err != nil {
+			return nil, err
+		}
+		user.Pronouns = pronouns
+	}
+	if data.BannerURL != nil {
+		if err := validateImageURL(*data.BannerURL); err != nil {
+			return nil, err
+		}
+		user.BannerURL = *data.BannerURL
+	}
+	if data.AccentColor != nil {
+		if *data.AccentColor != "" && !accentColorRegex.MatchString(*data.AccentColor) {
+			return nil, fmt.Errorf("%w: accent color must be a hex color like #5865F2", ErrInvalidProfile)
+		}
+		user.AccentColor = strings.ToUpper(*data.AccentColor)
+	}
+	if data.Discoverable != nil {
+		user.Discoverable = *data.Discoverable
+	}
+	if data.DefaultNotifications != nil {
+		level := models.NotificationLevel(*data.DefaultNotifications)
+		if !slices.Contains(defaultNotificationLevels, level) {
+			return nil, fmt.Errorf(
+				"%w: default notifications must be all, mentions or none",
+				ErrInvalidProfile,
+			)
+		}
+		user.DefaultNotifications = level
+	}
+	if data.DigestFrequency != nil {
+		frequency := models.DigestFrequency(*data.DigestFrequency)
+		if !slices.Contains(digestFrequencies, frequency) {
+			return nil, fmt.Errorf(
+				"%w: digest frequency must be never, daily or weekly",
+				ErrInvalidProfile,
+			)
+		}
+		user.DigestFrequency = frequency
+	}
+
+	if err := s.userRepo.UpdateProfile(user); err != nil {
+		return nil, err
+	}
+
+	s.broadcastProfile(user)
+	return user, nil
+}
+
+// Search finds users matching query and ranks exact and prefix matches above
+// substring and fuzzy ones, boosting people who share a room with the caller.
+// It returns one page of results along with the total number of matches.
+func (s *UserService) Search(
+	query, callerID string,
+	limit, offset int,
+) ([]*models.User, int, error) {
+	return s.userRepo.Search(strings.TrimSpace(query), callerID, limit, offset)
+}
+
+func (s *UserService) broadcastProfile(user *models.User) {
+	recipientIDs, err := s.chatroomRepo.ListCoParticipantIDs(user.ID)
+	if err != nil {
+		log.Error("Failed to find profile update recipients", "userID", user.ID, "err", err.Error())
+		return
+	}
+
+	s.eventService.PublishToUsers(
+		dtos.ProfileUpdateEvent,
+		dtos.NewUserResponse(user, nil),
+		append(recipientIDs, user.ID),
+	)
+}
+
+// validateProfileText rejects single-line profile fields that are too long or
+// contain control characters.
+func validateProfileText(field, value string, maxLength int) error {
+	if utf8.RuneCountInString(value) > maxLength {
+		return fmt.Errorf("%w: %s must be at most %d characters", ErrInvalidProfile, field, maxLength)
+	}
+
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%w: %s must not contain control characters", ErrInvalidProfile, field)
+		}
+	}
+
+	return nil
+}
+
+func validateImageURL(rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+
+	if len(rawURL) > maxBannerURLLength {
+		return fmt.Errorf("%w: image URL is too long", ErrInvalidProfile)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%w: image URL must be an https URL", ErrInvalidProfile)
+	}
+
+	return nil
+}
+
 func (s *UserService) Delete(id string) error {
 	return s.userRepo.Delete(id)
 }
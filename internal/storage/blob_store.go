@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"time"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore stores uploaded files by key.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner is implemented by stores that can hand out their own expiring
+// download URLs, letting clients skip proxying the file through the server.
+type Presigner interface {
+	PresignGet(ctx context.Context, key, filename string, expiry time.Duration) (string, error)
+}
+
+// ContentDisposition builds a Content-Disposition header value that makes
+// browsers download the blob under its original filename.
+func ContentDisposition(filename string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+}
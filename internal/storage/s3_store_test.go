@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testBucket    = "yapchat-test"
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+// fakeS3 is a stand-in for MinIO that keeps objects in memory. It speaks
+// just enough of the S3 API for S3Store, with path-style bucket addressing,
+// and doesn't check signatures beyond the credential they're made with.
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]fakeObject
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		auth = r.URL.Query().Get("X-Amz-Credential")
+	}
+	if !strings.Contains(auth, testAccessKey+"/") {
+		writeS3Error(w, r, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	objects, ok := f.buckets[bucket]
+	if key == "" {
+		switch {
+		case r.Method == http.MethodPut:
+			f.buckets[bucket] = make(map[string]fakeObject)
+		case !ok:
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		}
+		return
+	}
+	if !ok {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type")}
+		w.Header().Set("ETag", etag(data))
+	case http.MethodHead, http.MethodGet:
+		object, ok := objects[key]
+		if !ok {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(object.data)))
+		w.Header().Set("ETag", etag(object.data))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if disposition := r.URL.Query().Get("response-content-disposition"); disposition != "" {
+			w.Header().Set("Content-Disposition", disposition)
+		}
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+	case http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		fmt.Fprintf(w, "<Error><Code>%s</Code><Resource>%s</Resource></Error>", code, r.URL.Path)
+	}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// newTestS3Store returns a store backed by a fake S3 server. The server uses
+// TLS, since over plain HTTP uploads are sent in signed chunks.
+func newTestS3Store(t *testing.T) (*S3Store, *httptest.Server) {
+	t.Helper()
+	fake := &fakeS3{buckets: make(map[string]map[string]fakeObject)}
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "https://"),
+		Region:    "us-east-1",
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		UseSSL:    true,
+		Transport: server.Client().Transport,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store() error = %v", err)
+	}
+	fake.mu.Lock()
+	_, ok := fake.buckets[testBucket]
+	fake.mu.Unlock()
+	if !ok {
+		t.Fatal("NewS3Store() didn't create the bucket")
+	}
+	return store, server
+}
+
+func TestS3StorePutGetDelete(t *testing.T) {
+	store, _ := newTestS3Store(t)
+	ctx := context.Background()
+	const key = "attachments/1/01JA2B3C4D5E6F7G8H9J0KMNPQ"
+	content := "hello, bucket"
+
+	err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain")
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	rc, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("reading blob: %v", err)
+	}
+	if string(data) != content {
+		t.Errorf("Get() = %q, want %q", data, content)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want %v", err, ErrBlobNotFound)
+	}
+}
+
+func TestS3StoreGetMissing(t *testing.T) {
+	store, _ := newTestS3Store(t)
+
+	if _, err := store.Get(context.Background(), "missing"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Get() error = %v, want %v", err, ErrBlobNotFound)
+	}
+}
+
+func TestS3StoreExistingBucket(t *testing.T) {
+	_, server := newTestS3Store(t)
+
+	// Connecting to the same server again finds the bucket already there
+	_, err := NewS3Store(S3Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "https://"),
+		Region:    "us-east-1",
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		UseSSL:    true,
+		Transport: server.Client().Transport,
+	})
+	if err != nil {
+		t.Errorf("NewS3Store() error = %v", err)
+	}
+}
+
+func TestS3StorePresignGet(t *testing.T) {
+	store, server := newTestS3Store(t)
+	ctx := context.Background()
+	const key = "attachments/1/report"
+	content := "quarterly numbers"
+
+	err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "application/pdf")
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	rawURL, err := store.PresignGet(ctx, key, "Q3 report.pdf", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGet() error = %v", err)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("PresignGet() returned an invalid URL %q: %v", rawURL, err)
+	}
+
+	if want := "/" + testBucket + "/" + key; u.Path != want {
+		t.Errorf("path = %q, want %q", u.Path, want)
+	}
+	query := u.Query()
+	for name, want := range map[string]string{
+		"X-Amz-Algorithm":              "AWS4-HMAC-SHA256",
+		"X-Amz-Expires":                "900",
+		"response-content-disposition": ContentDisposition("Q3 report.pdf"),
+	} {
+		if got := query.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if query.Get("X-Amz-Signature") == "" {
+		t.Error("URL isn't signed")
+	}
+
+	// The URL works without any other credentials
+	resp, err := server.Client().Get(rawURL)
+	if err != nil {
+		t.Fatalf("GET presigned URL: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(data) != content {
+		t.Errorf("GET presigned URL = %d %q, want 200 %q", resp.StatusCode, data, content)
+	}
+	if got := resp.Header.Get("Content-Disposition"); got != ContentDisposition("Q3 report.pdf") {
+		t.Errorf("Content-Disposition = %q, want the original filename", got)
+	}
+}
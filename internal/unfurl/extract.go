@@ -0,0 +1,43 @@
+package unfurl
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/teamyapchat/yapchat-server/internal/markdown"
+)
+
+// MaxURLsPerMessage caps how many links in a single message get unfurled
+const MaxURLsPerMessage = 5
+
+// Links wrapped in angle brackets, like <https://example.com>, are left
+// alone so that senders can opt out of an embed
+var urlRegex = regexp.MustCompile(`<?https?://[^\s<>]+>?`)
+
+// ExtractURLs returns the distinct links in a message that should be
+// unfurled, in the order they appear.
+func ExtractURLs(content string) []string {
+	var urls []string
+	for _, match := range urlRegex.FindAllString(content, -1) {
+		if strings.HasPrefix(match, "<") && strings.HasSuffix(match, ">") {
+			continue
+		}
+
+		rawURL := markdown.TrimTrailingPunctuation(strings.Trim(match, "<>"))
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+
+		if !slices.Contains(urls, rawURL) {
+			urls = append(urls, rawURL)
+		}
+		if len(urls) == MaxURLsPerMessage {
+			break
+		}
+	}
+
+	return urls
+}
@@ -0,0 +1,174 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+var (
+	ErrForbiddenURL     = errors.New("url not allowed")
+	ErrNotUnfurlable    = errors.New("nothing to unfurl")
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+const (
+	fetchTimeout = 8 * time.Second
+	dialTimeout  = 3 * time.Second
+	maxBodySize  = 1 << 20
+	maxRedirects = 3
+	userAgent    = "Mozilla/5.0 (compatible; YapChatBot/1.0; +https://yapchat.xyz)"
+)
+
+// Ranges that aren't covered by the net.IP helpers but still aren't
+// reachable on the public internet
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// Fetcher downloads pages to build embeds from. It refuses to connect to
+// private, loopback and other internal addresses, checking the address
+// actually dialled so DNS rebinding can't get around it.
+type Fetcher struct {
+	client *http.Client
+}
+
+func NewFetcher() *Fetcher {
+	return newFetcher(IsPublicAddress)
+}
+
+// newFetcher lets the address check be swapped out, since test servers
+// listen on loopback.
+func newFetcher(allowAddress func(netip.Addr) bool) *Fetcher {
+	dialer := &net.Dialer{
+		Timeout: dialTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !allowAddress(addrPort.Addr().Unmap()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenURL, address)
+			}
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		// Never go through a proxy, which would dial on our behalf
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   dialTimeout,
+		ResponseHeaderTimeout: fetchTimeout,
+		MaxIdleConns:          16,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	return &Fetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   fetchTimeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return ErrTooManyRedirects
+				}
+				return validateURL(req.URL)
+			},
+		},
+	}
+}
+
+// Fetch builds an embed for rawURL. It returns ErrNotUnfurlable when the
+// page is reachable but has nothing worth showing.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*models.Embed, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, ErrForbiddenURL
+	}
+	if err := validateURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,image/*;q=0.8")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrNotUnfurlable, resp.StatusCode)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch contentType {
+	case "text/html", "application/xhtml+xml":
+		embed, err := parseHTML(io.LimitReader(resp.Body, maxBodySize), resp.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+		embed.URL = rawURL
+		return embed, nil
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return &models.Embed{Type: models.ImageEmbed, URL: rawURL, ImageURL: rawURL}, nil
+	default:
+		return nil, ErrNotUnfurlable
+	}
+}
+
+// validateURL only allows plain http and https URLs. Where they point is
+// checked when dialling.
+func validateURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrForbiddenURL
+	}
+	if u.User != nil || u.Hostname() == "" {
+		return ErrForbiddenURL
+	}
+	return nil
+}
+
+// IsPublicAddress reports whether addr is reachable on the public internet,
+// for anything else that makes requests to user-supplied URLs.
+func IsPublicAddress(addr netip.Addr) bool {
+	if !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return false
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
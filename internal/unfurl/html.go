@@ -0,0 +1,147 @@
+package unfurl
+
+import (
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/teamyapchat/yapchat-server/internal/models"
+)
+
+const (
+	maxTitleLength       = 256
+	maxDescriptionLength = 1024
+	maxSiteNameLength    = 128
+)
+
+// parseHTML builds an embed from the metadata in a page's head, preferring
+// OpenGraph tags, then Twitter card tags, then plain HTML.
+func parseHTML(r io.Reader, pageURL *url.URL) (*models.Embed, error) {
+	meta := make(map[string]string)
+	var title string
+
+	tokenizer := html.NewTokenizer(r)
+	for done := false; !done; {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			// Either the end of the page or the size cap, and both leave us
+			// with whatever was read so far
+			done = true
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Meta:
+				key, content := metaAttributes(token)
+				if _, ok := meta[key]; key != "" && !ok {
+					meta[key] = content
+				}
+			case atom.Title:
+				if title == "" && tokenizer.Next() == html.TextToken {
+					title = string(tokenizer.Text())
+				}
+			case atom.Body:
+				done = true
+			}
+		case html.EndTagToken:
+			if tokenizer.Token().DataAtom == atom.Head {
+				done = true
+			}
+		}
+	}
+
+	title = firstOf(meta["og:title"], meta["twitter:title"], title)
+	description := firstOf(
+		meta["og:description"],
+		meta["twitter:description"],
+		meta["description"],
+	)
+
+	embed := &models.Embed{
+		Type:        models.LinkEmbed,
+		Title:       clean(title, maxTitleLength),
+		Description: clean(description, maxDescriptionLength),
+		SiteName:    clean(meta["og:site_name"], maxSiteNameLength),
+		ThemeColor:  themeColor(meta["theme-color"]),
+	}
+
+	imageURL := firstOf(
+		meta["og:image:secure_url"],
+		meta["og:image"],
+		meta["og:image:url"],
+		meta["twitter:image"],
+		meta["twitter:image:src"],
+	)
+	if imageURL != "" {
+		if u, err := pageURL.Parse(imageURL); err == nil && validateURL(u) == nil {
+			embed.ImageURL = u.String()
+			embed.ImageWidth, _ = strconv.Atoi(meta["og:image:width"])
+			embed.ImageHeight, _ = strconv.Atoi(meta["og:image:height"])
+		}
+	}
+
+	if embed.Title == "" && embed.Description == "" && embed.ImageURL == "" {
+		return nil, ErrNotUnfurlable
+	}
+
+	return embed, nil
+}
+
+// metaAttributes returns the key of a meta tag, from either its property or
+// name attribute, and its content.
+func metaAttributes(token html.Token) (string, string) {
+	var key, content string
+	for _, attr := range token.Attr {
+		switch attr.Key {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(attr.Val))
+			}
+		case "content":
+			content = attr.Val
+		}
+	}
+	return key, content
+}
+
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// clean collapses whitespace, drops control characters and truncates s to at
+// most maxLength characters.
+func clean(s string, maxLength int) string {
+	s = strings.Join(strings.FieldsFunc(s, unicode.IsSpace), " ")
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, s)
+
+	if utf8.RuneCountInString(s) > maxLength {
+		s = string([]rune(s)[:maxLength-1]) + "…"
+	}
+	return s
+}
+
+func themeColor(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) != 7 || value[0] != '#' {
+		return ""
+	}
+	if _, err := strconv.ParseUint(value[1:], 16, 32); err != nil {
+		return ""
+	}
+	return strings.ToUpper(value)
+}
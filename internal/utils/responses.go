@@ -33,3 +33,8 @@ type Pagination struct {
 	TotalPages int `json:"total_pages" validate:"required" example:"10"`
 	Data       any `json:"data"        validate:"required"`
 }
+
+type CursorPagination struct {
+	NextCursor string `json:"next_cursor,omitempty"                     example:"42"`
+	Data       any    `json:"data"                  validate:"required"`
+}
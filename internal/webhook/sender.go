@@ -0,0 +1,127 @@
+// Package webhook delivers signed webhook requests to user-supplied URLs. The
+// requests are signed the way Svix signs them, so receivers can verify them
+// with any Svix library, as the server itself does for Clerk's webhooks.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	svix "github.com/svix/svix-webhooks/go"
+)
+
+var ErrForbiddenURL = errors.New("webhook url not allowed")
+
+const (
+	sendTimeout     = 10 * time.Second
+	dialTimeout     = 3 * time.Second
+	maxResponseSize = 4 << 10
+	secretPrefix    = "whsec_"
+	userAgent       = "YapChat-Webhooks/1.0"
+)
+
+// StatusError is returned when the receiver responds with anything but a
+// 2xx status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook receiver responded with status %d", e.StatusCode)
+}
+
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender creates a sender. URLs are supplied by users, so allowAddress
+// decides which addresses they may resolve to.
+func NewSender(allowAddress func(netip.Addr) bool) *Sender {
+	dialer := &net.Dialer{
+		Timeout: dialTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !allowAddress(addrPort.Addr().Unmap()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenURL, address)
+			}
+			return nil
+		},
+	}
+
+	return &Sender{
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:               nil,
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: dialTimeout,
+				MaxIdleConnsPerHost: 4,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			Timeout: sendTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Send posts a JSON payload to url, signed with secret. messageID identifies
+// the event, and stays the same across retries so receivers can ignore
+// duplicates.
+func (s *Sender) Send(
+	ctx context.Context,
+	url, secret, messageID string,
+	payload []byte,
+) error {
+	signer, err := svix.NewWebhook(secret)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	signature, err := signer.Sign(messageID, now, payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("svix-id", messageID)
+	req.Header.Set("svix-timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set("svix-signature", signature)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// NewSecret returns a new signing secret in the whsec_ form Svix libraries
+// accept.
+func NewSecret() string {
+	key := make([]byte, 24)
+	rand.Read(key)
+	return secretPrefix + base64.StdEncoding.EncodeToString(key)
+}
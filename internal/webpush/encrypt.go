@@ -0,0 +1,103 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	recordSize     = 4096
+	authSecretSize = 16
+	saltSize       = 16
+	// Salt, record size, key ID length and the sender's public key as the key ID
+	headerSize = saltSize + 4 + 1 + 65
+	tagSize    = 16
+
+	// MaxPayloadSize is the most that fits in the single record push services
+	// accept, after the header, tag and padding delimiter
+	MaxPayloadSize = recordSize - headerSize - tagSize - 1
+)
+
+// encrypt encrypts a payload for a subscription using the aes128gcm content
+// encoding (RFC 8188), keyed by an ECDH exchange with the subscription's
+// p256dh key and mixed with its auth secret (RFC 8291).
+func encrypt(payload, p256dh, auth []byte) ([]byte, error) {
+	curve := ecdh.P256()
+	uaPublic, err := curve.NewPublicKey(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid p256dh key", ErrInvalidSubscription)
+	}
+
+	asPrivate, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := make([]byte, 0, 14+len(p256dh)+len(asPublic))
+	keyInfo = append(keyInfo, "WebPush: info\x00"...)
+	keyInfo = append(keyInfo, p256dh...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm, err := expand(hkdf.Extract(sha256.New, sharedSecret, auth), keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	cek, err := expand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := expand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// The whole payload is one record, so its padding delimiter marks it as
+	// the last
+	plaintext := make([]byte, 0, len(payload)+1)
+	plaintext = append(plaintext, payload...)
+	plaintext = append(plaintext, 2)
+
+	body := make([]byte, 0, headerSize+len(plaintext)+tagSize)
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, recordSize)
+	body = append(body, byte(len(asPublic)))
+	body = append(body, asPublic...)
+	return gcm.Seal(body, nonce, plaintext, nil), nil
+}
+
+func expand(prk, info []byte, length int) ([]byte, error) {
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, info), out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
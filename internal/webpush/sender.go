@@ -0,0 +1,250 @@
+// Package webpush sends Web Push messages (RFC 8030) to browsers, encrypting
+// payloads as RFC 8291 describes and identifying the server with VAPID
+// (RFC 8292).
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidKey          = errors.New("invalid VAPID private key")
+	ErrInvalidSubscription = errors.New("invalid push subscription")
+	ErrSubscriptionGone    = errors.New("push subscription expired or unsubscribed")
+	ErrPayloadTooLarge     = errors.New("push payload too large")
+	ErrForbiddenEndpoint   = errors.New("push endpoint not allowed")
+)
+
+const (
+	sendTimeout     = 10 * time.Second
+	dialTimeout     = 3 * time.Second
+	maxResponseSize = 4 << 10
+)
+
+type Urgency string
+
+const (
+	UrgencyVeryLow Urgency = "very-low"
+	UrgencyLow     Urgency = "low"
+	UrgencyNormal  Urgency = "normal"
+	UrgencyHigh    Urgency = "high"
+)
+
+// Subscription is what the browser's PushSubscription.toJSON() returns, with
+// P256dh and Auth still base64url encoded.
+type Subscription struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+}
+
+type Options struct {
+	// How long the push service keeps the message for an offline device
+	TTL     time.Duration
+	Urgency Urgency
+	// Replaces any undelivered message with the same topic
+	Topic string
+}
+
+// StatusError is returned when the push service rejects a message.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("push service responded with status %d", e.StatusCode)
+}
+
+// Temporary reports whether sending the message again later might work.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// EndpointPolicy decides which endpoints can be sent to. Endpoints are
+// supplied by users, so in production they should only reach public push
+// services over HTTPS.
+type EndpointPolicy struct {
+	// AllowAddress decides which addresses endpoints may resolve to
+	AllowAddress func(netip.Addr) bool
+	// AllowHTTP allows plain http: endpoints, like local fake push services
+	AllowHTTP bool
+}
+
+type Sender struct {
+	key       *ecdsa.PrivateKey
+	publicKey string
+	subject   string
+	allowHTTP bool
+	client    *http.Client
+}
+
+// NewSender creates a sender that signs with the VAPID private key, a
+// base64url encoded P-256 scalar like most Web Push libraries generate.
+// subject is a mailto: or https: URL push services can contact the operator
+// at.
+func NewSender(privateKey, subject string, policy EndpointPolicy) (*Sender, error) {
+	key, publicKey, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout: dialTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !policy.AllowAddress(addrPort.Addr().Unmap()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenEndpoint, address)
+			}
+			return nil
+		},
+	}
+
+	return &Sender{
+		key:       key,
+		publicKey: publicKey,
+		subject:   subject,
+		allowHTTP: policy.AllowHTTP,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:               nil,
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: dialTimeout,
+				MaxIdleConnsPerHost: 8,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			Timeout: sendTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// PublicKey returns the application server key browsers subscribe with.
+func (s *Sender) PublicKey() string {
+	return s.publicKey
+}
+
+// Send delivers an encrypted payload to a subscription. It returns
+// ErrSubscriptionGone when the subscription should be deleted,
+// ErrForbiddenEndpoint when the policy doesn't allow its endpoint, and a
+// *StatusError for any other rejection.
+func (s *Sender) Send(
+	ctx context.Context,
+	subscription Subscription,
+	payload []byte,
+	opts Options,
+) error {
+	if len(payload) > MaxPayloadSize {
+		return ErrPayloadTooLarge
+	}
+
+	endpoint, p256dh, auth, err := subscription.decode()
+	if err != nil {
+		return err
+	}
+	if err := s.checkScheme(endpoint); err != nil {
+		return err
+	}
+
+	body, err := encrypt(payload, p256dh, auth)
+	if err != nil {
+		return err
+	}
+
+	authorization, err := s.vapidAuthorization(endpoint, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		endpoint.String(),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(opts.TTL.Seconds())))
+	if opts.Urgency != "" {
+		req.Header.Set("Urgency", string(opts.Urgency))
+	}
+	if opts.Topic != "" {
+		req.Header.Set("Topic", opts.Topic)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrSubscriptionGone
+	}
+
+	statusErr := &StatusError{StatusCode: resp.StatusCode}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		statusErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return statusErr
+}
+
+// Validate checks that a subscription is well formed and that the policy
+// allows its endpoint's scheme. Where the endpoint resolves to is only
+// checked when sending.
+func (s *Sender) Validate(subscription Subscription) error {
+	endpoint, _, _, err := subscription.decode()
+	if err != nil {
+		return err
+	}
+	return s.checkScheme(endpoint)
+}
+
+func (s *Sender) checkScheme(endpoint *url.URL) error {
+	if endpoint.Scheme != "https" && !s.allowHTTP {
+		return fmt.Errorf("%w: %s", ErrForbiddenEndpoint, endpoint.Redacted())
+	}
+	return nil
+}
+
+func (s Subscription) decode() (*url.URL, []byte, []byte, error) {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil || endpoint.Host == "" || endpoint.User != nil ||
+		(endpoint.Scheme != "https" && endpoint.Scheme != "http") {
+		return nil, nil, nil, fmt.Errorf("%w: invalid endpoint", ErrInvalidSubscription)
+	}
+
+	p256dh, err := decodeBase64(s.P256dh)
+	if err != nil || len(p256dh) != 65 || p256dh[0] != 4 {
+		return nil, nil, nil, fmt.Errorf("%w: invalid p256dh key", ErrInvalidSubscription)
+	}
+
+	auth, err := decodeBase64(s.Auth)
+	if err != nil || len(auth) != authSecretSize {
+		return nil, nil, nil, fmt.Errorf("%w: invalid auth secret", ErrInvalidSubscription)
+	}
+
+	return endpoint, p256dh, auth, nil
+}
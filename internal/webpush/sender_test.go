@@ -0,0 +1,347 @@
+package webpush
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const testSubject = "mailto:push@example.com"
+
+var localPolicy = EndpointPolicy{
+	AllowAddress: func(netip.Addr) bool { return true },
+	AllowHTTP:    true,
+}
+
+// userAgent is the browser end of a subscription, holding the keys needed to
+// decrypt what it's sent.
+type userAgent struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newUserAgent(t *testing.T) *userAgent {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, authSecretSize)
+	rand.Read(auth)
+	return &userAgent{key: key, auth: auth}
+}
+
+func (ua *userAgent) subscription(endpoint string) Subscription {
+	return Subscription{
+		Endpoint: endpoint,
+		P256dh:   base64.RawURLEncoding.EncodeToString(ua.key.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(ua.auth),
+	}
+}
+
+// decrypt reverses the aes128gcm encoding of a single record message, as
+// RFC 8291 has the browser do.
+func (ua *userAgent) decrypt(t *testing.T, body []byte) []byte {
+	t.Helper()
+	if len(body) < headerSize+tagSize {
+		t.Fatalf("body is %d bytes, too short for a header", len(body))
+	}
+	salt := body[:saltSize]
+	if rs := binary.BigEndian.Uint32(body[saltSize:]); rs != recordSize {
+		t.Errorf("record size = %d, want %d", rs, recordSize)
+	}
+	keyIDLen := int(body[saltSize+4])
+	asPublicBytes := body[saltSize+5 : saltSize+5+keyIDLen]
+	ciphertext := body[saltSize+5+keyIDLen:]
+
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		t.Fatalf("key ID isn't a P-256 public key: %v", err)
+	}
+	sharedSecret, err := ua.key.ECDH(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), ua.key.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, asPublicBytes...)
+	ikm := readHKDF(t, hkdf.New(sha256.New, sharedSecret, ua.auth, keyInfo), 32)
+	cek := readHKDF(t, hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: aes128gcm\x00")), 16)
+	nonce := readHKDF(t, hkdf.New(sha256.New, ikm, salt, []byte("Content-Encoding: nonce\x00")), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+
+	// Strip the padding, which ends at the last record's delimiter
+	plaintext = []byte(strings.TrimRight(string(plaintext), "\x00"))
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 2 {
+		t.Fatal("plaintext doesn't end with the last record's delimiter")
+	}
+	return plaintext[:len(plaintext)-1]
+}
+
+func readHKDF(t *testing.T, r io.Reader, length int) []byte {
+	t.Helper()
+	out := make([]byte, length)
+	if _, err := io.ReadFull(r, out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func newTestSender(t *testing.T, policy EndpointPolicy) *Sender {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := NewSender(
+		base64.RawURLEncoding.EncodeToString(key.Bytes()),
+		testSubject,
+		policy,
+	)
+	if err != nil {
+		t.Fatalf("NewSender() error = %v", err)
+	}
+	return sender
+}
+
+// checkVAPID verifies the Authorization header's token against the key it
+// names, which must be the sender's.
+func checkVAPID(t *testing.T, header, publicKey, audience string) {
+	t.Helper()
+	token, key, ok := strings.Cut(strings.TrimPrefix(header, "vapid t="), ", k=")
+	if !ok || !strings.HasPrefix(header, "vapid t=") {
+		t.Fatalf("Authorization = %q, want a vapid token and key", header)
+	}
+	if key != publicKey {
+		t.Errorf("Authorization key = %q, want the sender's %q", key, publicKey)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token %q isn't a JWS", token)
+	}
+	keyBytes, _ := base64.RawURLEncoding.DecodeString(key)
+	x, y := elliptic.Unmarshal(elliptic.P256(), keyBytes)
+	if x == nil {
+		t.Fatalf("key %q isn't a P-256 point", key)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if len(signature) != 64 {
+		t.Fatalf("signature is %d bytes, want 64", len(signature))
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	ecdsaKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(ecdsaKey, digest[:], r, s) {
+		t.Fatal("token signature doesn't verify")
+	}
+
+	var claims vapidClaims
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Audience != audience || claims.Subject != testSubject {
+		t.Errorf("claims = %+v, want audience %q and subject %q", claims, audience, testSubject)
+	}
+	if exp := time.Unix(claims.ExpiresAt, 0); time.Until(exp) <= 0 || time.Until(exp) > 24*time.Hour {
+		t.Errorf("token expires at %v, want within the next day", exp)
+	}
+}
+
+func TestSendDecrypts(t *testing.T) {
+	type delivery struct {
+		header http.Header
+		body   []byte
+	}
+	deliveries := make(chan delivery, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveries <- delivery{header: r.Header, body: body}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	ua := newUserAgent(t)
+	sender := newTestSender(t, localPolicy)
+	payload := []byte(`{"type":"message","body":"hello"}`)
+
+	err := sender.Send(context.Background(), ua.subscription(server.URL+"/push/abc"), payload, Options{
+		TTL:     time.Hour,
+		Urgency: UrgencyHigh,
+		Topic:   "room-1",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	got := <-deliveries
+	if plaintext := ua.decrypt(t, got.body); string(plaintext) != string(payload) {
+		t.Errorf("decrypted payload = %q, want %q", plaintext, payload)
+	}
+	checkVAPID(t, got.header.Get("Authorization"), sender.PublicKey(), server.URL)
+
+	for name, want := range map[string]string{
+		"Content-Encoding": "aes128gcm",
+		"TTL":              "3600",
+		"Urgency":          "high",
+		"Topic":            "room-1",
+	} {
+		if value := got.header.Get(name); value != want {
+			t.Errorf("%s = %q, want %q", name, value, want)
+		}
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		check   func(t *testing.T, err error)
+	}{
+		{
+			name: "gone",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusGone)
+			},
+			check: func(t *testing.T, err error) {
+				if !errors.Is(err, ErrSubscriptionGone) {
+					t.Errorf("Send() error = %v, want %v", err, ErrSubscriptionGone)
+				}
+			},
+		},
+		{
+			name: "rate limited",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "30")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			check: func(t *testing.T, err error) {
+				var statusErr *StatusError
+				if !errors.As(err, &statusErr) || !statusErr.Temporary() ||
+					statusErr.RetryAfter != 30*time.Second {
+					t.Errorf("Send() error = %v, want a temporary status error retrying in 30s", err)
+				}
+			},
+		},
+		{
+			name: "rejected",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+			},
+			check: func(t *testing.T, err error) {
+				var statusErr *StatusError
+				if !errors.As(err, &statusErr) || statusErr.Temporary() {
+					t.Errorf("Send() error = %v, want a permanent status error", err)
+				}
+			},
+		},
+	}
+
+	ua := newUserAgent(t)
+	sender := newTestSender(t, localPolicy)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			subscription := ua.subscription(server.URL)
+			tt.check(t, sender.Send(context.Background(), subscription, []byte("hi"), Options{}))
+		})
+	}
+}
+
+func TestSendPayloadTooLarge(t *testing.T) {
+	sender := newTestSender(t, localPolicy)
+	subscription := newUserAgent(t).subscription("https://push.example.com/abc")
+
+	err := sender.Send(context.Background(), subscription, make([]byte, MaxPayloadSize+1), Options{})
+	if !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Send() error = %v, want %v", err, ErrPayloadTooLarge)
+	}
+}
+
+func TestEndpointPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the forbidden endpoint was requested")
+	}))
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the forbidden endpoint was requested")
+	}))
+	defer tlsServer.Close()
+
+	ua := newUserAgent(t)
+	sender := newTestSender(t, EndpointPolicy{AllowAddress: func(addr netip.Addr) bool {
+		return !addr.IsLoopback()
+	}})
+
+	if err := sender.Validate(ua.subscription(server.URL)); !errors.Is(err, ErrForbiddenEndpoint) {
+		t.Errorf("Validate(http) error = %v, want %v", err, ErrForbiddenEndpoint)
+	}
+	if err := sender.Validate(ua.subscription(tlsServer.URL)); err != nil {
+		t.Errorf("Validate(https) error = %v, want nil", err)
+	}
+
+	for _, endpoint := range []string{server.URL, tlsServer.URL} {
+		err := sender.Send(context.Background(), ua.subscription(endpoint), []byte("hi"), Options{})
+		if !errors.Is(err, ErrForbiddenEndpoint) {
+			t.Errorf("Send(%s) error = %v, want %v", endpoint, err, ErrForbiddenEndpoint)
+		}
+	}
+}
+
+func TestValidateSubscription(t *testing.T) {
+	sender := newTestSender(t, localPolicy)
+	valid := newUserAgent(t).subscription("https://push.example.com/abc")
+
+	tests := map[string]func(s *Subscription){
+		"bad endpoint":     func(s *Subscription) { s.Endpoint = "push.example.com/abc" },
+		"other scheme":     func(s *Subscription) { s.Endpoint = "ftp://push.example.com/abc" },
+		"credentials":      func(s *Subscription) { s.Endpoint = "https://a:b@push.example.com/abc" },
+		"short p256dh key": func(s *Subscription) { s.P256dh = s.P256dh[:20] },
+		"bad auth secret":  func(s *Subscription) { s.Auth = "not base64!" },
+	}
+
+	if err := sender.Validate(valid); err != nil {
+		t.Fatalf("Validate() error = %v, want nil", err)
+	}
+	for name, mutate := range tests {
+		subscription := valid
+		mutate(&subscription)
+		if err := sender.Validate(subscription); !errors.Is(err, ErrInvalidSubscription) {
+			t.Errorf("%s: Validate() error = %v, want %v", name, err, ErrInvalidSubscription)
+		}
+	}
+}
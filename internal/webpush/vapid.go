@@ -0,0 +1,88 @@
+package webpush
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Push services reject tokens that expire more than a day out
+const vapidTokenLifetime = 12 * time.Hour
+
+type vapidClaims struct {
+	Audience  string `json:"aud"`
+	ExpiresAt int64  `json:"exp"`
+	Subject   string `json:"sub,omitempty"`
+}
+
+var vapidHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+
+// vapidAuthorization builds the Authorization header that identifies the
+// server to the push service behind endpoint.
+func (s *Sender) vapidAuthorization(endpoint *url.URL, now time.Time) (string, error) {
+	claims, err := json.Marshal(vapidClaims{
+		Audience:  endpoint.Scheme + "://" + endpoint.Host,
+		ExpiresAt: now.Add(vapidTokenLifetime).Unix(),
+		Subject:   s.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := vapidHeader + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// JWS wants the raw 32 byte r and s rather than ASN.1
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, s.publicKey), nil
+}
+
+// parsePrivateKey decodes a VAPID private key, returning it along with the
+// base64url encoded public key.
+func parsePrivateKey(encoded string) (*ecdsa.PrivateKey, string, error) {
+	d, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, "", ErrInvalidKey
+	}
+
+	ecdhKey, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, "", ErrInvalidKey
+	}
+
+	// Uncompressed point: 0x04 || X || Y
+	publicKey := ecdhKey.PublicKey().Bytes()
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(publicKey[1:33]),
+			Y:     new(big.Int).SetBytes(publicKey[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}
+
+	return key, base64.RawURLEncoding.EncodeToString(publicKey), nil
+}
+
+// decodeBase64 accepts base64url with or without padding, which is how
+// browsers and key generators hand keys out.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
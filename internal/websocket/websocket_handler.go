@@ -2,8 +2,11 @@ package websocket
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"runtime/debug"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,35 +22,37 @@ import (
 )
 
 type WSHandler struct {
-	authService     *services.AuthService
-	chatroomService *services.ChatRoomService
-	messageService  *services.MessageService
-	userService     *services.UserService
-	clients         map[string]*websocket.Conn
-	nc              *nats.Conn
+	authService         *services.AuthService
+	chatroomService     *services.ChatRoomService
+	messageService      *services.MessageService
+	presenceService     *services.PresenceService
+	attachmentService   *services.AttachmentService
+	notificationService *services.NotificationService
+	commandService      *services.CommandService
+	clients             map[string]map[string]*websocket.Conn // user ID -> session ID -> conn
+	nc                  *nats.Conn
 }
 
 func NewWSHandler(
-	natsURL string,
+	nc *nats.Conn,
 	authService *services.AuthService,
 	chatroomService *services.ChatRoomService,
 	messageService *services.MessageService,
-	userService *services.UserService,
+	presenceService *services.PresenceService,
+	attachmentService *services.AttachmentService,
+	notificationService *services.NotificationService,
+	commandService *services.CommandService,
 ) *WSHandler {
-	nc, err := nats.Connect(natsURL)
-	if err != nil {
-		log.Fatal("Failed to connect to NATS", "err", err.Error())
-	}
-
-	log.Info("Connected to NATS")
-
 	return &WSHandler{
-		authService:     authService,
-		chatroomService: chatroomService,
-		messageService:  messageService,
-		userService:     userService,
-		clients:         make(map[string]*websocket.Conn),
-		nc:              nc,
+		authService:         authService,
+		chatroomService:     chatroomService,
+		messageService:      messageService,
+		presenceService:     presenceService,
+		attachmentService:   attachmentService,
+		notificationService: notificationService,
+		commandService:      commandService,
+		clients:             make(map[string]map[string]*websocket.Conn),
+		nc:                  nc,
 	}
 }
 
@@ -106,28 +111,54 @@ func (h *WSHandler) WebSocketHandler(c *gin.Context) {
 		return
 	}
 
-	usr, err := h.authService.VerifyToken(c, identifyData.Token)
+	// Bots identify with "Bot <token>", and need a scope to send messages
+	var userID string
+	canSend := true
+	if botToken, ok := strings.CutPrefix(identifyData.Token, "Bot "); ok {
+		token, err := h.authService.VerifyBotToken(botToken)
+		if err != nil {
+			conn.WriteJSON(gin.H{"error": err.Error()})
+			conn.Close()
+
+			return
+		}
+		if !token.HasScope(models.BotScopeMessagesRead) {
+			conn.WriteJSON(gin.H{"error": "bot token is missing the messages:read scope"})
+			conn.Close()
+
+			return
+		}
+		userID = token.BotID
+		canSend = token.HasScope(models.BotScopeMessagesWrite)
+	} else {
+		userID, err = h.authService.VerifyToken(c, identifyData.Token)
+		if err != nil {
+			conn.WriteJSON(gin.H{"error": err.Error()})
+			conn.Close()
+
+			return
+		}
+	}
+
+	sessionID, err := h.presenceService.Connect(userID)
 	if err != nil {
-		conn.WriteJSON(gin.H{"error": err.Error()})
+		conn.WriteJSON(gin.H{"error": "failed to create session"})
 		conn.Close()
 
+		log.Error("Failed to create session", "userID", userID, "err", err.Error())
 		return
 	}
 
-	userID := usr.ID
-
 	defer func() {
 		conn.Close()
 		mutex.Lock()
-		delete(h.clients, userID)
+		delete(h.clients[userID], sessionID)
+		if len(h.clients[userID]) == 0 {
+			delete(h.clients, userID)
+		}
 		mutex.Unlock()
 
-		// Set status to offline
-		_, err := h.userService.Update(
-			userID,
-			dtos.UpdateUserRequest{Status: "offline"},
-		)
-		if err != nil {
+		if err := h.presenceService.Disconnect(userID, sessionID); err != nil {
 			log.Error(
 				"Failed to set user status to offline",
 				"userID",
@@ -137,26 +168,18 @@ func (h *WSHandler) WebSocketHandler(c *gin.Context) {
 			)
 		}
 
-		log.Info("Client disconnected", "id", userID)
+		log.Info("Client disconnected", "id", userID, "sessionID", sessionID)
 	}()
 
 	mutex.Lock()
-	h.clients[userID] = conn
-	mutex.Unlock()
-
-	_, err = h.userService.Update(userID, dtos.UpdateUserRequest{Status: "online"})
-	if err != nil {
-		log.Error(
-			"Failed to set user status to online",
-			"userID",
-			userID,
-			"err",
-			err.Error(),
-		)
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[string]*websocket.Conn)
 	}
+	h.clients[userID][sessionID] = conn
+	mutex.Unlock()
 
 	conn.WriteJSON(gin.H{"message": "successfully connected to server"})
-	log.Info("Client connected", "id", userID)
+	log.Info("Client connected", "id", userID, "sessionID", sessionID)
 
 	// Handle panics in connection handler
 	defer func() {
@@ -182,57 +205,194 @@ func (h *WSHandler) WebSocketHandler(c *gin.Context) {
 			break
 		}
 
-		if payload.Opcode != 0 {
+		switch payload.Opcode {
+		case 0:
+			if !canSend {
+				conn.WriteJSON(gin.H{"error": "bot token is missing the messages:write scope"})
+				continue
+			}
+			h.handleDispatch(conn, userID, sessionID, payload)
+		case 2:
+			h.handlePresenceUpdate(conn, userID, payload)
+		default:
 			log.Warn("Invalid message type received", "opcode", payload.Opcode, "userID", userID)
-			continue
 		}
+	}
+}
 
-		log.Debug("Received message", "msg", payload)
+func (h *WSHandler) handleDispatch(
+	conn *websocket.Conn,
+	userID, sessionID string,
+	payload dtos.Payload,
+) {
+	log.Debug("Received message", "msg", payload)
 
-		var msgData dtos.DispatchData
-		if err := mapstructure.Decode(payload.Data, &msgData); err != nil {
-			conn.WriteJSON(gin.H{"error": "invalid data body structure"})
+	var msgData dtos.DispatchData
+	if err := mapstructure.Decode(payload.Data, &msgData); err != nil {
+		conn.WriteJSON(gin.H{"error": "invalid data body structure"})
 
-			log.Error("Failed to unmarshal dispatch data", "err", err.Error())
-			continue
+		log.Error("Failed to unmarshal dispatch data", "err", err.Error())
+		return
+	}
+
+	// Checked up front since formatting, mentions and attachments all act on
+	// the room
+	isParticipant, err := h.chatroomService.IsParticipant(msgData.RoomID, userID)
+	if err != nil {
+		conn.WriteJSON(gin.H{"error": "failed to send message"})
+
+		log.Error("Failed to check participant", "userID", userID, "err", err.Error())
+		return
+	}
+	if !isParticipant {
+		conn.WriteJSON(gin.H{"error": services.ErrNotParticipant.Error()})
+		return
+	}
+
+	// Messages starting with a slash are commands, unless it's doubled to
+	// send the message as it is
+	if rest, ok := strings.CutPrefix(msgData.Content, "//"); ok {
+		msgData.Content = "/" + rest
+	} else if strings.HasPrefix(msgData.Content, "/") {
+		content, err := h.commandService.Execute(
+			msgData.RoomID,
+			userID,
+			sessionID,
+			msgData.Content,
+		)
+		if err != nil {
+			if errors.Is(err, services.ErrChatRoomNotFound) ||
+				errors.Is(err, services.ErrNotParticipant) {
+				conn.WriteJSON(gin.H{"error": err.Error()})
+			} else {
+				conn.WriteJSON(gin.H{"error": "failed to run command"})
+				log.Error("Failed to run command", "userID", userID, "err", err.Error())
+			}
+			return
+		}
+		if content == "" {
+			return
 		}
+		msgData.Content = content
+	}
 
-		msg := dtos.MessageResponse{
-			Content:   msgData.Content,
-			SenderID:  userID,
-			RoomID:    msgData.RoomID,
-			Timestamp: payload.Timestamp.Format(time.RFC3339),
+	content, entities, err := h.messageService.Format(
+		msgData.Content,
+		userID,
+		msgData.RoomID,
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMessage) {
+			conn.WriteJSON(gin.H{"error": err.Error()})
+		} else {
+			conn.WriteJSON(gin.H{"error": "failed to send message"})
 		}
 
-		// Persist message to DB
-		err = h.messageService.CreateMessage(&models.Message{
-			SenderID:  msg.SenderID,
-			RoomID:    msg.RoomID,
-			Content:   msg.Content,
-			Timestamp: payload.Timestamp,
-		})
+		log.Error("Failed to format message", "userID", userID, "err", err.Error())
+		return
+	}
+	if content == "" && len(msgData.AttachmentIDs) == 0 {
+		conn.WriteJSON(gin.H{"error": "message is empty"})
+		return
+	}
+
+	// Persist message to DB
+	message := &models.Message{
+		SenderID:  userID,
+		RoomID:    msgData.RoomID,
+		Type:      models.UserMessage,
+		Content:   content,
+		Entities:  entities,
+		Timestamp: payload.Timestamp,
+	}
+
+	if len(msgData.AttachmentIDs) > 0 {
+		attachments, err := h.attachmentService.Resolve(msgData.AttachmentIDs, userID, msgData.RoomID)
 		if err != nil {
-			log.Error("Failed to persist message", "err", err.Error())
+			conn.WriteJSON(gin.H{"error": err.Error()})
+
+			log.Error("Failed to resolve attachments", "userID", userID, "err", err.Error())
+			return
 		}
+		message.Attachments = attachments
+	}
+
+	if err := h.messageService.CreateMessage(message); err != nil {
+		conn.WriteJSON(gin.H{"error": "failed to send message"})
+
+		log.Error("Failed to persist message", "err", err.Error())
+		return
+	}
+	msg := dtos.NewMessageResponse(message)
+	msg.Attachments = h.attachmentService.ToResponses(message.Attachments)
+
+	// Publish message to NATS
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		log.Error("Error marshaling message to JSON for NATS", "err", err.Error())
+		return // Handle error appropriately
+	}
+
+	err = h.nc.Publish(services.ChatMessagesSubject, msgJSON)
+	if err != nil {
+		log.Error("Error publishing message to NATS", "err", err.Error())
+		return // Handle error appropriately
+	}
+}
+
+func (h *WSHandler) handlePresenceUpdate(
+	conn *websocket.Conn,
+	userID string,
+	payload dtos.Payload,
+) {
+	var presenceData dtos.PresenceUpdateData
+	if err := mapstructure.Decode(payload.Data, &presenceData); err != nil {
+		conn.WriteJSON(gin.H{"error": "invalid data body structure"})
+
+		log.Error("Failed to unmarshal presence update data", "err", err.Error())
+		return
+	}
 
-		// Publish message to NATS
-		msgJSON, err := json.Marshal(msg)
+	if presenceData.Status != "" {
+		err := h.presenceService.SetStatus(userID, models.PresenceStatus(presenceData.Status))
 		if err != nil {
-			log.Error("Error marshaling message to JSON for NATS", "err", err.Error())
-			continue // Handle error appropriately
+			conn.WriteJSON(gin.H{"error": err.Error()})
+
+			log.Error("Failed to update presence status", "userID", userID, "err", err.Error())
+			return
 		}
+	}
 
-		err = h.nc.Publish("chat_messages", msgJSON)
-		if err != nil {
-			log.Error("Error publishing message to NATS", "err", err.Error())
-			continue // Handle error appropriately
+	if presenceData.CustomStatus != nil {
+		var customStatus *models.CustomStatus
+		if presenceData.CustomStatus.Text != "" || presenceData.CustomStatus.Emoji != "" {
+			customStatus = &models.CustomStatus{
+				Text:  presenceData.CustomStatus.Text,
+				Emoji: presenceData.CustomStatus.Emoji,
+			}
+
+			if presenceData.CustomStatus.ExpiresAt != "" {
+				expiresAt, err := time.Parse(time.RFC3339, presenceData.CustomStatus.ExpiresAt)
+				if err != nil {
+					conn.WriteJSON(gin.H{"error": "invalid custom status expiry"})
+					return
+				}
+				customStatus.ExpiresAt = &expiresAt
+			}
+		}
+
+		if err := h.presenceService.SetCustomStatus(userID, customStatus); err != nil {
+			conn.WriteJSON(gin.H{"error": err.Error()})
+
+			log.Error("Failed to update custom status", "userID", userID, "err", err.Error())
+			return
 		}
 	}
 }
 
 func (h *WSHandler) StartBroadcaster() {
 	// Subscribe to NATS subject
-	_, err := h.nc.Subscribe("chat_messages", func(m *nats.Msg) {
+	_, err := h.nc.Subscribe(services.ChatMessagesSubject, func(m *nats.Msg) {
 		var msg dtos.MessageResponse
 		err := json.Unmarshal(m.Data, &msg)
 		if err != nil {
@@ -242,47 +402,185 @@ func (h *WSHandler) StartBroadcaster() {
 
 		log.Debug("Received NATS message", "msg", msg)
 
-		chatroom, err := h.chatroomService.GetByID(msg.RoomID)
+		recipientIDs, err := h.getRoomParticipantIDs(msg.RoomID)
 		if err != nil {
-			log.Error("Error finding chatroom", "chatroomID", msg.RoomID, "err", err.Error())
 			return
 		}
 
-		if chatroom.Participants == nil {
-			log.Warn("No participants found in chatroom", "chatroomID", msg.RoomID)
+		// Broadcast message to all connected clients, filtering by roomID
+		h.sendMessage(recipientIDs, msg)
+	})
+	if err != nil {
+		log.Error("Error subscribing to NATS subject", "err", err.Error())
+		return // Handle error appropriately
+	}
+	log.Info("Subscribed to NATS subject: " + services.ChatMessagesSubject)
+
+	_, err = h.nc.Subscribe(services.GatewayEventsSubject, func(m *nats.Msg) {
+		var event dtos.GatewayEvent
+		if err := json.Unmarshal(m.Data, &event); err != nil {
+			log.Error("Error unmarshaling gateway event", "err", err.Error())
 			return
 		}
 
-		recipientIDs := make([]string, 0, len(chatroom.Participants))
-		for _, participant := range chatroom.Participants {
-			recipientIDs = append(recipientIDs, participant.ID)
+		recipientIDs := event.RecipientIDs
+		if event.RoomID != 0 {
+			recipientIDs, err = h.getRoomParticipantIDs(event.RoomID)
+			if err != nil {
+				return
+			}
 		}
 
-		// Broadcast message to all connected clients, filtering by roomID
-		mutex.Lock()
-		defer mutex.Unlock()
-		for _, userID := range recipientIDs {
-			if client, exists := h.clients[userID]; exists {
-				if err := client.WriteJSON(msg); err != nil {
-					log.Error(
-						"Error broadcasting message to client",
-						"userID",
-						userID,
-						"err",
-						err.Error(),
-					)
-					client.Close()
-					delete(h.clients, userID) // Remove client if write fails
-				}
-			}
+		if event.SessionID != "" {
+			h.sendToSession(recipientIDs, event.SessionID, event.Event)
+			return
 		}
+		h.sendToUsers(recipientIDs, event.Event)
 	})
 	if err != nil {
 		log.Error("Error subscribing to NATS subject", "err", err.Error())
-		return // Handle error appropriately
+		return
 	}
-	log.Info("Subscribed to NATS subject: chat_messages")
+	log.Info("Subscribed to NATS subject: " + services.GatewayEventsSubject)
 
 	// Keep broadcaster running
 	select {} // Block indefinitely to keep goroutine alive
 }
+
+func (h *WSHandler) getRoomParticipantIDs(roomID uint) ([]string, error) {
+	chatroom, err := h.chatroomService.GetByID(roomID)
+	if err != nil {
+		log.Error("Error finding chatroom", "chatroomID", roomID, "err", err.Error())
+		return nil, err
+	}
+
+	if chatroom.Participants == nil {
+		log.Warn("No participants found in chatroom", "chatroomID", roomID)
+		return nil, nil
+	}
+
+	recipientIDs := make([]string, 0, len(chatroom.Participants))
+	for _, participant := range chatroom.Participants {
+		recipientIDs = append(recipientIDs, participant.ID)
+	}
+
+	return recipientIDs, nil
+}
+
+// sendMessage delivers a chat message to the recipients connected to this
+// node, marking it silent for those who shouldn't be notified of it. System
+// messages are silent for everyone.
+func (h *WSHandler) sendMessage(recipientIDs []string, msg dtos.MessageResponse) {
+	recipientIDs = h.connectedUsers(recipientIDs)
+
+	if msg.Type == string(models.SystemMessage) {
+		msg.Silent = true
+		h.sendToUsers(recipientIDs, msg)
+		return
+	}
+
+	notifiedIDs, err := h.notificationService.FilterNotified(
+		msg.RoomID,
+		recipientIDs,
+		func(userID string) bool {
+			return mentions(msg.Entities, userID)
+		},
+	)
+	if err != nil {
+		// Better to notify too often than to drop the message
+		log.Error("Failed to get notification settings", "roomID", msg.RoomID, "err", err.Error())
+		notifiedIDs = recipientIDs
+	}
+	notifiedIDs = slices.DeleteFunc(notifiedIDs, func(userID string) bool {
+		return userID == msg.SenderID && msg.WebhookID == 0
+	})
+
+	silentIDs := slices.DeleteFunc(slices.Clone(recipientIDs), func(userID string) bool {
+		return slices.Contains(notifiedIDs, userID)
+	})
+
+	h.sendToUsers(notifiedIDs, msg)
+
+	msg.Silent = true
+	h.sendToUsers(silentIDs, msg)
+}
+
+// connectedUsers returns the users among userIDs with a session on this
+// node.
+func (h *WSHandler) connectedUsers(userIDs []string) []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	connected := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if len(h.clients[userID]) > 0 {
+			connected = append(connected, userID)
+		}
+	}
+	return connected
+}
+
+// mentions reports whether a message's entities mention the user. Recipients
+// on the gateway are online, so @here counts as mentioning them.
+func mentions(entities []dtos.EntityResponse, userID string) bool {
+	for _, entity := range entities {
+		switch models.EntityType(entity.Type) {
+		case models.EveryoneMentionEntity, models.HereMentionEntity:
+			return true
+		case models.UserMentionEntity:
+			if entity.UserID == userID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sendToUsers writes v to every recipient that is connected to this node.
+func (h *WSHandler) sendToUsers(userIDs []string, v any) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, userID := range userIDs {
+		for sessionID, client := range h.clients[userID] {
+			if err := client.WriteJSON(v); err != nil {
+				log.Error(
+					"Error broadcasting message to client",
+					"userID",
+					userID,
+					"sessionID",
+					sessionID,
+					"err",
+					err.Error(),
+				)
+				client.Close()
+				delete(h.clients[userID], sessionID) // Remove client if write fails
+			}
+		}
+	}
+}
+
+// sendToSession writes v to one session of the recipients, if it's connected
+// to this node.
+func (h *WSHandler) sendToSession(userIDs []string, sessionID string, v any) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, userID := range userIDs {
+		client, ok := h.clients[userID][sessionID]
+		if !ok {
+			continue
+		}
+		if err := client.WriteJSON(v); err != nil {
+			log.Error(
+				"Error sending to client",
+				"userID",
+				userID,
+				"sessionID",
+				sessionID,
+				"err",
+				err.Error(),
+			)
+			client.Close()
+			delete(h.clients[userID], sessionID)
+		}
+	}
+}
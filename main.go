@@ -2,8 +2,13 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
 	"fmt"
 	"net/http"
+	"net/netip"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
@@ -11,6 +16,7 @@ import (
 	"github.com/charmbracelet/log"
 	"github.com/clerk/clerk-sdk-go/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
 	"github.com/redis/go-redis/v9"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -20,10 +26,17 @@ import (
 	_ "github.com/teamyapchat/yapchat-server/docs"
 	"github.com/teamyapchat/yapchat-server/internal/config"
 	"github.com/teamyapchat/yapchat-server/internal/handlers"
+	"github.com/teamyapchat/yapchat-server/internal/jwt"
+	"github.com/teamyapchat/yapchat-server/internal/mail"
 	"github.com/teamyapchat/yapchat-server/internal/middleware"
 	"github.com/teamyapchat/yapchat-server/internal/models"
 	"github.com/teamyapchat/yapchat-server/internal/repositories"
+	"github.com/teamyapchat/yapchat-server/internal/search"
 	"github.com/teamyapchat/yapchat-server/internal/services"
+	"github.com/teamyapchat/yapchat-server/internal/storage"
+	"github.com/teamyapchat/yapchat-server/internal/unfurl"
+	"github.com/teamyapchat/yapchat-server/internal/webhook"
+	"github.com/teamyapchat/yapchat-server/internal/webpush"
 	"github.com/teamyapchat/yapchat-server/internal/websocket"
 )
 
@@ -45,6 +58,17 @@ func InitDB(cfg config.Config) (*gorm.DB, error) {
 		&models.User{},
 		&models.ChatRoom{},
 		&models.Message{},
+		&models.Attachment{},
+		&models.Mention{},
+		&models.RoomSettings{},
+		&models.PushSubscription{},
+		&models.ReadState{},
+		&models.BotToken{},
+		&models.BotCommand{},
+		&models.IncomingWebhook{},
+		&models.OutgoingWebhook{},
+		&models.WebhookDelivery{},
+		&models.ScheduledMessage{},
 	); err != nil {
 		return nil, err
 	}
@@ -65,6 +89,91 @@ func InitRedis(redisURL string) (*redis.Client, error) {
 	return redis.NewClient(opts), nil
 }
 
+func InitBlobStore(cfg config.Config) (storage.BlobStore, error) {
+	switch cfg.StorageBackend {
+	case "local":
+		return storage.NewLocalStore(cfg.StoragePath)
+	case "s3":
+		return storage.NewS3Store(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			UseSSL:    cfg.S3UseSSL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
+
+// InitMailer returns nil when email is disabled.
+func InitMailer(cfg config.Config) (mail.Mailer, error) {
+	switch cfg.MailBackend {
+	case "":
+		return nil, nil
+	case "smtp":
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		})
+	case "file":
+		return mail.NewFileMailer(cfg.MailPath, cfg.MailFrom)
+	default:
+		return nil, fmt.Errorf("unknown mail backend %q", cfg.MailBackend)
+	}
+}
+
+func InitLocalAuthenticator(
+	cfg config.Config,
+	userRepo repositories.UserRepository,
+) (*services.LocalAuthenticator, error) {
+	var key *jwt.Key
+	var err error
+	switch cfg.JWTAlgorithm {
+	case string(jwt.HS256):
+		key, err = jwt.NewHMACKey([]byte(cfg.JWTSecret))
+	case string(jwt.RS256):
+		var pemData []byte
+		pemData, err = os.ReadFile(cfg.JWTKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		key, err = jwt.NewRSAKey(pemData)
+	default:
+		return nil, fmt.Errorf("unknown JWT algorithm %q", cfg.JWTAlgorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return services.NewLocalAuthenticator(key, cfg.JWTIssuer, userRepo), nil
+}
+
+func InitAuthenticator(
+	cfg config.Config,
+	jwksCache *services.JWKSCache,
+	redisClient *redis.Client,
+	userRepo repositories.UserRepository,
+) (services.Authenticator, error) {
+	switch cfg.AuthProvider {
+	case "clerk":
+		clerk.SetKey(cfg.ClerkSecret)
+		return services.NewClerkAuthenticator(jwksCache, userRepo, redisClient), nil
+	case "local":
+		return InitLocalAuthenticator(cfg, userRepo)
+	default:
+		return nil, fmt.Errorf("unknown auth provider %q", cfg.AuthProvider)
+	}
+}
+
+func InitNATS(natsURL string) (*nats.Conn, error) {
+	return nats.Connect(natsURL)
+}
+
 // @title						YapChat API
 // @version					1.0
 // @description				The official API for YapChat
@@ -87,12 +196,38 @@ func main() {
 	}
 	log.Info("Successfully initialized database")
 
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		issueToken(cfg, db, os.Args[2:])
+		return
+	}
+
 	redisClient, err := InitRedis(cfg.RedisURL)
 	if err != nil {
 		log.Fatal("Failed to parse Redis URL", "url", cfg.RedisURL, "err", err.Error())
 	}
 	log.Info("Successfully connected to Redis")
 
+	nc, err := InitNATS(cfg.NATSURL)
+	if err != nil {
+		log.Fatal("Failed to connect to NATS", "err", err.Error())
+	}
+	defer nc.Close()
+	log.Info("Connected to NATS")
+
+	blobStore, err := InitBlobStore(cfg)
+	if err != nil {
+		log.Fatal("Failed to initialize blob storage", "err", err.Error())
+	}
+	log.Info("Successfully initialized blob storage", "backend", cfg.StorageBackend)
+
+	mailer, err := InitMailer(cfg)
+	if err != nil {
+		log.Fatal("Failed to initialize mailer", "err", err.Error())
+	}
+	if mailer == nil {
+		log.Warn("No MAIL_BACKEND set. Email digests are disabled.")
+	}
+
 	// Middlewares
 	limiter := middleware.NewRateLimiter(redisClient)
 
@@ -100,37 +235,244 @@ func main() {
 		Limit:  5,
 		Window: time.Second,
 	})
+	limiter.AddLimiter("webhook", middleware.RateLimitConfig{
+		Limit:  30,
+		Window: time.Minute,
+		// Counted per token as well as per webhook, so that requests with a
+		// wrong token can't use up the real one's quota
+		Key: func(c *gin.Context) string {
+			sum := sha256.Sum256([]byte(c.Param("token")))
+			return c.Param("id") + ":" + hex.EncodeToString(sum[:])
+		},
+	})
 
 	// Repos
 	userRepo := repositories.NewUserRepository(db)
 	chatroomRepo := repositories.NewChatRoomRepository(db)
 	messageRepo := repositories.NewMessageRepository(db)
+	attachmentRepo := repositories.NewAttachmentRepository(db)
+	mentionRepo := repositories.NewMentionRepository(db)
+	roomSettingsRepo := repositories.NewRoomSettingsRepository(db)
+	pushSubscriptionRepo := repositories.NewPushSubscriptionRepository(db)
+	readStateRepo := repositories.NewReadStateRepository(db)
+	botTokenRepo := repositories.NewBotTokenRepository(db)
+	botCommandRepo := repositories.NewBotCommandRepository(db)
+	incomingWebhookRepo := repositories.NewIncomingWebhookRepository(db)
+	outgoingWebhookRepo := repositories.NewOutgoingWebhookRepository(db)
+	scheduledMessageRepo := repositories.NewScheduledMessageRepository(db)
 
 	// Services
-	clerk.SetKey(cfg.ClerkSecret)
-	store := services.NewJWKStore(cfg.ClerkSecret, redisClient)
-	authService := services.NewAuthService(store)
+	var jwksCache *services.JWKSCache
+	if cfg.AuthProvider == "clerk" {
+		jwksCache = services.NewJWKSCache(cfg.ClerkSecret, redisClient)
+	}
+	authenticator, err := InitAuthenticator(cfg, jwksCache, redisClient, userRepo)
+	if err != nil {
+		log.Fatal("Failed to initialize authentication", "err", err.Error())
+	}
+	botService := services.NewBotService(
+		userRepo,
+		botTokenRepo,
+		botCommandRepo,
+		outgoingWebhookRepo,
+	)
+	authService := services.NewAuthService(authenticator, botService)
+
+	eventService := services.NewEventService(nc)
+	sessionService := services.NewSessionService(redisClient, cfg.NodeID)
+	presenceService := services.NewPresenceService(
+		redisClient,
+		chatroomRepo,
+		eventService,
+		sessionService,
+	)
+
+	attachmentService := services.NewAttachmentService(
+		attachmentRepo,
+		chatroomRepo,
+		blobStore,
+		cfg.AttachmentSigningKey,
+		cfg.MaxUploadSize,
+		cfg.PublicURL,
+	)
 
-	userService := services.NewUserService(userRepo)
-	chatroomService := services.NewChatRoomService(chatroomRepo, userRepo, redisClient)
-	messageService := services.NewMessageService(messageRepo)
+	imageService := services.NewImageService(blobStore, cfg.PublicURL)
+
+	userService := services.NewUserService(userRepo, chatroomRepo, eventService, imageService)
+
+	var searchIndex search.Index
+	switch cfg.SearchBackend {
+	case "mysql":
+		searchIndex = search.NewMySQLIndex(db)
+	case "memory":
+		searchIndex = search.NewMemoryIndex()
+	default:
+		log.Fatal("Unknown search backend", "backend", cfg.SearchBackend)
+	}
+
+	notificationService := services.NewNotificationService(
+		roomSettingsRepo,
+		chatroomRepo,
+		eventService,
+	)
+	var pushSender *webpush.Sender
+	if cfg.VAPIDPrivateKey != "" {
+		policy := webpush.EndpointPolicy{AllowAddress: unfurl.IsPublicAddress}
+		if cfg.PushAllowLocalEndpoints {
+			policy = webpush.EndpointPolicy{
+				AllowAddress: func(netip.Addr) bool { return true },
+				AllowHTTP:    true,
+			}
+			log.Warn("PUSH_ALLOW_LOCAL_ENDPOINTS is set. Push endpoints aren't restricted.")
+		}
+		pushSender, err = webpush.NewSender(cfg.VAPIDPrivateKey, cfg.VAPIDSubject, policy)
+		if err != nil {
+			log.Fatal("Failed to initialize Web Push", "err", err.Error())
+		}
+	} else {
+		log.Warn("No VAPID_PRIVATE_KEY set. Web Push notifications are disabled.")
+	}
+	pushService := services.NewPushService(
+		nc,
+		pushSender,
+		pushSubscriptionRepo,
+		userRepo,
+		chatroomRepo,
+		sessionService,
+		notificationService,
+	)
+
+	digestService := services.NewDigestService(
+		redisClient,
+		userRepo,
+		mentionRepo,
+		messageRepo,
+		sessionService,
+		mailer,
+		cfg.DigestSigningKey,
+		cfg.PublicURL,
+		cfg.NodeID,
+	)
+
+	outgoingWebhookService := services.NewOutgoingWebhookService(
+		outgoingWebhookRepo,
+		chatroomRepo,
+		botService,
+		webhook.NewSender(unfurl.IsPublicAddress),
+		redisClient,
+		cfg.NodeID,
+	)
+	mentionService := services.NewMentionService(
+		mentionRepo,
+		chatroomRepo,
+		presenceService,
+		eventService,
+	)
+	unfurlService := services.NewUnfurlService(
+		redisClient,
+		messageRepo,
+		eventService,
+		unfurl.NewFetcher(),
+	)
+	messageService := services.NewMessageService(
+		messageRepo,
+		userRepo,
+		chatroomRepo,
+		searchIndex,
+		unfurlService,
+		mentionService,
+		eventService,
+		outgoingWebhookService,
+	)
+	chatroomService := services.NewChatRoomService(
+		chatroomRepo,
+		userRepo,
+		readStateRepo,
+		messageRepo,
+		redisClient,
+		imageService,
+		messageService,
+		eventService,
+		outgoingWebhookService,
+	)
+	incomingWebhookService := services.NewIncomingWebhookService(
+		incomingWebhookRepo,
+		chatroomService,
+		messageService,
+		eventService,
+		cfg.PublicURL,
+	)
+	commandService := services.NewCommandService(
+		chatroomService,
+		messageService,
+		notificationService,
+		botCommandRepo,
+		eventService,
+	)
+	scheduledMessageService := services.NewScheduledMessageService(
+		scheduledMessageRepo,
+		chatroomService,
+		messageService,
+		eventService,
+		redisClient,
+		cfg.NodeID,
+	)
+	if cfg.SearchBackend == "memory" {
+		if err := messageService.RebuildIndex(); err != nil {
+			log.Fatal("Failed to build search index", "err", err.Error())
+		}
+		log.Info("Successfully built in-memory search index")
+	}
 
 	// Handlers
-	userHandler := handlers.NewUserHandler(userService)
-	chatroomHandler := handlers.NewChatRoomHandler(chatroomService, messageService)
+	userHandler := handlers.NewUserHandler(userService, presenceService)
+	chatroomHandler := handlers.NewChatRoomHandler(
+		chatroomService,
+		messageService,
+		presenceService,
+		attachmentService,
+		notificationService,
+	)
+	searchHandler := handlers.NewSearchHandler(chatroomService, messageService, attachmentService)
+	attachmentHandler := handlers.NewAttachmentHandler(attachmentService, chatroomService)
+	imageHandler := handlers.NewImageHandler(imageService)
+	mentionHandler := handlers.NewMentionHandler(mentionService)
+	pushHandler := handlers.NewPushHandler(pushService)
+	botHandler := handlers.NewBotHandler(botService)
+	incomingWebhookHandler := handlers.NewIncomingWebhookHandler(incomingWebhookService)
+	outgoingWebhookHandler := handlers.NewOutgoingWebhookHandler(outgoingWebhookService)
+	commandHandler := handlers.NewCommandHandler(commandService)
+	scheduledMessageHandler := handlers.NewScheduledMessageHandler(scheduledMessageService)
+	digestHandler := handlers.NewDigestHandler(digestService)
 	webhookHandler := handlers.NewWebhookHandler(cfg.SigningSecret, userService)
 	wsHandler := websocket.NewWSHandler(
-		cfg.NATSURL,
+		nc,
 		authService,
 		chatroomService,
 		messageService,
-		userService,
+		presenceService,
+		attachmentService,
+		notificationService,
+		commandService,
 	)
 	go wsHandler.StartBroadcaster()
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	presenceService.Start()
+	go presenceService.Run(ctx)
+	go unfurlService.Run(ctx)
+	go pushService.Run(ctx)
+	go outgoingWebhookService.Run(ctx)
+	go scheduledMessageService.Run(ctx)
+	if jwksCache != nil {
+		go jwksCache.Run(ctx)
+	}
+	if mailer != nil {
+		go digestService.Run(ctx)
+	}
+
 	router := gin.Default()
 
 	router.SetTrustedProxies(nil)
@@ -140,24 +482,159 @@ func main() {
 
 	router.GET("/ws", wsHandler.WebSocketHandler)
 
-	router.POST("/webhook", webhookHandler.WebhookHandler)
+	// Users are synced from Clerk, and created by the token command otherwise
+	if cfg.AuthProvider == "clerk" {
+		router.POST("/webhook", webhookHandler.WebhookHandler)
+	}
+
+	router.GET("/attachments/:id/download", attachmentHandler.DownloadHandler)
+	router.GET("/images/*key", imageHandler.GetImageHandler)
+	router.POST(
+		"/webhooks/:id/:token",
+		limiter.Middleware("webhook"),
+		incomingWebhookHandler.ExecuteHandler,
+	)
+
+	// Unsubscribe tokens are signed with a key that only exists while email
+	// is enabled
+	if mailer != nil {
+		router.GET("/digest/unsubscribe", digestHandler.UnsubscribePageHandler)
+		router.POST("/digest/unsubscribe", digestHandler.UnsubscribeHandler)
+	}
+
+	// Routes bots can use, and the scope their token needs for each
+	botRoutes := middleware.BotRoutes{
+		"GET /v1/users/me":                          models.BotScopeUsersRead,
+		"GET /v1/users/:username":                   models.BotScopeUsersRead,
+		"GET /v1/chatrooms":                         models.BotScopeRoomsRead,
+		"GET /v1/chatrooms/:id":                     models.BotScopeRoomsRead,
+		"POST /v1/chatrooms/:id/join":               models.BotScopeRoomsJoin,
+		"POST /v1/chatrooms/:id/leave":              models.BotScopeRoomsJoin,
+		"GET /v1/chatrooms/:id/messages":            models.BotScopeMessagesRead,
+		"GET /v1/chatrooms/:id/pins":                models.BotScopeMessagesRead,
+		"GET /v1/attachments/:id":                   models.BotScopeMessagesRead,
+		"POST /v1/chatrooms/:id/attachments":        models.BotScopeMessagesWrite,
+		"GET /v1/chatrooms/:id/commands":            models.BotScopeMessagesRead,
+		"POST /v1/chatrooms/:id/ephemeral-messages": models.BotScopeMessagesWrite,
+		"GET /v1/bots/:id/commands":                 models.BotScopeCommandsWrite,
+		"PUT /v1/bots/:id/commands":                 models.BotScopeCommandsWrite,
+	}
 
 	protected := router.Group("/v1")
-	protected.Use(middleware.AuthMiddleware(authService), limiter.Middleware("protected"))
+	protected.Use(
+		middleware.AuthMiddleware(authService, botRoutes),
+		limiter.Middleware("protected"),
+	)
 	{
 		// User routes
 		protected.GET("/users/me", userHandler.GetMeHandler)
+		protected.GET("/users/search", userHandler.SearchHandler)
 		protected.GET("/users/:username", userHandler.GetByUsernameHandler)
 
+		protected.PATCH("/users/me", userHandler.UpdateMeHandler)
+		protected.PUT("/users/me/presence", userHandler.UpdatePresenceHandler)
+		protected.PUT("/users/me/avatar", userHandler.UpdateAvatarHandler)
+
+		// Mention routes
+		protected.GET("/users/me/mentions", mentionHandler.ListMentionsHandler)
+		protected.POST("/users/me/mentions/read", mentionHandler.MarkAllReadHandler)
+		protected.POST("/users/me/mentions/:id/read", mentionHandler.MarkReadHandler)
+
 		// Chatroom routes
 		protected.GET("/chatrooms", chatroomHandler.ListChatroomsHandler)
 		protected.GET("/chatrooms/:id", chatroomHandler.GetByIDHandler)
 		protected.GET("/chatrooms/:id/invite-code", chatroomHandler.GetInviteCodeHandler)
 		protected.GET("/chatrooms/:id/messages", chatroomHandler.GetMessagesByRoomIDHandler)
+		protected.GET("/chatrooms/:id/settings", chatroomHandler.GetSettingsHandler)
+		protected.GET("/chatrooms/:id/pins", chatroomHandler.ListPinsHandler)
+		protected.GET("/chatrooms/:id/webhooks", incomingWebhookHandler.ListHandler)
+		protected.GET("/chatrooms/:id/commands", commandHandler.ListCommandsHandler)
 
 		protected.POST("/chatrooms", chatroomHandler.CreateHandler)
 		protected.POST("/chatrooms/:id/join", chatroomHandler.JoinChatroomHandler)
 		protected.POST("/chatrooms/:id/leave", chatroomHandler.LeaveChatroomHandler)
+		protected.POST("/chatrooms/:id/read", chatroomHandler.MarkReadHandler)
+		protected.POST(
+			"/chatrooms/:id/ephemeral-messages",
+			chatroomHandler.SendEphemeralMessageHandler,
+		)
+		protected.POST("/chatrooms/:id/webhooks", incomingWebhookHandler.CreateHandler)
+
+		protected.PUT("/chatrooms/:id/icon", chatroomHandler.UpdateIconHandler)
+		protected.PUT("/chatrooms/:id/pins/:message_id", chatroomHandler.PinHandler)
+		protected.PATCH("/chatrooms/:id", chatroomHandler.RenameHandler)
+		protected.PATCH("/chatrooms/:id/settings", chatroomHandler.UpdateSettingsHandler)
+
+		protected.DELETE("/chatrooms/:id/pins/:message_id", chatroomHandler.UnpinHandler)
+		protected.DELETE("/chatrooms/:id/participants/:user_id", chatroomHandler.KickHandler)
+		protected.DELETE(
+			"/chatrooms/:id/webhooks/:webhook_id",
+			incomingWebhookHandler.DeleteHandler,
+		)
+
+		// Scheduled message routes
+		protected.GET("/chatrooms/:id/scheduled-messages", scheduledMessageHandler.ListHandler)
+		protected.POST("/chatrooms/:id/scheduled-messages", scheduledMessageHandler.CreateHandler)
+		protected.PATCH(
+			"/chatrooms/:id/scheduled-messages/:message_id",
+			scheduledMessageHandler.UpdateHandler,
+		)
+		protected.DELETE(
+			"/chatrooms/:id/scheduled-messages/:message_id",
+			scheduledMessageHandler.DeleteHandler,
+		)
+
+		// Attachment routes
+		protected.GET("/attachments/:id", attachmentHandler.GetByIDHandler)
+		protected.POST("/chatrooms/:id/attachments", attachmentHandler.UploadHandler)
+
+		// Push notification routes
+		protected.GET("/push/vapid-key", pushHandler.GetVAPIDKeyHandler)
+		protected.POST("/users/me/push-subscriptions", pushHandler.SubscribeHandler)
+		protected.DELETE("/users/me/push-subscriptions", pushHandler.UnsubscribeHandler)
+
+		// Search routes
+		protected.GET("/search/messages", searchHandler.SearchMessagesHandler)
+
+		// Bot routes
+		protected.GET("/bots", botHandler.ListBotsHandler)
+		protected.GET("/bots/:id/tokens", botHandler.ListTokensHandler)
+		protected.GET("/bots/:id/commands", botHandler.ListCommandsHandler)
+
+		protected.POST("/bots", botHandler.CreateBotHandler)
+		protected.POST("/bots/:id/tokens", botHandler.CreateTokenHandler)
+		protected.POST("/bots/:id/tokens/:token_id/rotate", botHandler.RotateTokenHandler)
+
+		protected.PUT("/bots/:id/commands", botHandler.SetCommandsHandler)
+
+		protected.DELETE("/bots/:id", botHandler.DeleteBotHandler)
+		protected.DELETE("/bots/:id/tokens/:token_id", botHandler.RevokeTokenHandler)
+
+		// Outgoing webhook routes
+		protected.GET(
+			"/chatrooms/:id/outgoing-webhooks",
+			outgoingWebhookHandler.ListRoomWebhooksHandler,
+		)
+		protected.GET("/bots/:id/outgoing-webhooks", outgoingWebhookHandler.ListBotWebhooksHandler)
+		protected.GET(
+			"/outgoing-webhooks/:id/deliveries",
+			outgoingWebhookHandler.ListDeliveriesHandler,
+		)
+
+		protected.POST(
+			"/chatrooms/:id/outgoing-webhooks",
+			outgoingWebhookHandler.CreateRoomWebhookHandler,
+		)
+		protected.POST(
+			"/bots/:id/outgoing-webhooks",
+			outgoingWebhookHandler.CreateBotWebhookHandler,
+		)
+		protected.POST(
+			"/outgoing-webhooks/:id/deliveries/:delivery_id/retry",
+			outgoingWebhookHandler.RetryDeliveryHandler,
+		)
+
+		protected.DELETE("/outgoing-webhooks/:id", outgoingWebhookHandler.DeleteHandler)
 	}
 
 	srv := &http.Server{
@@ -171,6 +648,15 @@ func main() {
 		}
 	}()
 
+	// Metrics are served on their own listener so they aren't public
+	if cfg.MetricsAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(cfg.MetricsAddr, expvar.Handler()); err != nil {
+				log.Error("Error while running metrics server", "err", err.Error())
+			}
+		}()
+	}
+
 	<-ctx.Done()
 
 	stop()
@@ -183,5 +669,7 @@ func main() {
 		log.Fatal("Server forced to shutdown", "err", err.Error())
 	}
 
+	presenceService.Shutdown()
+
 	log.Info("Server exiting")
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gorm.io/gorm"
+
+	"github.com/teamyapchat/yapchat-server/internal/config"
+	"github.com/teamyapchat/yapchat-server/internal/models"
+	"github.com/teamyapchat/yapchat-server/internal/repositories"
+)
+
+// issueToken implements the token command, which prints a session token for
+// the local auth provider, creating the user if they don't exist yet:
+//
+//	yapchat-server token [-username name] [-ttl 720h] <user-id>
+func issueToken(cfg config.Config, db *gorm.DB, args []string) {
+	flags := flag.NewFlagSet("token", flag.ExitOnError)
+	username := flags.String("username", "", "username for a new user (defaults to the user ID)")
+	ttl := flags.Duration("ttl", 30*24*time.Hour, "how long the token is valid for")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatal("Usage: token [-username name] [-ttl duration] <user-id>")
+	}
+	userID := flags.Arg(0)
+
+	if cfg.AuthProvider != "local" {
+		log.Fatal("Tokens can only be issued with AUTH_PROVIDER=local")
+	}
+
+	userRepo := repositories.NewUserRepository(db)
+	authenticator, err := InitLocalAuthenticator(cfg, userRepo)
+	if err != nil {
+		log.Fatal("Failed to initialize authentication", "err", err.Error())
+	}
+
+	if _, err := userRepo.FindByID(userID); err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Fatal("Failed to find user", "userID", userID, "err", err.Error())
+		}
+
+		if *username == "" {
+			*username = userID
+		}
+		if err := userRepo.Create(&models.User{ID: userID, Username: *username}); err != nil {
+			log.Fatal("Failed to create user", "userID", userID, "err", err.Error())
+		}
+		log.Info("Created user", "userID", userID, "username", *username)
+	}
+
+	token, err := authenticator.IssueToken(userID, *ttl)
+	if err != nil {
+		log.Fatal("Failed to issue token", "err", err.Error())
+	}
+	fmt.Println(token)
+}